@@ -9,4 +9,7 @@ func main() {
 	plugin.Serve(&plugin.ServeOpts{
 		ProviderFunc: raws.Provider,
 	})
+
+	// Serve returns when Terraform tells the plugin to quit.
+	raws.ShutdownMetrics()
 }
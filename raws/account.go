@@ -0,0 +1,112 @@
+package raws
+
+import (
+	"fmt"
+	"log"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+	coec2 "github.com/awslabs/aws-sdk-go/gen/ec2"
+	"github.com/hashicorp/go-multierror"
+)
+
+// getCallerIdentityRequest and getCallerIdentityResult describe the STS
+// GetCallerIdentity call, which the generated STS client doesn't have.
+type getCallerIdentityRequest struct{}
+
+type getCallerIdentityResult struct {
+	Account codaws.StringValue `xml:"GetCallerIdentityResult>Account"`
+	ARN     codaws.StringValue `xml:"GetCallerIdentityResult>Arn"`
+	UserID  codaws.StringValue `xml:"GetCallerIdentityResult>UserId"`
+}
+
+// AWSAccountID returns the ID of the account the credentials belong to,
+// asking STS first and falling back to the owner of the default security
+// group for endpoints that don't implement GetCallerIdentity.
+func (c *Config) AWSAccountID(creds codaws.CredentialsProvider, ec2conn EC2API) (string, error) {
+	var errs []error
+
+	id, err := c.stsAccountID(creds)
+	if err == nil {
+		return id, nil
+	}
+	log.Printf("[DEBUG] Unable to get account ID from STS: %s", err)
+	errs = append(errs, err)
+
+	id, err = defaultSecurityGroupOwnerID(ec2conn)
+	if err == nil {
+		return id, nil
+	}
+	log.Printf("[DEBUG] Unable to get account ID from the default security group: %s", err)
+	errs = append(errs, err)
+
+	return "", fmt.Errorf("Error determining the AWS account ID: %s", &multierror.Error{Errors: errs})
+}
+
+func (c *Config) stsAccountID(creds codaws.CredentialsProvider) (string, error) {
+	httpClient, err := c.serviceHTTPClient("sts", creds)
+	if err != nil {
+		return "", err
+	}
+
+	client := &codaws.QueryClient{
+		Context: codaws.Context{
+			Service:     "sts",
+			Region:      c.Region,
+			Credentials: creds,
+		},
+		Client:     httpClient,
+		Endpoint:   fmt.Sprintf("https://sts.%s.%s", c.Region, partitionForRegion(c.Region).DNSSuffix),
+		APIVersion: "2011-06-15",
+	}
+
+	resp := &getCallerIdentityResult{}
+	if err := client.Do("GetCallerIdentity", "POST", "/", &getCallerIdentityRequest{}, resp); err != nil {
+		return "", err
+	}
+	if resp.Account == nil || *resp.Account == "" {
+		return "", fmt.Errorf("GetCallerIdentity returned no account")
+	}
+
+	return *resp.Account, nil
+}
+
+func defaultSecurityGroupOwnerID(ec2conn EC2API) (string, error) {
+	filterName := "group-name"
+	resp, err := ec2conn.DescribeSecurityGroups(&coec2.DescribeSecurityGroupsRequest{
+		Filters: []coec2.Filter{
+			coec2.Filter{
+				Name:   &filterName,
+				Values: []string{"default"},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.SecurityGroups) == 0 || resp.SecurityGroups[0].OwnerID == nil {
+		return "", fmt.Errorf("No default security group found")
+	}
+
+	return *resp.SecurityGroups[0].OwnerID, nil
+}
+
+// ValidateAccountID refuses account IDs that are forbidden or, when an
+// allow list is configured, not on it.
+func (c *Config) ValidateAccountID(accountID string) error {
+	for _, id := range c.ForbiddenAccountIds {
+		if id == accountID {
+			return fmt.Errorf("Forbidden account ID (%s)", accountID)
+		}
+	}
+
+	if len(c.AllowedAccountIds) > 0 {
+		for _, id := range c.AllowedAccountIds {
+			if id == accountID {
+				return nil
+			}
+		}
+		return fmt.Errorf("Account ID not allowed (%s)", accountID)
+	}
+
+	return nil
+}
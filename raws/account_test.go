@@ -0,0 +1,66 @@
+package raws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConfigValidateAccountID(t *testing.T) {
+	cases := []struct {
+		Allowed   []string
+		Forbidden []string
+		AccountID string
+		Err       bool
+	}{
+		{nil, nil, "123456789012", false},
+		{[]string{"123456789012"}, nil, "123456789012", false},
+		{[]string{"111111111111", "123456789012"}, nil, "123456789012", false},
+		{[]string{"111111111111"}, nil, "123456789012", true},
+		{nil, []string{"123456789012"}, "123456789012", true},
+		{nil, []string{"111111111111"}, "123456789012", false},
+	}
+
+	for i, tc := range cases {
+		c := Config{AllowedAccountIds: tc.Allowed, ForbiddenAccountIds: tc.Forbidden}
+		err := c.ValidateAccountID(tc.AccountID)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%d: expected error %t, got %v", i, tc.Err, err)
+		}
+	}
+}
+
+func TestConfigAWSAccountID_sts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Action") != "GetCallerIdentity" {
+			t.Errorf("bad action: %s", r.Form.Get("Action"))
+		}
+		fmt.Fprint(w, `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult>
+    <Arn>arn:aws:iam::123456789012:user/terraform</Arn>
+    <UserId>AIDAEXAMPLE</UserId>
+    <Account>123456789012</Account>
+  </GetCallerIdentityResult>
+  <ResponseMetadata>
+    <RequestId>01234567-89ab-cdef-0123-456789abcdef</RequestId>
+  </ResponseMetadata>
+</GetCallerIdentityResponse>`)
+	}))
+	defer ts.Close()
+
+	c := Config{
+		AccessKey: "key",
+		SecretKey: "secret",
+		Region:    "us-east-1",
+		Endpoints: map[string]string{"sts": ts.URL},
+	}
+	id, err := c.AWSAccountID(c.credentialsChain(), nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != "123456789012" {
+		t.Fatalf("bad account id: %s", id)
+	}
+}
@@ -1,54 +1,125 @@
 package raws
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"strings"
+	"time"
 	"unicode"
 
 	codaws "github.com/awslabs/aws-sdk-go/aws"
-	coec2 "github.com/awslabs/aws-sdk-go/gen/ec2"
-	"github.com/hashicorp/terraform/helper/multierror"
-	"github.com/mitchellh/goamz/aws"
-	"github.com/mitchellh/goamz/ec2"
+	"github.com/awslabs/aws-sdk-go/gen/sts"
+	"github.com/hashicorp/go-multierror"
 )
 
 type Config struct {
-	AccessKey string
-	SecretKey string
-	Region    string
+	AccessKey        string
+	SecretKey        string
+	Profile          string
+	Token            string
+	CredsFilename    string
+	ConfigFilename   string
+	MetadataEndpoint string
+	MetadataTimeout  time.Duration
+	Region           string
+	AssumeRole       *AssumeRole
+
+	Endpoints            map[string]string
+	Insecure             bool
+	HTTPProxy            string
+	CABundle             string
+	RequestTimeout       time.Duration
+	SkipRegionValidation bool
+	MaxRetries           int
+	ValidatePermissions  bool
+
+	MaxRequestsPerSecond int
+	MaxRequestsBurst     int
+
+	AllowedAccountIds   []string
+	ForbiddenAccountIds []string
+
+	DefaultTags          map[string]string
+	IgnoreTagKeys        []string
+	IgnoreTagKeyPrefixes []string
+
+	limiter       *rateLimiter
+	baseTransport http.RoundTripper
+	metadataConn  *metadataClient
+
+	// wrapTransport, when set, wraps the transport of every service
+	// connection. Tests use it to record and replay API traffic.
+	wrapTransport func(service string, base http.RoundTripper) http.RoundTripper
 }
 
 type AWSClient struct {
-	ec2conn  *ec2.EC2
-	codaConn *coec2.EC2
+	codaConn    EC2API
+	region      string
+	accountid   string
+	defaultTags map[string]string
+	ignoreTags  *ignoreTagsConfig
 }
 
 func (c *Config) Client() (interface{}, error) {
 	var client AWSClient
 
-	// Get the auth and region. This can fail if keys/regions were not
+	// Get the region and auth. This can fail if keys/regions were not
 	// specified and we're attempting to use the environment.
 	var errs []error
-	log.Println("[INFO] Building AWS auth structure")
-	auth, err := c.AWSAuth()
-	if err != nil {
-		errs = append(errs, err)
-	}
-
 	log.Println("[INFO] Building AWS region structure")
 	region, err := c.AWSRegion()
 	if err != nil {
 		errs = append(errs, err)
+	} else {
+		c.Region = region
+	}
+
+	var creds codaws.CredentialsProvider
+	if len(errs) == 0 {
+		log.Println("[INFO] Building AWS auth structure")
+		creds, err = c.AWSCredentials()
+		if err != nil {
+			errs = append(errs, err)
+		}
 	}
 
 	if len(errs) == 0 {
+		client.region = c.Region
+		client.defaultTags = c.DefaultTags
+		client.ignoreTags = &ignoreTagsConfig{
+			Keys:        c.IgnoreTagKeys,
+			KeyPrefixes: c.IgnoreTagKeyPrefixes,
+		}
 		log.Println("[INFO] Initializing EC2 connection")
-		client.ec2conn = ec2.New(auth, region)
-		log.Println("[INFO] Initializing codaws connection")
-		creds := codaws.Creds(c.AccessKey, c.SecretKey, "")
-		client.codaConn = coec2.New(creds, c.Region, nil)
+		httpClient, err := c.serviceHTTPClient("ec2", creds)
+		if err != nil {
+			return nil, err
+		}
+		client.codaConn = newEC2Conn(creds, c.Region, httpClient)
+		if c.ValidatePermissions {
+			log.Println("[INFO] Validating permissions with DryRun before changing resources")
+			if err := checkDryRunSupport(client.codaConn); err != nil {
+				return nil, err
+			}
+			client.codaConn = newDryRunEC2(client.codaConn)
+		}
 
+		if len(c.AllowedAccountIds) > 0 || len(c.ForbiddenAccountIds) > 0 {
+			log.Println("[INFO] Validating the AWS account ID")
+			accountID, err := c.AWSAccountID(creds, client.codaConn)
+			if err != nil {
+				return nil, err
+			}
+			if err := c.ValidateAccountID(accountID); err != nil {
+				return nil, err
+			}
+			client.accountid = accountID
+		}
 	}
 
 	if len(errs) > 0 {
@@ -58,48 +129,176 @@ func (c *Config) Client() (interface{}, error) {
 	return &client, nil
 }
 
-// AWSAuth returns a valid aws.Auth object for access to AWS services, or
-// an error if the authentication couldn't be resolved.
-//
-// TODO(mitchellh): Test in some way.
-func (c *Config) AWSAuth() (aws.Auth, error) {
-	auth, err := aws.GetAuth(c.AccessKey, c.SecretKey)
-	if err == nil {
-		// Store the accesskey and secret that we got...
-		c.AccessKey = auth.AccessKey
-		c.SecretKey = auth.SecretKey
+// assumeRoleCredentials wraps the source credentials in a provider that
+// returns temporary credentials for the configured role.
+func (c *Config) assumeRoleCredentials(source codaws.CredentialsProvider) (codaws.CredentialsProvider, error) {
+	httpClient, err := c.serviceHTTPClient("sts", source)
+	if err != nil {
+		return nil, err
 	}
 
-	return auth, err
+	log.Println("[INFO] Initializing STS connection")
+	return &assumeRoleCredentials{
+		Client: sts.New(source, c.Region, httpClient),
+		Role:   c.AssumeRole,
+	}, nil
 }
 
-func (c *Config) AWSRegion() (aws.Region, error) {
-	if c.Region != "" {
-		if c.IsValidRegion() {
-			return aws.Regions[c.Region], nil
-		} else {
-			return aws.Region{}, fmt.Errorf("Not a valid region: %s", c.Region)
+// serviceHTTPClient returns the HTTP client for a service connection.
+// Every request it sends is retried according to the retry rules and
+// waits on the shared rate limiter, and an endpoint configured for the
+// service overrides the regional endpoint the SDK would use, with requests
+// signed again by creds for that endpoint. With
+// TF_LOG=TRACE every attempt is logged. Calls are counted in the API
+// metrics.
+func (c *Config) serviceHTTPClient(service string, creds codaws.CredentialsProvider) (*http.Client, error) {
+	transport, err := c.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint := c.Endpoints[service]; endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("Invalid %s endpoint %q: must be a URL such as https://host:port", service, endpoint)
 		}
+		log.Printf("[INFO] Using custom %s endpoint %s", service, endpoint)
+		transport = &endpointTransport{Endpoint: u, Credentials: creds, Base: transport}
 	}
 
-	md, err := aws.GetMetaData("placement/availability-zone")
-	if err != nil {
-		return aws.Region{}, err
+	if logTraceEnabled() {
+		transport = &traceTransport{Service: service, Base: transport}
+	}
+
+	if limiter := c.rateLimiter(); limiter != nil {
+		transport = &rateLimitTransport{Limiter: limiter, Base: transport}
+	}
+
+	transport = &retryTransport{
+		MaxRetries: c.MaxRetries,
+		Base:       transport,
+		OnRetry: func(action string) {
+			providerMetrics.retried(service, action)
+		},
+	}
+	providerMetrics.watchSignals()
+	transport = &metricsTransport{Service: service, Metrics: providerMetrics, Base: transport}
+	if c.wrapTransport != nil {
+		transport = c.wrapTransport(service, transport)
+	}
+
+	return &http.Client{Transport: transport, Timeout: c.RequestTimeout}, nil
+}
+
+// rateLimiter returns the limiter shared by all connections, or nil if
+// requests are not rate limited.
+func (c *Config) rateLimiter() *rateLimiter {
+	if c.MaxRequestsPerSecond <= 0 {
+		return nil
 	}
+	if c.limiter == nil {
+		log.Printf("[INFO] Limiting API requests to %d per second (burst %d)",
+			c.MaxRequestsPerSecond, c.MaxRequestsBurst)
+		c.limiter = newRateLimiter(c.MaxRequestsPerSecond, c.MaxRequestsBurst)
+	}
+	return c.limiter
+}
 
-	region := strings.TrimRightFunc(string(md), unicode.IsLetter)
-	return aws.Regions[region], nil
+// metadata returns the instance metadata client shared by region and
+// credential discovery, so they use the same session token.
+func (c *Config) metadata() *metadataClient {
+	if c.metadataConn == nil {
+		c.metadataConn = newMetadataClient(c.MetadataEndpoint, c.MetadataTimeout)
+	}
+	return c.metadataConn
 }
 
-func (c *Config) IsValidRegion() bool {
-	var regions = [11]string{"us-east-1", "us-west-2", "us-west-1", "eu-west-1",
-		"eu-central-1", "ap-southeast-1", "ap-southeast-2", "ap-northeast-1",
-		"sa-east-1", "cn-north-1", "us-gov-west-1"}
+// transport returns the base HTTP transport shared by every connection.
+// It goes through http_proxy, or the proxy from the environment, and
+// trusts the certificates in ca_bundle instead of the system roots.
+func (c *Config) transport() (http.RoundTripper, error) {
+	if c.baseTransport != nil {
+		return c.baseTransport, nil
+	}
+	if !c.Insecure && c.HTTPProxy == "" && c.CABundle == "" {
+		return http.DefaultTransport, nil
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if c.HTTPProxy != "" {
+		u, err := url.Parse(c.HTTPProxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("Invalid http_proxy %q: must be a URL such as http://proxy:3128", c.HTTPProxy)
+		}
+		log.Printf("[INFO] Using HTTP proxy %s://%s", u.Scheme, u.Host)
+		proxy = http.ProxyURL(u)
+	}
 
-	for _, valid := range regions {
-		if c.Region == valid {
-			return true
+	tlsConfig := &tls.Config{}
+	if c.CABundle != "" {
+		pem, err := ioutil.ReadFile(c.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading ca_bundle: %s", err)
 		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Error reading ca_bundle %s: no PEM certificates found", c.CABundle)
+		}
+		log.Printf("[INFO] Using CA bundle %s", c.CABundle)
+		tlsConfig.RootCAs = pool
+	}
+	if c.Insecure {
+		log.Println("[WARN] TLS certificate verification is disabled (insecure = true)")
+		tlsConfig.InsecureSkipVerify = true
 	}
-	return false
+
+	c.baseTransport = &http.Transport{
+		Proxy:               proxy,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	return c.baseTransport, nil
+}
+
+// AWSCredentials returns the credentials provider used by every
+// connection, or an error if no credentials could be resolved from it.
+func (c *Config) AWSCredentials() (codaws.CredentialsProvider, error) {
+	creds := c.credentialsChain()
+	if c.AssumeRole != nil {
+		var err error
+		creds, err = c.assumeRoleCredentials(creds)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := creds.Credentials(); err != nil {
+		return nil, err
+	}
+
+	return creds, nil
+}
+
+// AWSRegion returns the configured region or, when none is set, the
+// region of the instance we're running on.
+func (c *Config) AWSRegion() (string, error) {
+	if c.Region != "" {
+		if c.SkipRegionValidation {
+			log.Printf("[INFO] Skipping validation of region %s", c.Region)
+			return c.Region, nil
+		}
+		if err := validateRegion(c.Region); err != nil {
+			return "", err
+		}
+		return c.Region, nil
+	}
+
+	az, err := c.metadata().get("placement/availability-zone")
+	if err != nil {
+		return "", err
+	}
+
+	region := strings.TrimRightFunc(strings.TrimSpace(az), unicode.IsLetter)
+	log.Printf("[INFO] Using region %s from instance metadata", region)
+	return region, nil
 }
@@ -0,0 +1,142 @@
+package raws
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigAWSRegion(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/latest/meta-data/placement/availability-zone" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "eu-central-1b")
+	}))
+	defer ts.Close()
+
+	c := Config{MetadataEndpoint: ts.URL + "/latest/meta-data/"}
+	region, err := c.AWSRegion()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if region != "eu-central-1" {
+		t.Fatalf("bad region: %s", region)
+	}
+
+	c = Config{Region: "eu-central-1"}
+	if region, err := c.AWSRegion(); err != nil || region != "eu-central-1" {
+		t.Fatalf("bad: %s, %v", region, err)
+	}
+
+	c = Config{Region: "not-a-region"}
+	if _, err := c.AWSRegion(); err == nil {
+		t.Fatal("expected error for invalid region")
+	}
+
+	c = Config{Region: "not-a-region", SkipRegionValidation: true}
+	if region, err := c.AWSRegion(); err != nil || region != "not-a-region" {
+		t.Fatalf("bad: %s, %v", region, err)
+	}
+}
+
+func TestConfigServiceHTTPClient_caBundle(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "raws-ca")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	bundle := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := ioutil.WriteFile(bundle, cert, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	notPEM := filepath.Join(dir, "empty.pem")
+	if err := ioutil.WriteFile(notPEM, []byte("not a certificate"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		Config    Config
+		ConfigErr bool
+		GetErr    bool
+	}{
+		// The self-signed certificate isn't trusted by default
+		{Config{}, false, true},
+		{Config{CABundle: bundle}, false, false},
+		{Config{Insecure: true}, false, false},
+		{Config{CABundle: notPEM}, true, false},
+		{Config{CABundle: filepath.Join(dir, "missing.pem")}, true, false},
+	}
+
+	for i, tc := range cases {
+		client, err := tc.Config.serviceHTTPClient("ec2", nil)
+		if (err != nil) != tc.ConfigErr {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		resp, err := client.Get(ts.URL)
+		if (err != nil) != tc.GetErr {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+func TestConfigServiceHTTPClient_proxy(t *testing.T) {
+	var host string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.URL.Host
+	}))
+	defer proxy.Close()
+
+	c := Config{HTTPProxy: proxy.URL}
+	client, err := c.serviceHTTPClient("ec2", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp, err := client.Get("http://ec2.us-east-1.amazonaws.com/")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+	if host != "ec2.us-east-1.amazonaws.com" {
+		t.Fatalf("request did not go through the proxy: %q", host)
+	}
+
+	c = Config{HTTPProxy: "proxy:3128"}
+	if _, err := c.serviceHTTPClient("ec2", nil); err == nil {
+		t.Fatal("expected error for a proxy that is not a URL")
+	}
+}
+
+func TestConfigServiceHTTPClient_timeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	c := Config{RequestTimeout: 50 * time.Millisecond}
+	client, err := c.serviceHTTPClient("ec2", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := client.Get(ts.URL); err == nil {
+		t.Fatal("expected the request to time out")
+	}
+}
@@ -0,0 +1,498 @@
+package raws
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/sts"
+	"github.com/hashicorp/go-multierror"
+)
+
+// credentialsChain returns the provider used to sign every API request. It
+// resolves credentials the same way the AWS CLI does: static keys from the
+// provider block, the environment, the shared credentials file, a
+// credential_process in the shared config file and finally the instance
+// metadata service.
+func (c *Config) credentialsChain() codaws.CredentialsProvider {
+	return &chainCredentials{
+		Providers: []codaws.CredentialsProvider{
+			&staticCredentials{
+				AccessKeyID:     c.AccessKey,
+				SecretAccessKey: c.SecretKey,
+				SessionToken:    c.Token,
+			},
+			&envCredentials{},
+			&sharedCredentials{
+				Filename: c.CredsFilename,
+				Profile:  c.Profile,
+			},
+			&processCredentials{
+				Filename: c.ConfigFilename,
+				Profile:  c.Profile,
+			},
+			&metadataCredentials{
+				Client: c.metadata(),
+			},
+		},
+	}
+}
+
+// chainCredentials asks each provider in turn and remembers the first one
+// that returns credentials, so later requests don't walk the chain again.
+type chainCredentials struct {
+	Providers []codaws.CredentialsProvider
+
+	mu      sync.Mutex
+	current codaws.CredentialsProvider
+}
+
+func (c *chainCredentials) Credentials() (*codaws.Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current != nil {
+		return c.current.Credentials()
+	}
+
+	var errs []error
+	for _, p := range c.Providers {
+		creds, err := p.Credentials()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Printf("[INFO] AWS credentials resolved from %s", credentialsSource(p))
+		c.current = p
+		return creds, nil
+	}
+
+	return nil, fmt.Errorf("No valid credential sources found for AWS Provider: %s",
+		&multierror.Error{Errors: errs})
+}
+
+func credentialsSource(p codaws.CredentialsProvider) string {
+	switch p.(type) {
+	case *staticCredentials:
+		return "provider configuration"
+	case *envCredentials:
+		return "environment"
+	case *sharedCredentials:
+		return "shared credentials file"
+	case *processCredentials:
+		return "credential_process"
+	case *metadataCredentials:
+		return "instance metadata"
+	case *assumeRoleCredentials:
+		return "assumed role"
+	}
+	return fmt.Sprintf("%T", p)
+}
+
+// staticCredentials are the keys set directly in the provider block.
+type staticCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+func (s *staticCredentials) Credentials() (*codaws.Credentials, error) {
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return nil, errors.New("static credentials: access_key and secret_key are not set")
+	}
+
+	return &codaws.Credentials{
+		AccessKeyID:     s.AccessKeyID,
+		SecretAccessKey: s.SecretAccessKey,
+		SecurityToken:   s.SessionToken,
+	}, nil
+}
+
+// envCredentials reads the standard AWS environment variables, falling
+// back to the older AWS_ACCESS_KEY/AWS_SECRET_KEY names.
+type envCredentials struct{}
+
+func (e *envCredentials) Credentials() (*codaws.Credentials, error) {
+	id := firstEnv("AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY")
+	secret := firstEnv("AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY")
+	if id == "" || secret == "" {
+		return nil, errors.New("environment: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+	}
+
+	return &codaws.Credentials{
+		AccessKeyID:     id,
+		SecretAccessKey: secret,
+		SecurityToken:   os.Getenv("AWS_SESSION_TOKEN"),
+	}, nil
+}
+
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// sharedCredentials reads a profile from the shared credentials file,
+// ~/.aws/credentials unless another filename is given.
+type sharedCredentials struct {
+	Filename string
+	Profile  string
+}
+
+func (s *sharedCredentials) Credentials() (*codaws.Credentials, error) {
+	filename := s.filename()
+	profile := s.profile()
+
+	sections, err := parseINIFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("shared credentials file: %s", err)
+	}
+	section, ok := sections[profile]
+	if !ok {
+		return nil, fmt.Errorf("shared credentials file: profile %q not found in %s", profile, filename)
+	}
+
+	id := section["aws_access_key_id"]
+	secret := section["aws_secret_access_key"]
+	if id == "" || secret == "" {
+		return nil, fmt.Errorf("shared credentials file: profile %q in %s has no keys", profile, filename)
+	}
+
+	return &codaws.Credentials{
+		AccessKeyID:     id,
+		SecretAccessKey: secret,
+		SecurityToken:   section["aws_session_token"],
+	}, nil
+}
+
+func (s *sharedCredentials) filename() string {
+	if s.Filename != "" {
+		return s.Filename
+	}
+	if v := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); v != "" {
+		return v
+	}
+	return filepath.Join(userHomeDir(), ".aws", "credentials")
+}
+
+func (s *sharedCredentials) profile() string {
+	return profileName(s.Profile)
+}
+
+// profileName returns the profile to read from the shared files: the
+// configured one, AWS_PROFILE or "default".
+func profileName(profile string) string {
+	if profile != "" {
+		return profile
+	}
+	if v := os.Getenv("AWS_PROFILE"); v != "" {
+		return v
+	}
+	return "default"
+}
+
+func userHomeDir() string {
+	if home := os.Getenv("HOME"); home != "" {
+		return home
+	}
+	return os.Getenv("USERPROFILE")
+}
+
+// parseINIFile reads the simple INI dialect used by the AWS shared
+// credentials and config files into a map of section name to keys.
+func parseINIFile(filename string) (map[string]map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sections := make(map[string]map[string]string)
+	var current map[string]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			current = make(map[string]string)
+			sections[name] = current
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if i := strings.Index(line, "="); i > 0 {
+			key := strings.TrimSpace(line[:i])
+			current[key] = strings.TrimSpace(line[i+1:])
+		}
+	}
+
+	return sections, scanner.Err()
+}
+
+// processCredentialsTimeout is how long a credential_process may run.
+const processCredentialsTimeout = 1 * time.Minute
+
+// processCredentialsWindow is how long before expiry credentials from a
+// credential_process are fetched again.
+const processCredentialsWindow = 5 * time.Minute
+
+// processCredentials runs the credential_process command of a profile in
+// the shared config file, ~/.aws/config unless another filename is given,
+// and parses the credentials it prints as JSON. They are cached until
+// shortly before they expire.
+type processCredentials struct {
+	Filename string
+	Profile  string
+
+	mu         sync.Mutex
+	creds      *codaws.Credentials
+	expiration time.Time
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+func (p *processCredentials) Credentials() (*codaws.Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.creds != nil && (p.expiration.IsZero() || p.clock().Before(p.expiration.Add(-processCredentialsWindow))) {
+		return p.creds, nil
+	}
+
+	filename := p.filename()
+	profile := profileName(p.Profile)
+	sections, err := parseINIFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("credential_process: %s", err)
+	}
+	// The config file names every profile but the default "profile NAME".
+	section, ok := sections["profile "+profile]
+	if !ok {
+		section, ok = sections[profile]
+	}
+	command := section["credential_process"]
+	if !ok || command == "" {
+		return nil, fmt.Errorf("credential_process: profile %q in %s has no credential_process", profile, filename)
+	}
+
+	log.Printf("[INFO] Running credential_process for profile %s", profile)
+	out, err := runCredentialProcess(command, processCredentialsTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("credential_process: %s", err)
+	}
+
+	var doc struct {
+		Version         int
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		SessionToken    string
+		Expiration      time.Time
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return nil, fmt.Errorf("credential_process: Error decoding the output of %q: %s", command, err)
+	}
+	if doc.Version != 1 {
+		return nil, fmt.Errorf("credential_process: %q printed unsupported Version %d, expected 1", command, doc.Version)
+	}
+	if doc.AccessKeyID == "" || doc.SecretAccessKey == "" {
+		return nil, fmt.Errorf("credential_process: %q printed no AccessKeyId or SecretAccessKey", command)
+	}
+
+	p.creds = &codaws.Credentials{
+		AccessKeyID:     doc.AccessKeyID,
+		SecretAccessKey: doc.SecretAccessKey,
+		SecurityToken:   doc.SessionToken,
+	}
+	p.expiration = doc.Expiration
+	return p.creds, nil
+}
+
+func (p *processCredentials) filename() string {
+	if p.Filename != "" {
+		return p.Filename
+	}
+	if v := os.Getenv("AWS_CONFIG_FILE"); v != "" {
+		return v
+	}
+	return filepath.Join(userHomeDir(), ".aws", "config")
+}
+
+func (p *processCredentials) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// runCredentialProcess runs command through the shell, like the AWS CLI,
+// and returns what it printed on stdout. It is killed if it runs for
+// longer than timeout.
+func runCredentialProcess(command string, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd.exe", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%q did not finish within %s and was killed", command, timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%q failed: %s: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// metadataCredentials fetches the instance profile credentials from the
+// instance metadata service and caches them until shortly before they
+// expire.
+type metadataCredentials struct {
+	Client *metadataClient
+
+	creds      *codaws.Credentials
+	expiration time.Time
+}
+
+// metadataCredentialsWindow is how long before expiry cached instance
+// profile credentials are refreshed.
+const metadataCredentialsWindow = 5 * time.Minute
+
+func (m *metadataCredentials) Credentials() (*codaws.Credentials, error) {
+	if m.creds != nil && time.Now().Before(m.expiration.Add(-metadataCredentialsWindow)) {
+		return m.creds, nil
+	}
+
+	roles, err := m.Client.get("iam/security-credentials/")
+	if err != nil {
+		return nil, fmt.Errorf("instance metadata: %s", err)
+	}
+	role := strings.TrimSpace(strings.SplitN(roles, "\n", 2)[0])
+	if role == "" {
+		return nil, errors.New("instance metadata: no instance profile attached")
+	}
+
+	body, err := m.Client.get("iam/security-credentials/" + role)
+	if err != nil {
+		return nil, fmt.Errorf("instance metadata: %s", err)
+	}
+
+	var doc struct {
+		Code            string
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return nil, fmt.Errorf("instance metadata: Error decoding credentials for %s: %s", role, err)
+	}
+	if doc.Code != "" && doc.Code != "Success" {
+		return nil, fmt.Errorf("instance metadata: credentials for %s returned %s", role, doc.Code)
+	}
+
+	m.creds = &codaws.Credentials{
+		AccessKeyID:     doc.AccessKeyID,
+		SecretAccessKey: doc.SecretAccessKey,
+		SecurityToken:   doc.Token,
+	}
+	m.expiration = doc.Expiration
+	return m.creds, nil
+}
+
+// AssumeRole holds the settings of the provider's assume_role block.
+type AssumeRole struct {
+	RoleARN     string
+	SessionName string
+	ExternalID  string
+	Policy      string
+	Duration    time.Duration
+}
+
+// assumeRoleCredentials exchanges the source credentials for temporary
+// credentials of the configured role, refreshing them before they expire.
+type assumeRoleCredentials struct {
+	Client *sts.STS
+	Role   *AssumeRole
+
+	mu         sync.Mutex
+	creds      *codaws.Credentials
+	expiration time.Time
+}
+
+// assumeRoleCredentialsWindow is how long before expiry the role is
+// assumed again.
+const assumeRoleCredentialsWindow = 1 * time.Minute
+
+func (a *assumeRoleCredentials) Credentials() (*codaws.Credentials, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.creds != nil && time.Now().Before(a.expiration.Add(-assumeRoleCredentialsWindow)) {
+		return a.creds, nil
+	}
+
+	role := a.Role
+	sessionName := role.SessionName
+	if sessionName == "" {
+		sessionName = "terraform-raws"
+	}
+	assumeOpts := &sts.AssumeRoleRequest{
+		RoleARN:         &role.RoleARN,
+		RoleSessionName: &sessionName,
+	}
+	if role.ExternalID != "" {
+		assumeOpts.ExternalID = &role.ExternalID
+	}
+	if role.Policy != "" {
+		assumeOpts.Policy = &role.Policy
+	}
+	if role.Duration > 0 {
+		seconds := int(role.Duration / time.Second)
+		assumeOpts.DurationSeconds = &seconds
+	}
+
+	log.Printf("[INFO] Assuming role %s (session %s)", role.RoleARN, sessionName)
+	resp, err := a.Client.AssumeRole(assumeOpts)
+	if err != nil {
+		return nil, fmt.Errorf("Error assuming role %s: %s", role.RoleARN, err)
+	}
+	if resp.Credentials == nil {
+		return nil, fmt.Errorf("Error assuming role %s: no credentials returned", role.RoleARN)
+	}
+
+	a.creds = &codaws.Credentials{
+		AccessKeyID:     *resp.Credentials.AccessKeyID,
+		SecretAccessKey: *resp.Credentials.SecretAccessKey,
+		SecurityToken:   *resp.Credentials.SessionToken,
+	}
+	a.expiration = resp.Credentials.Expiration
+	return a.creds, nil
+}
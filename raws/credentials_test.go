@@ -0,0 +1,394 @@
+package raws
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSharedCredentials = `
+[default]
+aws_access_key_id = default_key
+aws_secret_access_key = default_secret
+
+# a profile with a session token
+[dev]
+aws_access_key_id = dev_key
+aws_secret_access_key = dev_secret
+aws_session_token = dev_token
+`
+
+func testCredentialsFile(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "raws-creds")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	filename := filepath.Join(dir, "credentials")
+	if err := ioutil.WriteFile(filename, []byte(testSharedCredentials), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return filename
+}
+
+func testMetadataServer(role string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/meta-data/iam/security-credentials/":
+			fmt.Fprintln(w, role)
+		case "/latest/meta-data/iam/security-credentials/" + role:
+			fmt.Fprintf(w, `{
+  "Code": "Success",
+  "AccessKeyId": "metadata_key",
+  "SecretAccessKey": "metadata_secret",
+  "Token": "metadata_token",
+  "Expiration": %q
+}`, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func unsetCredentialsEnv(t *testing.T) func() {
+	keys := []string{
+		"AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY",
+		"AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY",
+		"AWS_SESSION_TOKEN", "AWS_PROFILE", "AWS_SHARED_CREDENTIALS_FILE",
+		"AWS_CONFIG_FILE",
+	}
+	saved := make(map[string]string)
+	for _, k := range keys {
+		saved[k] = os.Getenv(k)
+		os.Unsetenv(k)
+	}
+	return func() {
+		for k, v := range saved {
+			os.Setenv(k, v)
+		}
+	}
+}
+
+func TestSharedCredentials(t *testing.T) {
+	defer unsetCredentialsEnv(t)()
+	filename := testCredentialsFile(t)
+	defer os.RemoveAll(filepath.Dir(filename))
+
+	creds, err := (&sharedCredentials{Filename: filename}).Credentials()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if creds.AccessKeyID != "default_key" || creds.SecretAccessKey != "default_secret" {
+		t.Fatalf("bad: %#v", creds)
+	}
+
+	creds, err = (&sharedCredentials{Filename: filename, Profile: "dev"}).Credentials()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if creds.AccessKeyID != "dev_key" || creds.SecurityToken != "dev_token" {
+		t.Fatalf("bad: %#v", creds)
+	}
+
+	if _, err := (&sharedCredentials{Filename: filename, Profile: "missing"}).Credentials(); err == nil {
+		t.Fatal("expected error for missing profile")
+	}
+}
+
+func TestEnvCredentials(t *testing.T) {
+	defer unsetCredentialsEnv(t)()
+
+	if _, err := (&envCredentials{}).Credentials(); err == nil {
+		t.Fatal("expected error with empty environment")
+	}
+
+	os.Setenv("AWS_ACCESS_KEY_ID", "env_key")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "env_secret")
+	os.Setenv("AWS_SESSION_TOKEN", "env_token")
+	creds, err := (&envCredentials{}).Credentials()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if creds.AccessKeyID != "env_key" || creds.SecretAccessKey != "env_secret" || creds.SecurityToken != "env_token" {
+		t.Fatalf("bad: %#v", creds)
+	}
+}
+
+func TestMetadataCredentials(t *testing.T) {
+	ts := testMetadataServer("test-role")
+	defer ts.Close()
+
+	p := &metadataCredentials{Client: newMetadataClient(ts.URL+"/latest/meta-data/", 0)}
+	creds, err := p.Credentials()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if creds.AccessKeyID != "metadata_key" || creds.SecurityToken != "metadata_token" {
+		t.Fatalf("bad: %#v", creds)
+	}
+}
+
+func TestCredentialsChain_order(t *testing.T) {
+	defer unsetCredentialsEnv(t)()
+	filename := testCredentialsFile(t)
+	defer os.RemoveAll(filepath.Dir(filename))
+	ts := testMetadataServer("test-role")
+	defer ts.Close()
+
+	cases := []struct {
+		Config Config
+		Env    map[string]string
+		Key    string
+	}{
+		{
+			Config{AccessKey: "static_key", SecretKey: "static_secret", CredsFilename: filename},
+			map[string]string{"AWS_ACCESS_KEY_ID": "env_key", "AWS_SECRET_ACCESS_KEY": "env_secret"},
+			"static_key",
+		},
+		{
+			Config{CredsFilename: filename},
+			map[string]string{"AWS_ACCESS_KEY_ID": "env_key", "AWS_SECRET_ACCESS_KEY": "env_secret"},
+			"env_key",
+		},
+		{
+			Config{CredsFilename: filename, Profile: "dev"},
+			nil,
+			"dev_key",
+		},
+		{
+			Config{
+				CredsFilename:    filepath.Join(filepath.Dir(filename), "missing"),
+				ConfigFilename:   filepath.Join(filepath.Dir(filename), "missing"),
+				MetadataEndpoint: ts.URL + "/latest/meta-data/",
+			},
+			nil,
+			"metadata_key",
+		},
+	}
+
+	for i, tc := range cases {
+		for k, v := range tc.Env {
+			os.Setenv(k, v)
+		}
+
+		creds, err := tc.Config.credentialsChain().Credentials()
+		if err != nil {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+		if creds.AccessKeyID != tc.Key {
+			t.Fatalf("%d: expected %s, got %s", i, tc.Key, creds.AccessKeyID)
+		}
+
+		for k := range tc.Env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestCredentialsChain_token(t *testing.T) {
+	defer unsetCredentialsEnv(t)()
+
+	c := Config{AccessKey: "static_key", SecretKey: "static_secret", Token: "static_token"}
+	creds, err := c.credentialsChain().Credentials()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if creds.SecurityToken != "static_token" {
+		t.Fatalf("bad: %#v", creds)
+	}
+}
+
+func TestProcessCredentials(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test credential process is a shell script")
+	}
+	defer unsetCredentialsEnv(t)()
+
+	dir, err := ioutil.TempDir("", "raws-process")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The script counts its runs and prints credentials that expire at a
+	// fixed time.
+	expiration := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	runs := filepath.Join(dir, "runs")
+	script := filepath.Join(dir, "creds.sh")
+	err = ioutil.WriteFile(script, []byte(fmt.Sprintf(`echo run >> %s
+echo '{"Version": 1, "AccessKeyId": "process_key", "SecretAccessKey": "process_secret",'
+echo ' "SessionToken": "process_token", "Expiration": %q}'
+`, runs, expiration.Format(time.RFC3339))), 0700)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	config := filepath.Join(dir, "config")
+	err = ioutil.WriteFile(config, []byte(fmt.Sprintf(`[default]
+region = us-east-1
+
+[profile sso]
+credential_process = sh %s
+
+[profile broken]
+credential_process = echo token expired >&2; exit 1
+
+[profile future]
+credential_process = echo '{"Version": 2}'
+`, script)), 0600)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	clock := expiration.Add(-time.Hour)
+	p := &processCredentials{Filename: config, Profile: "sso", now: func() time.Time { return clock }}
+	countRuns := func() int {
+		data, _ := ioutil.ReadFile(runs)
+		return strings.Count(string(data), "run")
+	}
+
+	for i := 0; i < 2; i++ {
+		creds, err := p.Credentials()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if creds.AccessKeyID != "process_key" || creds.SecurityToken != "process_token" {
+			t.Fatalf("bad: %#v", creds)
+		}
+	}
+	if n := countRuns(); n != 1 {
+		t.Fatalf("expected the credentials to be cached, the process ran %d times", n)
+	}
+
+	// Shortly before they expire the process runs again.
+	clock = expiration.Add(-time.Minute)
+	if _, err := p.Credentials(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n := countRuns(); n != 2 {
+		t.Fatalf("expected the credentials to be refreshed, the process ran %d times", n)
+	}
+
+	cases := map[string]string{
+		"broken":  "token expired",
+		"future":  "unsupported Version 2",
+		"default": "has no credential_process",
+		"missing": "has no credential_process",
+	}
+	for profile, expected := range cases {
+		_, err := (&processCredentials{Filename: config, Profile: profile}).Credentials()
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: expected %q, got: %v", profile, expected, err)
+		}
+	}
+}
+
+func TestRunCredentialProcess_timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test credential process is a shell command")
+	}
+
+	start := time.Now()
+	_, err := runCredentialProcess("exec sleep 10", 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "did not finish within 100ms") {
+		t.Fatalf("expected a timeout error, got: %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("the process was not killed, it ran for %s", d)
+	}
+
+	// A failing process is not reported as a timeout
+	_, err = runCredentialProcess("exit 3", time.Minute)
+	if err == nil || strings.Contains(err.Error(), "did not finish") || !strings.Contains(err.Error(), "exit status 3") {
+		t.Fatalf("expected the exit status, got: %v", err)
+	}
+}
+
+func TestCredentialsChain_noSources(t *testing.T) {
+	defer unsetCredentialsEnv(t)()
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	c := Config{
+		CredsFilename:    filepath.Join(os.TempDir(), "raws-no-such-file"),
+		MetadataEndpoint: ts.URL,
+	}
+	if _, err := c.credentialsChain().Credentials(); err == nil {
+		t.Fatal("expected error when no credential source is available")
+	}
+}
+
+const testAssumeRoleResponse = `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <AssumedRoleUser>
+      <Arn>arn:aws:sts::123456789012:assumed-role/test/terraform-raws</Arn>
+      <AssumedRoleId>AROAEXAMPLE:terraform-raws</AssumedRoleId>
+    </AssumedRoleUser>
+    <Credentials>
+      <AccessKeyId>role_key</AccessKeyId>
+      <SecretAccessKey>role_secret</SecretAccessKey>
+      <SessionToken>role_token</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+  <ResponseMetadata>
+    <RequestId>c6104cbe-af31-11e0-8154-cbc7ccf896c7</RequestId>
+  </ResponseMetadata>
+</AssumeRoleResponse>`
+
+func TestAssumeRoleCredentials(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Action") != "AssumeRole" {
+			t.Errorf("bad action: %s", r.Form.Get("Action"))
+		}
+		if r.Form.Get("RoleArn") != "arn:aws:iam::123456789012:role/test" {
+			t.Errorf("bad role: %s", r.Form.Get("RoleArn"))
+		}
+		if r.Form.Get("ExternalId") != "ext" {
+			t.Errorf("bad external id: %s", r.Form.Get("ExternalId"))
+		}
+		if r.Form.Get("DurationSeconds") != "900" {
+			t.Errorf("bad duration: %s", r.Form.Get("DurationSeconds"))
+		}
+		calls++
+		fmt.Fprintf(w, testAssumeRoleResponse, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer ts.Close()
+
+	c := Config{
+		AccessKey: "source_key",
+		SecretKey: "source_secret",
+		Region:    "us-east-1",
+		Endpoints: map[string]string{"sts": ts.URL},
+		AssumeRole: &AssumeRole{
+			RoleARN:    "arn:aws:iam::123456789012:role/test",
+			ExternalID: "ext",
+			Duration:   15 * time.Minute,
+		},
+	}
+	p, err := c.assumeRoleCredentials(c.credentialsChain())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		creds, err := p.Credentials()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if creds.AccessKeyID != "role_key" || creds.SecurityToken != "role_token" {
+			t.Fatalf("bad: %#v", creds)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected temporary credentials to be cached, got %d AssumeRole calls", calls)
+	}
+}
@@ -0,0 +1,417 @@
+package raws
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+)
+
+// dryRunEC2 sends every request that changes infrastructure with DryRun
+// set before sending it for real, so a missing IAM permission fails the
+// operation before anything has been changed (validate_permissions). The
+// dry run is the real request, so resource and tag conditions in IAM
+// policies are checked as well.
+//
+// A request whose dry run has passed isn't dry-run again, so retrying it
+// costs no more than without validate_permissions. ModifyVpcAttribute,
+// ModifySubnetAttribute and the Associate and Disassociate calls for VPC
+// and subnet CIDR blocks don't support DryRun and are passed straight
+// through.
+type dryRunEC2 struct {
+	EC2API
+
+	mu     sync.Mutex
+	passed map[string]bool
+}
+
+func newDryRunEC2(conn EC2API) *dryRunEC2 {
+	return &dryRunEC2{
+		EC2API: conn,
+		passed: make(map[string]bool),
+	}
+}
+
+// dryRun checks req for action by calling send, which sends a copy of it
+// with DryRun set, unless the same request has already passed.
+func (c *dryRunEC2) dryRun(action string, req interface{}, send func() error) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("Error validating permissions for ec2:%s: %s", action, err)
+	}
+	key := action + " " + string(body)
+
+	c.mu.Lock()
+	passed := c.passed[key]
+	c.mu.Unlock()
+	if passed {
+		return nil
+	}
+
+	if err := dryRunResult(action, send()); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.passed[key] = true
+	c.mu.Unlock()
+	return nil
+}
+
+// checkDryRunSupport makes sure the endpoint honours DryRun, with a call
+// that changes nothing. An endpoint that ignored it would carry out every
+// request twice.
+func checkDryRunSupport(conn EC2API) error {
+	_, err := conn.DescribeVPCs(&ec2.DescribeVPCsRequest{DryRun: dryRunFlag()})
+	return dryRunResult("DescribeVpcs", err)
+}
+
+// dryRunResult turns the error of a dry run into the error the operation
+// should fail with, or nil if the request would have succeeded.
+func dryRunResult(action string, err error) error {
+	if err == nil {
+		// The endpoint ignored DryRun and performed the request.
+		return fmt.Errorf("Error validating permissions for ec2:%s: the endpoint does not support DryRun; "+
+			"disable validate_permissions", action)
+	}
+
+	ec2err, ok := err.(*codaws.APIError)
+	if !ok {
+		return err
+	}
+	switch ec2err.Code {
+	case "DryRunOperation":
+		log.Printf("[DEBUG] Permission check for ec2:%s passed", action)
+		return nil
+	case "UnauthorizedOperation":
+		return fmt.Errorf("Missing IAM permission: the provider's credentials are not allowed to call ec2:%s. "+
+			"Grant it to the IAM user or role and try again. (%s)", action, ec2err.Message)
+	}
+	return err
+}
+
+func dryRunFlag() codaws.BooleanValue {
+	dryRun := true
+	return &dryRun
+}
+
+func (c *dryRunEC2) CreateVPC(req *ec2.CreateVPCRequest) (*ec2.CreateVPCResult, error) {
+	err := c.dryRun("CreateVpc", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		_, err := c.EC2API.CreateVPC(&dry)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.EC2API.CreateVPC(req)
+}
+
+func (c *dryRunEC2) DeleteVPC(req *ec2.DeleteVPCRequest) error {
+	err := c.dryRun("DeleteVpc", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.DeleteVPC(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.DeleteVPC(req)
+}
+
+func (c *dryRunEC2) CreateSubnet(req *ec2.CreateSubnetRequest) (*ec2.CreateSubnetResult, error) {
+	err := c.dryRun("CreateSubnet", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		_, err := c.EC2API.CreateSubnet(&dry)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.EC2API.CreateSubnet(req)
+}
+
+func (c *dryRunEC2) DeleteSubnet(req *ec2.DeleteSubnetRequest) error {
+	err := c.dryRun("DeleteSubnet", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.DeleteSubnet(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.DeleteSubnet(req)
+}
+
+func (c *dryRunEC2) CreateRouteTable(req *ec2.CreateRouteTableRequest) (*ec2.CreateRouteTableResult, error) {
+	err := c.dryRun("CreateRouteTable", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		_, err := c.EC2API.CreateRouteTable(&dry)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.EC2API.CreateRouteTable(req)
+}
+
+func (c *dryRunEC2) DeleteRouteTable(req *ec2.DeleteRouteTableRequest) error {
+	err := c.dryRun("DeleteRouteTable", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.DeleteRouteTable(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.DeleteRouteTable(req)
+}
+
+func (c *dryRunEC2) CreateRoute(req *ec2.CreateRouteRequest) error {
+	err := c.dryRun("CreateRoute", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.CreateRoute(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.CreateRoute(req)
+}
+
+func (c *dryRunEC2) DeleteRoute(req *ec2.DeleteRouteRequest) error {
+	err := c.dryRun("DeleteRoute", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.DeleteRoute(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.DeleteRoute(req)
+}
+
+func (c *dryRunEC2) CreateIPv6Route(req *createIPv6RouteRequest) error {
+	err := c.dryRun("CreateRoute", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.CreateIPv6Route(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.CreateIPv6Route(req)
+}
+
+func (c *dryRunEC2) DeleteIPv6Route(req *deleteIPv6RouteRequest) error {
+	err := c.dryRun("DeleteRoute", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.DeleteIPv6Route(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.DeleteIPv6Route(req)
+}
+
+func (c *dryRunEC2) AssociateRouteTable(req *ec2.AssociateRouteTableRequest) (*ec2.AssociateRouteTableResult, error) {
+	err := c.dryRun("AssociateRouteTable", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		_, err := c.EC2API.AssociateRouteTable(&dry)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.EC2API.AssociateRouteTable(req)
+}
+
+func (c *dryRunEC2) ReplaceRouteTableAssociation(req *ec2.ReplaceRouteTableAssociationRequest) (*ec2.ReplaceRouteTableAssociationResult, error) {
+	err := c.dryRun("ReplaceRouteTableAssociation", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		_, err := c.EC2API.ReplaceRouteTableAssociation(&dry)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.EC2API.ReplaceRouteTableAssociation(req)
+}
+
+func (c *dryRunEC2) DisassociateRouteTable(req *ec2.DisassociateRouteTableRequest) error {
+	err := c.dryRun("DisassociateRouteTable", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.DisassociateRouteTable(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.DisassociateRouteTable(req)
+}
+
+func (c *dryRunEC2) CreateInternetGateway(req *ec2.CreateInternetGatewayRequest) (*ec2.CreateInternetGatewayResult, error) {
+	err := c.dryRun("CreateInternetGateway", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		_, err := c.EC2API.CreateInternetGateway(&dry)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.EC2API.CreateInternetGateway(req)
+}
+
+func (c *dryRunEC2) AttachInternetGateway(req *ec2.AttachInternetGatewayRequest) error {
+	err := c.dryRun("AttachInternetGateway", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.AttachInternetGateway(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.AttachInternetGateway(req)
+}
+
+func (c *dryRunEC2) DetachInternetGateway(req *ec2.DetachInternetGatewayRequest) error {
+	err := c.dryRun("DetachInternetGateway", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.DetachInternetGateway(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.DetachInternetGateway(req)
+}
+
+func (c *dryRunEC2) DeleteInternetGateway(req *ec2.DeleteInternetGatewayRequest) error {
+	err := c.dryRun("DeleteInternetGateway", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.DeleteInternetGateway(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.DeleteInternetGateway(req)
+}
+
+func (c *dryRunEC2) CreateSecurityGroup(req *ec2.CreateSecurityGroupRequest) (*ec2.CreateSecurityGroupResult, error) {
+	err := c.dryRun("CreateSecurityGroup", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		_, err := c.EC2API.CreateSecurityGroup(&dry)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.EC2API.CreateSecurityGroup(req)
+}
+
+func (c *dryRunEC2) AuthorizeSecurityGroupIngress(req *ec2.AuthorizeSecurityGroupIngressRequest) error {
+	err := c.dryRun("AuthorizeSecurityGroupIngress", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.AuthorizeSecurityGroupIngress(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.AuthorizeSecurityGroupIngress(req)
+}
+
+func (c *dryRunEC2) RevokeSecurityGroupIngress(req *ec2.RevokeSecurityGroupIngressRequest) error {
+	err := c.dryRun("RevokeSecurityGroupIngress", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.RevokeSecurityGroupIngress(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.RevokeSecurityGroupIngress(req)
+}
+
+func (c *dryRunEC2) RevokeSecurityGroupEgress(req *ec2.RevokeSecurityGroupEgressRequest) error {
+	err := c.dryRun("RevokeSecurityGroupEgress", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.RevokeSecurityGroupEgress(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.RevokeSecurityGroupEgress(req)
+}
+
+func (c *dryRunEC2) DeleteSecurityGroup(req *ec2.DeleteSecurityGroupRequest) error {
+	err := c.dryRun("DeleteSecurityGroup", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.DeleteSecurityGroup(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.DeleteSecurityGroup(req)
+}
+
+func (c *dryRunEC2) DetachNetworkInterface(req *ec2.DetachNetworkInterfaceRequest) error {
+	err := c.dryRun("DetachNetworkInterface", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.DetachNetworkInterface(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.DetachNetworkInterface(req)
+}
+
+func (c *dryRunEC2) DeleteNetworkInterface(req *ec2.DeleteNetworkInterfaceRequest) error {
+	err := c.dryRun("DeleteNetworkInterface", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.DeleteNetworkInterface(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.DeleteNetworkInterface(req)
+}
+
+func (c *dryRunEC2) CreateTags(req *ec2.CreateTagsRequest) error {
+	err := c.dryRun("CreateTags", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.CreateTags(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.CreateTags(req)
+}
+
+func (c *dryRunEC2) DeleteTags(req *ec2.DeleteTagsRequest) error {
+	err := c.dryRun("DeleteTags", req, func() error {
+		dry := *req
+		dry.DryRun = dryRunFlag()
+		return c.EC2API.DeleteTags(&dry)
+	})
+	if err != nil {
+		return err
+	}
+	return c.EC2API.DeleteTags(req)
+}
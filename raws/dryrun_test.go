@@ -0,0 +1,153 @@
+package raws
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+)
+
+func TestDryRunEC2(t *testing.T) {
+	m := &mockEC2{}
+	conn := newDryRunEC2(m)
+
+	if _, err := conn.CreateVPC(&ec2.CreateVPCRequest{CIDRBlock: codaws.String("10.0.0.0/16")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := conn.DeleteSubnet(&ec2.DeleteSubnetRequest{SubnetID: codaws.String("subnet-1")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		"DryRun CreateVPC 10.0.0.0/16",
+		"CreateVPC 10.0.0.0/16",
+		"DryRun DeleteSubnet subnet-1",
+		"DeleteSubnet subnet-1",
+	}
+	if !reflect.DeepEqual(m.calls, expected) {
+		t.Fatalf("bad calls: %#v", m.calls)
+	}
+}
+
+func TestDryRunEC2_retry(t *testing.T) {
+	m := &mockEC2{
+		errs: map[string]error{
+			"DeleteSubnet subnet-1": &codaws.APIError{Code: "DependencyViolation"},
+		},
+	}
+	conn := newDryRunEC2(m)
+
+	// A retry of a request that passed its dry run is sent as is
+	if err := conn.DeleteSubnet(&ec2.DeleteSubnetRequest{SubnetID: codaws.String("subnet-1")}); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := conn.DeleteSubnet(&ec2.DeleteSubnetRequest{SubnetID: codaws.String("subnet-1")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// Another request is checked on its own
+	if err := conn.DeleteSubnet(&ec2.DeleteSubnetRequest{SubnetID: codaws.String("subnet-2")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		"DryRun DeleteSubnet subnet-1",
+		"DeleteSubnet subnet-1",
+		"DeleteSubnet subnet-1",
+		"DryRun DeleteSubnet subnet-2",
+		"DeleteSubnet subnet-2",
+	}
+	if !reflect.DeepEqual(m.calls, expected) {
+		t.Fatalf("bad calls: %#v", m.calls)
+	}
+}
+
+func TestDryRunEC2_unauthorized(t *testing.T) {
+	m := &mockEC2{
+		errs: map[string]error{
+			"DryRun CreateSubnet": &codaws.APIError{
+				Code:    "UnauthorizedOperation",
+				Message: "You are not authorized to perform this operation.",
+			},
+		},
+	}
+	conn := newDryRunEC2(m)
+
+	_, err := conn.CreateSubnet(&ec2.CreateSubnetRequest{
+		VPCID:     codaws.String("vpc-1"),
+		CIDRBlock: codaws.String("10.0.1.0/24"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "ec2:CreateSubnet") {
+		t.Fatalf("expected a missing permission error, got: %v", err)
+	}
+
+	// Nothing is sent for real, and the next attempt is checked again
+	if _, err := conn.CreateSubnet(&ec2.CreateSubnetRequest{
+		VPCID:     codaws.String("vpc-1"),
+		CIDRBlock: codaws.String("10.0.1.0/24"),
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		"DryRun CreateSubnet vpc-1 10.0.1.0/24",
+		"DryRun CreateSubnet vpc-1 10.0.1.0/24",
+		"CreateSubnet vpc-1 10.0.1.0/24",
+	}
+	if !reflect.DeepEqual(m.calls, expected) {
+		t.Fatalf("bad calls: %#v", m.calls)
+	}
+}
+
+// noDryRunEC2 answers DescribeVPCs like an endpoint that ignores DryRun.
+type noDryRunEC2 struct {
+	*mockEC2
+}
+
+func (c noDryRunEC2) DescribeVPCs(req *ec2.DescribeVPCsRequest) (*ec2.DescribeVPCsResult, error) {
+	c.call("DescribeVPCs")
+	return &ec2.DescribeVPCsResult{}, nil
+}
+
+func TestCheckDryRunSupport(t *testing.T) {
+	m := &mockEC2{}
+	if err := checkDryRunSupport(m); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(m.calls, []string{"DryRun DescribeVPCs "}) {
+		t.Fatalf("bad calls: %#v", m.calls)
+	}
+
+	err := checkDryRunSupport(noDryRunEC2{&mockEC2{}})
+	if err == nil || !strings.Contains(err.Error(), "does not support DryRun") {
+		t.Fatalf("expected a DryRun support error, got: %v", err)
+	}
+}
+
+func TestDryRunResult(t *testing.T) {
+	cases := []struct {
+		Err    error
+		ErrMsg string
+	}{
+		{&codaws.APIError{Code: "DryRunOperation"}, ""},
+		{&codaws.APIError{Code: "UnauthorizedOperation"}, "not allowed to call ec2:CreateVpc"},
+		// The request would fail for another reason
+		{&codaws.APIError{Code: "InvalidVpcRange", Message: "bad range"}, "bad range"},
+		// The endpoint ignored DryRun and performed the request.
+		{nil, "does not support DryRun"},
+	}
+
+	for i, tc := range cases {
+		err := dryRunResult("CreateVpc", tc.Err)
+		if tc.ErrMsg == "" {
+			if err != nil {
+				t.Fatalf("%d: err: %s", i, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.ErrMsg) {
+			t.Fatalf("%d: expected %q, got: %v", i, tc.ErrMsg, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package raws
+
+import (
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+)
+
+// EC2API lists the EC2 operations the provider uses. AWSClient holds the
+// connection as an EC2API rather than a *ec2.EC2 so resource logic can be
+// unit tested against a mock. Add a method here before calling it from a
+// resource; calls the generated client doesn't have go in ec2query.go.
+type EC2API interface {
+	// VPCs
+	CreateVPC(*ec2.CreateVPCRequest) (*ec2.CreateVPCResult, error)
+	DescribeVPCs(*ec2.DescribeVPCsRequest) (*ec2.DescribeVPCsResult, error)
+	DescribeVPCAttribute(*ec2.DescribeVPCAttributeRequest) (*ec2.DescribeVPCAttributeResult, error)
+	ModifyVPCAttribute(*ec2.ModifyVPCAttributeRequest) error
+	DeleteVPC(*ec2.DeleteVPCRequest) error
+	AssociateVPCCIDRBlock(*associateVPCCIDRBlockRequest) (*associateVPCCIDRBlockResult, error)
+	DisassociateVPCCIDRBlock(*disassociateVPCCIDRBlockRequest) (*disassociateVPCCIDRBlockResult, error)
+	DescribeVPCCIDRBlocks(*describeVPCCIDRBlocksRequest) (*describeVPCCIDRBlocksResult, error)
+
+	// Subnets
+	CreateSubnet(*ec2.CreateSubnetRequest) (*ec2.CreateSubnetResult, error)
+	DescribeSubnets(*ec2.DescribeSubnetsRequest) (*ec2.DescribeSubnetsResult, error)
+	ModifySubnetAttribute(*ec2.ModifySubnetAttributeRequest) error
+	DeleteSubnet(*ec2.DeleteSubnetRequest) error
+	DescribeSubnetIPv6(*describeSubnetIPv6Request) (*describeSubnetIPv6Result, error)
+	AssociateSubnetCIDRBlock(*associateSubnetCIDRBlockRequest) (*associateSubnetCIDRBlockResult, error)
+	DisassociateSubnetCIDRBlock(*disassociateSubnetCIDRBlockRequest) (*disassociateSubnetCIDRBlockResult, error)
+	ModifySubnetIPv6Attribute(*modifySubnetIPv6AttributeRequest) error
+
+	// Route tables
+	CreateRouteTable(*ec2.CreateRouteTableRequest) (*ec2.CreateRouteTableResult, error)
+	DescribeRouteTables(*ec2.DescribeRouteTablesRequest) (*ec2.DescribeRouteTablesResult, error)
+	DeleteRouteTable(*ec2.DeleteRouteTableRequest) error
+	CreateRoute(*ec2.CreateRouteRequest) error
+	DeleteRoute(*ec2.DeleteRouteRequest) error
+	AssociateRouteTable(*ec2.AssociateRouteTableRequest) (*ec2.AssociateRouteTableResult, error)
+	ReplaceRouteTableAssociation(*ec2.ReplaceRouteTableAssociationRequest) (*ec2.ReplaceRouteTableAssociationResult, error)
+	DisassociateRouteTable(*ec2.DisassociateRouteTableRequest) error
+	DescribeIPv6Routes(*describeIPv6RoutesRequest) (*describeIPv6RoutesResult, error)
+	CreateIPv6Route(*createIPv6RouteRequest) error
+	DeleteIPv6Route(*deleteIPv6RouteRequest) error
+
+	// Network ACLs
+	DescribeNetworkACLs(*ec2.DescribeNetworkACLsRequest) (*ec2.DescribeNetworkACLsResult, error)
+
+	// Internet gateways
+	CreateInternetGateway(*ec2.CreateInternetGatewayRequest) (*ec2.CreateInternetGatewayResult, error)
+	DescribeInternetGateways(*ec2.DescribeInternetGatewaysRequest) (*ec2.DescribeInternetGatewaysResult, error)
+	AttachInternetGateway(*ec2.AttachInternetGatewayRequest) error
+	DetachInternetGateway(*ec2.DetachInternetGatewayRequest) error
+	DeleteInternetGateway(*ec2.DeleteInternetGatewayRequest) error
+
+	// Security groups
+	CreateSecurityGroup(*ec2.CreateSecurityGroupRequest) (*ec2.CreateSecurityGroupResult, error)
+	DescribeSecurityGroups(*ec2.DescribeSecurityGroupsRequest) (*ec2.DescribeSecurityGroupsResult, error)
+	AuthorizeSecurityGroupIngress(*ec2.AuthorizeSecurityGroupIngressRequest) error
+	RevokeSecurityGroupIngress(*ec2.RevokeSecurityGroupIngressRequest) error
+	RevokeSecurityGroupEgress(*ec2.RevokeSecurityGroupEgressRequest) error
+	DeleteSecurityGroup(*ec2.DeleteSecurityGroupRequest) error
+
+	// Network interfaces
+	DescribeNetworkInterfaces(*ec2.DescribeNetworkInterfacesRequest) (*ec2.DescribeNetworkInterfacesResult, error)
+	DetachNetworkInterface(*ec2.DetachNetworkInterfaceRequest) error
+	DeleteNetworkInterface(*ec2.DeleteNetworkInterfaceRequest) error
+
+	// Tags
+	CreateTags(*ec2.CreateTagsRequest) error
+	DeleteTags(*ec2.DeleteTagsRequest) error
+}
+
+var _ EC2API = (*ec2Conn)(nil)
@@ -0,0 +1,325 @@
+package raws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+)
+
+// mockEC2 records the EC2 calls made through it as short strings, e.g.
+// "CreateRoute 10.0.0.0/16 igw-1". Calls it doesn't implement panic on
+// the nil embedded EC2API.
+type mockEC2 struct {
+	EC2API
+
+	calls []string
+
+	// errs fails the first call whose recorded string starts with the key.
+	errs map[string]error
+
+	vpcs              []ec2.VPC
+	internetGateways  []ec2.InternetGateway
+	routeTables       []ec2.RouteTable
+	networkInterfaces []ec2.NetworkInterface
+	securityGroups    []ec2.SecurityGroup
+	subnets           []ec2.Subnet
+}
+
+func (m *mockEC2) call(format string, args ...interface{}) error {
+	c := fmt.Sprintf(format, args...)
+	m.calls = append(m.calls, c)
+	for prefix, err := range m.errs {
+		if strings.HasPrefix(c, prefix) {
+			delete(m.errs, prefix)
+			return err
+		}
+	}
+	return nil
+}
+
+// callDryRun records a call made with DryRun set as "DryRun <call>" and
+// answers it the way EC2 does when the call would have succeeded.
+func (m *mockEC2) callDryRun(dryRun codaws.BooleanValue, format string, args ...interface{}) error {
+	if dryRun == nil || !*dryRun {
+		return m.call(format, args...)
+	}
+	if err := m.call("DryRun "+format, args...); err != nil {
+		return err
+	}
+	return &codaws.APIError{Code: "DryRunOperation"}
+}
+
+func (m *mockEC2) CreateVPC(req *ec2.CreateVPCRequest) (*ec2.CreateVPCResult, error) {
+	if err := m.callDryRun(req.DryRun, "CreateVPC %s", *req.CIDRBlock); err != nil {
+		return nil, err
+	}
+	return &ec2.CreateVPCResult{}, nil
+}
+
+func (m *mockEC2) DescribeVPCs(req *ec2.DescribeVPCsRequest) (*ec2.DescribeVPCsResult, error) {
+	if err := m.callDryRun(req.DryRun, "DescribeVPCs %s", strings.Join(req.VPCIDs, ",")); err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeVPCsResult{VPCs: m.vpcs}, nil
+}
+
+func (m *mockEC2) DeleteVPC(req *ec2.DeleteVPCRequest) error {
+	return m.callDryRun(req.DryRun, "DeleteVPC %s", *req.VPCID)
+}
+
+func (m *mockEC2) CreateSubnet(req *ec2.CreateSubnetRequest) (*ec2.CreateSubnetResult, error) {
+	if err := m.callDryRun(req.DryRun, "CreateSubnet %s %s", *req.VPCID, *req.CIDRBlock); err != nil {
+		return nil, err
+	}
+	return &ec2.CreateSubnetResult{}, nil
+}
+
+func (m *mockEC2) CreateRouteTable(req *ec2.CreateRouteTableRequest) (*ec2.CreateRouteTableResult, error) {
+	if err := m.callDryRun(req.DryRun, "CreateRouteTable %s", *req.VPCID); err != nil {
+		return nil, err
+	}
+	return &ec2.CreateRouteTableResult{}, nil
+}
+
+func (m *mockEC2) AssociateRouteTable(req *ec2.AssociateRouteTableRequest) (*ec2.AssociateRouteTableResult, error) {
+	if err := m.callDryRun(req.DryRun, "AssociateRouteTable %s %s", *req.RouteTableID, *req.SubnetID); err != nil {
+		return nil, err
+	}
+	return &ec2.AssociateRouteTableResult{}, nil
+}
+
+func (m *mockEC2) ReplaceRouteTableAssociation(req *ec2.ReplaceRouteTableAssociationRequest) (*ec2.ReplaceRouteTableAssociationResult, error) {
+	if err := m.callDryRun(req.DryRun, "ReplaceRouteTableAssociation %s %s", *req.AssociationID, *req.RouteTableID); err != nil {
+		return nil, err
+	}
+	return &ec2.ReplaceRouteTableAssociationResult{}, nil
+}
+
+func (m *mockEC2) CreateInternetGateway(req *ec2.CreateInternetGatewayRequest) (*ec2.CreateInternetGatewayResult, error) {
+	if err := m.callDryRun(req.DryRun, "CreateInternetGateway"); err != nil {
+		return nil, err
+	}
+	return &ec2.CreateInternetGatewayResult{}, nil
+}
+
+func (m *mockEC2) AttachInternetGateway(req *ec2.AttachInternetGatewayRequest) error {
+	return m.callDryRun(req.DryRun, "AttachInternetGateway %s %s", *req.InternetGatewayID, *req.VPCID)
+}
+
+func (m *mockEC2) CreateSecurityGroup(req *ec2.CreateSecurityGroupRequest) (*ec2.CreateSecurityGroupResult, error) {
+	if err := m.callDryRun(req.DryRun, "CreateSecurityGroup %s %s", *req.VPCID, *req.GroupName); err != nil {
+		return nil, err
+	}
+	return &ec2.CreateSecurityGroupResult{}, nil
+}
+
+func (m *mockEC2) CreateTags(req *ec2.CreateTagsRequest) error {
+	return m.callDryRun(req.DryRun, "CreateTags %s %s", strings.Join(req.Resources, ","), tagsDebugString(req.Tags))
+}
+
+func (m *mockEC2) DeleteTags(req *ec2.DeleteTagsRequest) error {
+	return m.callDryRun(req.DryRun, "DeleteTags %s %s", strings.Join(req.Resources, ","), tagsDebugString(req.Tags))
+}
+
+func (m *mockEC2) DescribeInternetGateways(req *ec2.DescribeInternetGatewaysRequest) (*ec2.DescribeInternetGatewaysResult, error) {
+	if err := m.call("DescribeInternetGateways"); err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeInternetGatewaysResult{InternetGateways: m.internetGateways}, nil
+}
+
+func (m *mockEC2) DetachInternetGateway(req *ec2.DetachInternetGatewayRequest) error {
+	return m.callDryRun(req.DryRun, "DetachInternetGateway %s %s", *req.InternetGatewayID, *req.VPCID)
+}
+
+// DeleteInternetGateway removes the gateway from the ones described.
+func (m *mockEC2) DeleteInternetGateway(req *ec2.DeleteInternetGatewayRequest) error {
+	if err := m.callDryRun(req.DryRun, "DeleteInternetGateway %s", *req.InternetGatewayID); err != nil {
+		return err
+	}
+	var igws []ec2.InternetGateway
+	for _, igw := range m.internetGateways {
+		if *igw.InternetGatewayID != *req.InternetGatewayID {
+			igws = append(igws, igw)
+		}
+	}
+	m.internetGateways = igws
+	return nil
+}
+
+func (m *mockEC2) DescribeRouteTables(req *ec2.DescribeRouteTablesRequest) (*ec2.DescribeRouteTablesResult, error) {
+	if err := m.call("DescribeRouteTables"); err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeRouteTablesResult{RouteTables: m.routeTables}, nil
+}
+
+func (m *mockEC2) DisassociateRouteTable(req *ec2.DisassociateRouteTableRequest) error {
+	return m.callDryRun(req.DryRun, "DisassociateRouteTable %s", *req.AssociationID)
+}
+
+func (m *mockEC2) DeleteRouteTable(req *ec2.DeleteRouteTableRequest) error {
+	return m.callDryRun(req.DryRun, "DeleteRouteTable %s", *req.RouteTableID)
+}
+
+// DescribeNetworkInterfaces answers with the interfaces whose IDs are
+// asked for, or all of them.
+func (m *mockEC2) DescribeNetworkInterfaces(req *ec2.DescribeNetworkInterfacesRequest) (*ec2.DescribeNetworkInterfacesResult, error) {
+	if err := m.call("DescribeNetworkInterfaces %s", strings.Join(req.NetworkInterfaceIDs, ",")); err != nil {
+		return nil, err
+	}
+	if len(req.NetworkInterfaceIDs) == 0 {
+		return &ec2.DescribeNetworkInterfacesResult{NetworkInterfaces: m.networkInterfaces}, nil
+	}
+	result := &ec2.DescribeNetworkInterfacesResult{}
+	for _, eni := range m.networkInterfaces {
+		for _, id := range req.NetworkInterfaceIDs {
+			if *eni.NetworkInterfaceID == id {
+				result.NetworkInterfaces = append(result.NetworkInterfaces, eni)
+			}
+		}
+	}
+	return result, nil
+}
+
+// DetachNetworkInterface makes the interface with the attachment
+// available at once. Like EC2, it refuses to detach the primary interface
+// of an instance.
+func (m *mockEC2) DetachNetworkInterface(req *ec2.DetachNetworkInterfaceRequest) error {
+	if err := m.callDryRun(req.DryRun, "DetachNetworkInterface %s", *req.AttachmentID); err != nil {
+		return err
+	}
+	available := "available"
+	for i, eni := range m.networkInterfaces {
+		if eni.Attachment != nil && *eni.Attachment.AttachmentID == *req.AttachmentID {
+			if isPrimaryNetworkInterface(eni) {
+				return &codaws.APIError{
+					Code:    "OperationNotPermitted",
+					Message: "The network interface at device index 0 cannot be detached.",
+				}
+			}
+			m.networkInterfaces[i].Status = &available
+		}
+	}
+	return nil
+}
+
+func (m *mockEC2) DeleteNetworkInterface(req *ec2.DeleteNetworkInterfaceRequest) error {
+	return m.callDryRun(req.DryRun, "DeleteNetworkInterface %s", *req.NetworkInterfaceID)
+}
+
+func (m *mockEC2) DescribeSecurityGroups(req *ec2.DescribeSecurityGroupsRequest) (*ec2.DescribeSecurityGroupsResult, error) {
+	if err := m.call("DescribeSecurityGroups"); err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeSecurityGroupsResult{SecurityGroups: m.securityGroups}, nil
+}
+
+func (m *mockEC2) DeleteSecurityGroup(req *ec2.DeleteSecurityGroupRequest) error {
+	return m.callDryRun(req.DryRun, "DeleteSecurityGroup %s", *req.GroupID)
+}
+
+func (m *mockEC2) DescribeSubnets(req *ec2.DescribeSubnetsRequest) (*ec2.DescribeSubnetsResult, error) {
+	if err := m.call("DescribeSubnets"); err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeSubnetsResult{Subnets: m.subnets}, nil
+}
+
+func (m *mockEC2) ModifySubnetAttribute(req *ec2.ModifySubnetAttributeRequest) error {
+	return m.call("ModifySubnetAttribute %s MapPublicIpOnLaunch=%t", *req.SubnetID, *req.MapPublicIPOnLaunch.Value)
+}
+
+func (m *mockEC2) DeleteSubnet(req *ec2.DeleteSubnetRequest) error {
+	return m.callDryRun(req.DryRun, "DeleteSubnet %s", *req.SubnetID)
+}
+
+func (m *mockEC2) CreateRoute(req *ec2.CreateRouteRequest) error {
+	target := ""
+	if req.GatewayID != nil {
+		target = *req.GatewayID
+	}
+	if req.InstanceID != nil {
+		target = *req.InstanceID
+	}
+	return m.callDryRun(req.DryRun, "CreateRoute %s %s", *req.DestinationCIDRBlock, target)
+}
+
+func (m *mockEC2) DeleteRoute(req *ec2.DeleteRouteRequest) error {
+	return m.callDryRun(req.DryRun, "DeleteRoute %s", *req.DestinationCIDRBlock)
+}
+
+func (m *mockEC2) CreateIPv6Route(req *createIPv6RouteRequest) error {
+	target := ""
+	if req.GatewayID != nil {
+		target = *req.GatewayID
+	}
+	if req.InstanceID != nil {
+		target = *req.InstanceID
+	}
+	return m.callDryRun(req.DryRun, "CreateRoute %s %s", *req.DestinationIPv6CIDRBlock, target)
+}
+
+func (m *mockEC2) DeleteIPv6Route(req *deleteIPv6RouteRequest) error {
+	return m.callDryRun(req.DryRun, "DeleteRoute %s", *req.DestinationIPv6CIDRBlock)
+}
+
+func (m *mockEC2) AuthorizeSecurityGroupIngress(req *ec2.AuthorizeSecurityGroupIngressRequest) error {
+	return m.callDryRun(req.DryRun, "AuthorizeSecurityGroupIngress %s %s", *req.GroupID, mockIPPerms(req.IPPermissions))
+}
+
+func (m *mockEC2) RevokeSecurityGroupIngress(req *ec2.RevokeSecurityGroupIngressRequest) error {
+	return m.callDryRun(req.DryRun, "RevokeSecurityGroupIngress %s %s", *req.GroupID, mockIPPerms(req.IPPermissions))
+}
+
+func (m *mockEC2) RevokeSecurityGroupEgress(req *ec2.RevokeSecurityGroupEgressRequest) error {
+	return m.callDryRun(req.DryRun, "RevokeSecurityGroupEgress %s %s", *req.GroupID, mockIPPerms(req.IPPermissions))
+}
+
+// mockIPPerms formats permissions as sorted "protocol:from-to:source"
+// strings.
+func mockIPPerms(perms []ec2.IPPermission) string {
+	var result []string
+	for _, p := range perms {
+		prefix := fmt.Sprintf("%s:%d-%d:", *p.IPProtocol, *p.FromPort, *p.ToPort)
+		for _, r := range p.IPRanges {
+			result = append(result, prefix+*r.CIDRIP)
+		}
+		for _, g := range p.UserIDGroupPairs {
+			result = append(result, prefix+*g.GroupID)
+		}
+	}
+	sort.Strings(result)
+	return strings.Join(result, ",")
+}
+
+func TestVPCStateRefreshFunc(t *testing.T) {
+	state := "available"
+	conn := &mockEC2{vpcs: []ec2.VPC{ec2.VPC{State: &state}}}
+
+	vpc, s, err := VPCStateRefreshFunc(conn, "vpc-1")()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if vpc == nil || s != "available" {
+		t.Fatalf("bad: %#v %s", vpc, s)
+	}
+
+	conn.errs = map[string]error{"DescribeVPCs": &codaws.APIError{Code: "InvalidVpcID.NotFound"}}
+	vpc, s, err = VPCStateRefreshFunc(conn, "vpc-1")()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if vpc != nil || s != "" {
+		t.Fatalf("expected a missing VPC, got %#v %s", vpc, s)
+	}
+
+	conn.errs = map[string]error{"DescribeVPCs": &codaws.APIError{Code: "UnauthorizedOperation"}}
+	if _, _, err := VPCStateRefreshFunc(conn, "vpc-1")(); err == nil {
+		t.Fatal("expected error")
+	}
+}
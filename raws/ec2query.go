@@ -0,0 +1,256 @@
+package raws
+
+import (
+	"fmt"
+	"net/http"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+)
+
+// ec2QueryAPIVersion is the EC2 API version the calls the generated client
+// doesn't have are sent with.
+const ec2QueryAPIVersion = "2016-11-15"
+
+// ec2Conn is the EC2 connection the provider uses: the generated client,
+// plus the calls EC2 added after it was generated. Those are sent with a
+// plain EC2Client, the same way AWSAccountID sends GetCallerIdentity.
+type ec2Conn struct {
+	*ec2.EC2
+
+	query *codaws.EC2Client
+}
+
+func newEC2Conn(creds codaws.CredentialsProvider, region string, httpClient *http.Client) *ec2Conn {
+	return &ec2Conn{
+		EC2: ec2.New(creds, region, httpClient),
+		query: &codaws.EC2Client{
+			Context: codaws.Context{
+				Service:     "ec2",
+				Region:      region,
+				Credentials: creds,
+			},
+			Client:     httpClient,
+			Endpoint:   fmt.Sprintf("https://ec2.%s.%s", region, partitionForRegion(region).DNSSuffix),
+			APIVersion: ec2QueryAPIVersion,
+		},
+	}
+}
+
+// vpcCIDRBlockAssociation is a CIDR block associated with a VPC. Its
+// state is one of associating, associated, disassociating, disassociated,
+// failing or failed.
+type vpcCIDRBlockAssociation struct {
+	AssociationID codaws.StringValue `xml:"associationId"`
+	CIDRBlock     codaws.StringValue `xml:"cidrBlock"`
+	State         codaws.StringValue `xml:"cidrBlockState>state"`
+	StatusMessage codaws.StringValue `xml:"cidrBlockState>statusMessage"`
+}
+
+// ipv6CIDRBlockAssociation is an IPv6 CIDR block associated with a VPC or
+// a subnet. Its states are those of vpcCIDRBlockAssociation.
+type ipv6CIDRBlockAssociation struct {
+	AssociationID codaws.StringValue `xml:"associationId"`
+	IPv6CIDRBlock codaws.StringValue `xml:"ipv6CidrBlock"`
+	State         codaws.StringValue `xml:"ipv6CidrBlockState>state"`
+	StatusMessage codaws.StringValue `xml:"ipv6CidrBlockState>statusMessage"`
+}
+
+// vpcCIDRBlocks lists the CIDR blocks associated with a VPC, including the
+// one it was created with.
+type vpcCIDRBlocks struct {
+	VPCID                     codaws.StringValue         `xml:"vpcId"`
+	CIDRBlockAssociations     []vpcCIDRBlockAssociation  `xml:"cidrBlockAssociationSet>item"`
+	IPv6CIDRBlockAssociations []ipv6CIDRBlockAssociation `xml:"ipv6CidrBlockAssociationSet>item"`
+}
+
+// associateVPCCIDRBlockRequest associates either CIDRBlock or, with
+// AmazonProvidedIPv6CIDRBlock, an IPv6 block picked by Amazon.
+type associateVPCCIDRBlockRequest struct {
+	AmazonProvidedIPv6CIDRBlock codaws.BooleanValue `ec2:"AmazonProvidedIpv6CidrBlock"`
+	CIDRBlock                   codaws.StringValue  `ec2:"CidrBlock"`
+	VPCID                       codaws.StringValue  `ec2:"VpcId"`
+}
+
+type associateVPCCIDRBlockResult struct {
+	CIDRBlockAssociation     *vpcCIDRBlockAssociation  `xml:"cidrBlockAssociation"`
+	IPv6CIDRBlockAssociation *ipv6CIDRBlockAssociation `xml:"ipv6CidrBlockAssociation"`
+	VPCID                    codaws.StringValue        `xml:"vpcId"`
+}
+
+type disassociateVPCCIDRBlockRequest struct {
+	AssociationID codaws.StringValue `ec2:"AssociationId"`
+}
+
+type disassociateVPCCIDRBlockResult struct {
+	CIDRBlockAssociation     *vpcCIDRBlockAssociation  `xml:"cidrBlockAssociation"`
+	IPv6CIDRBlockAssociation *ipv6CIDRBlockAssociation `xml:"ipv6CidrBlockAssociation"`
+	VPCID                    codaws.StringValue        `xml:"vpcId"`
+}
+
+// describeVPCCIDRBlocksRequest is a DescribeVpcs call that only reads the
+// CIDR block associations of the VPCs.
+type describeVPCCIDRBlocksRequest struct {
+	Filters []ec2.Filter `ec2:"Filter"`
+	VPCIDs  []string     `ec2:"VpcId"`
+}
+
+type describeVPCCIDRBlocksResult struct {
+	VPCs []vpcCIDRBlocks `xml:"vpcSet>item"`
+}
+
+// subnetIPv6 is the IPv6 configuration of a subnet.
+type subnetIPv6 struct {
+	SubnetID                    codaws.StringValue         `xml:"subnetId"`
+	AssignIPv6AddressOnCreation codaws.BooleanValue        `xml:"assignIpv6AddressOnCreation"`
+	IPv6CIDRBlockAssociations   []ipv6CIDRBlockAssociation `xml:"ipv6CidrBlockAssociationSet>item"`
+}
+
+// describeSubnetIPv6Request is a DescribeSubnets call that only reads the
+// IPv6 configuration of the subnets.
+type describeSubnetIPv6Request struct {
+	SubnetIDs []string `ec2:"SubnetId"`
+}
+
+type describeSubnetIPv6Result struct {
+	Subnets []subnetIPv6 `xml:"subnetSet>item"`
+}
+
+type associateSubnetCIDRBlockRequest struct {
+	IPv6CIDRBlock codaws.StringValue `ec2:"Ipv6CidrBlock"`
+	SubnetID      codaws.StringValue `ec2:"SubnetId"`
+}
+
+type associateSubnetCIDRBlockResult struct {
+	IPv6CIDRBlockAssociation *ipv6CIDRBlockAssociation `xml:"ipv6CidrBlockAssociation"`
+	SubnetID                 codaws.StringValue        `xml:"subnetId"`
+}
+
+type disassociateSubnetCIDRBlockRequest struct {
+	AssociationID codaws.StringValue `ec2:"AssociationId"`
+}
+
+type disassociateSubnetCIDRBlockResult struct {
+	IPv6CIDRBlockAssociation *ipv6CIDRBlockAssociation `xml:"ipv6CidrBlockAssociation"`
+	SubnetID                 codaws.StringValue        `xml:"subnetId"`
+}
+
+// modifySubnetIPv6AttributeRequest is a ModifySubnetAttribute call for the
+// attribute the generated client doesn't know.
+type modifySubnetIPv6AttributeRequest struct {
+	AssignIPv6AddressOnCreation *ec2.AttributeBooleanValue `ec2:"AssignIpv6AddressOnCreation"`
+	SubnetID                    codaws.StringValue         `ec2:"SubnetId"`
+}
+
+// ipv6Route is a route to an IPv6 destination. Only one of GatewayID and
+// InstanceID is set.
+type ipv6Route struct {
+	DestinationIPv6CIDRBlock codaws.StringValue `xml:"destinationIpv6CidrBlock"`
+	GatewayID                codaws.StringValue `xml:"gatewayId"`
+	InstanceID               codaws.StringValue `xml:"instanceId"`
+	State                    codaws.StringValue `xml:"state"`
+}
+
+// routeTableIPv6Routes lists the routes of a route table. Routes to IPv4
+// destinations are included, without a DestinationIPv6CIDRBlock.
+type routeTableIPv6Routes struct {
+	RouteTableID codaws.StringValue `xml:"routeTableId"`
+	Routes       []ipv6Route        `xml:"routeSet>item"`
+}
+
+// describeIPv6RoutesRequest is a DescribeRouteTables call that only reads
+// the routes to IPv6 destinations.
+type describeIPv6RoutesRequest struct {
+	RouteTableIDs []string `ec2:"RouteTableId"`
+}
+
+type describeIPv6RoutesResult struct {
+	RouteTables []routeTableIPv6Routes `xml:"routeTableSet>item"`
+}
+
+type createIPv6RouteRequest struct {
+	DestinationIPv6CIDRBlock codaws.StringValue  `ec2:"DestinationIpv6CidrBlock"`
+	DryRun                   codaws.BooleanValue `ec2:"DryRun"`
+	GatewayID                codaws.StringValue  `ec2:"GatewayId"`
+	InstanceID               codaws.StringValue  `ec2:"InstanceId"`
+	RouteTableID             codaws.StringValue  `ec2:"RouteTableId"`
+}
+
+type deleteIPv6RouteRequest struct {
+	DestinationIPv6CIDRBlock codaws.StringValue  `ec2:"DestinationIpv6CidrBlock"`
+	DryRun                   codaws.BooleanValue `ec2:"DryRun"`
+	RouteTableID             codaws.StringValue  `ec2:"RouteTableId"`
+}
+
+// ec2Return is the result of the calls that only report success.
+type ec2Return struct {
+	Return codaws.BooleanValue `xml:"return"`
+}
+
+func (c *ec2Conn) AssociateVPCCIDRBlock(req *associateVPCCIDRBlockRequest) (*associateVPCCIDRBlockResult, error) {
+	resp := &associateVPCCIDRBlockResult{}
+	if err := c.query.Do("AssociateVpcCidrBlock", "POST", "/", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *ec2Conn) DisassociateVPCCIDRBlock(req *disassociateVPCCIDRBlockRequest) (*disassociateVPCCIDRBlockResult, error) {
+	resp := &disassociateVPCCIDRBlockResult{}
+	if err := c.query.Do("DisassociateVpcCidrBlock", "POST", "/", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *ec2Conn) DescribeVPCCIDRBlocks(req *describeVPCCIDRBlocksRequest) (*describeVPCCIDRBlocksResult, error) {
+	resp := &describeVPCCIDRBlocksResult{}
+	if err := c.query.Do("DescribeVpcs", "POST", "/", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *ec2Conn) DescribeSubnetIPv6(req *describeSubnetIPv6Request) (*describeSubnetIPv6Result, error) {
+	resp := &describeSubnetIPv6Result{}
+	if err := c.query.Do("DescribeSubnets", "POST", "/", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *ec2Conn) AssociateSubnetCIDRBlock(req *associateSubnetCIDRBlockRequest) (*associateSubnetCIDRBlockResult, error) {
+	resp := &associateSubnetCIDRBlockResult{}
+	if err := c.query.Do("AssociateSubnetCidrBlock", "POST", "/", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *ec2Conn) DisassociateSubnetCIDRBlock(req *disassociateSubnetCIDRBlockRequest) (*disassociateSubnetCIDRBlockResult, error) {
+	resp := &disassociateSubnetCIDRBlockResult{}
+	if err := c.query.Do("DisassociateSubnetCidrBlock", "POST", "/", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *ec2Conn) ModifySubnetIPv6Attribute(req *modifySubnetIPv6AttributeRequest) error {
+	return c.query.Do("ModifySubnetAttribute", "POST", "/", req, &ec2Return{})
+}
+
+func (c *ec2Conn) DescribeIPv6Routes(req *describeIPv6RoutesRequest) (*describeIPv6RoutesResult, error) {
+	resp := &describeIPv6RoutesResult{}
+	if err := c.query.Do("DescribeRouteTables", "POST", "/", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *ec2Conn) CreateIPv6Route(req *createIPv6RouteRequest) error {
+	return c.query.Do("CreateRoute", "POST", "/", req, &ec2Return{})
+}
+
+func (c *ec2Conn) DeleteIPv6Route(req *deleteIPv6RouteRequest) error {
+	return c.query.Do("DeleteRoute", "POST", "/", req, &ec2Return{})
+}
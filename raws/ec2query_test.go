@@ -0,0 +1,200 @@
+package raws
+
+import (
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+)
+
+// testEC2Conn returns a connection to the fake EC2.
+func testEC2Conn(t *testing.T, f *fakeEC2) *ec2Conn {
+	c := Config{
+		AccessKey: "key",
+		SecretKey: "secret",
+		Region:    "us-west-2",
+		Endpoints: map[string]string{"ec2": f.URL},
+	}
+	creds := c.credentialsChain()
+	httpClient, err := c.serviceHTTPClient("ec2", creds)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return newEC2Conn(creds, c.Region, httpClient)
+}
+
+func TestEC2Conn_vpcCIDRBlocks(t *testing.T) {
+	f := newFakeEC2()
+	defer f.Close()
+	conn := testEC2Conn(t, f)
+
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateVpc"}, "CidrBlock": {"10.1.0.0/16"}})
+	vpcID, cidr := "vpc-00000001", "10.2.0.0/16"
+	resp, err := conn.AssociateVPCCIDRBlock(&associateVPCCIDRBlockRequest{
+		CIDRBlock: &cidr,
+		VPCID:     &vpcID,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	id := *resp.CIDRBlockAssociation.AssociationID
+
+	refresh := VPCCIDRBlockAssociationStateRefreshFunc(conn, id)
+	for _, expected := range []string{"associating", "associated"} {
+		raw, state, err := refresh()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if state != expected {
+			t.Fatalf("expected %s, got %s", expected, state)
+		}
+		blocks := raw.(*vpcCIDRBlocks)
+		if *blocks.VPCID != vpcID || len(blocks.CIDRBlockAssociations) != 1 || *blocks.CIDRBlockAssociations[0].CIDRBlock != cidr {
+			t.Fatalf("bad: %#v", blocks)
+		}
+	}
+
+	blocks, err := describeVPCCIDRBlocks(conn, vpcID)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	associations := flattenVPCCIDRBlockAssociations(blocks.CIDRBlockAssociations)
+	if len(associations) != 2 || associations[0]["cidr_block"] != "10.1.0.0/16" || associations[1]["association_id"] != id {
+		t.Fatalf("bad: %#v", associations)
+	}
+
+	if _, err := conn.DisassociateVPCCIDRBlock(&disassociateVPCCIDRBlockRequest{AssociationID: &id}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, state, _ := refresh(); state != "disassociating" {
+		t.Fatalf("expected disassociating, got %s", state)
+	}
+	if raw, _, err := refresh(); raw != nil || err != nil {
+		t.Fatalf("expected the association to be gone, got %#v, %v", raw, err)
+	}
+
+	_, err = conn.DisassociateVPCCIDRBlock(&disassociateVPCCIDRBlockRequest{AssociationID: &id})
+	if ec2err, ok := err.(*codaws.APIError); !ok || ec2err.Code != "InvalidVpcCidrBlockAssociationID.NotFound" {
+		t.Fatalf("expected InvalidVpcCidrBlockAssociationID.NotFound, got: %v", err)
+	}
+}
+
+func TestEC2Conn_ipv6(t *testing.T) {
+	f := newFakeEC2()
+	defer f.Close()
+	conn := testEC2Conn(t, f)
+
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateVpc"}, "CidrBlock": {"10.1.0.0/16"}})
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateSubnet"}, "VpcId": {"vpc-00000001"}, "CidrBlock": {"10.1.1.0/24"}})
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateRouteTable"}, "VpcId": {"vpc-00000001"}})
+	var subnetID, rtID string
+	for id := range f.subnets {
+		subnetID = id
+	}
+	for id, rt := range f.routeTables {
+		if !rt.isMain() {
+			rtID = id
+		}
+	}
+
+	vpcID, amazonProvided := "vpc-00000001", true
+	resp, err := conn.AssociateVPCCIDRBlock(&associateVPCCIDRBlockRequest{
+		AmazonProvidedIPv6CIDRBlock: &amazonProvided,
+		VPCID:                       &vpcID,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	vpcBlock := resp.IPv6CIDRBlockAssociation
+	if vpcBlock == nil || *vpcBlock.State != "associating" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if _, state, err := VPCIPv6CIDRBlockAssociationStateRefreshFunc(conn, vpcID, *vpcBlock.AssociationID)(); err != nil || state != "associating" {
+		t.Fatalf("expected associating, got %s, %v", state, err)
+	}
+	blocks, err := describeVPCCIDRBlocks(conn, vpcID)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if a := vpcIPv6CIDRBlockAssociation(blocks); a == nil || *a.IPv6CIDRBlock != *vpcBlock.IPv6CIDRBlock || *a.State != "associated" {
+		t.Fatalf("bad: %#v", blocks)
+	}
+
+	_, vpcNetwork, _ := net.ParseCIDR(*vpcBlock.IPv6CIDRBlock)
+	subnetCIDR := (&net.IPNet{IP: vpcNetwork.IP, Mask: net.CIDRMask(64, 128)}).String()
+	subnetResp, err := conn.AssociateSubnetCIDRBlock(&associateSubnetCIDRBlockRequest{
+		IPv6CIDRBlock: &subnetCIDR,
+		SubnetID:      &subnetID,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	subnetBlockID := *subnetResp.IPv6CIDRBlockAssociation.AssociationID
+	refresh := SubnetIPv6CIDRBlockAssociationStateRefreshFunc(conn, subnetID, subnetBlockID)
+	for _, expected := range []string{"associating", "associated"} {
+		if _, state, err := refresh(); err != nil || state != expected {
+			t.Fatalf("expected %s, got %s, %v", expected, state, err)
+		}
+	}
+
+	if err := modifySubnetAssignIPv6(conn, subnetID, true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	subnets, err := conn.DescribeSubnetIPv6(&describeSubnetIPv6Request{SubnetIDs: []string{subnetID}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(subnets.Subnets) != 1 || !*subnets.Subnets[0].AssignIPv6AddressOnCreation {
+		t.Fatalf("bad: %#v", subnets)
+	}
+
+	_, err = conn.DisassociateVPCCIDRBlock(&disassociateVPCCIDRBlockRequest{AssociationID: vpcBlock.AssociationID})
+	if ec2err, ok := err.(*codaws.APIError); !ok || ec2err.Code != "DependencyViolation" {
+		t.Fatalf("expected DependencyViolation, got: %v", err)
+	}
+
+	dest, local := "::/0", "local"
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateInternetGateway"}})
+	var igwID string
+	for id := range f.gateways {
+		igwID = id
+	}
+	testFakeEC2Call(t, f, url.Values{"Action": {"AttachInternetGateway"}, "InternetGatewayId": {igwID}, "VpcId": {vpcID}})
+	if err := conn.CreateIPv6Route(&createIPv6RouteRequest{
+		DestinationIPv6CIDRBlock: &dest,
+		GatewayID:                &igwID,
+		RouteTableID:             &rtID,
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	routes, err := conn.DescribeIPv6Routes(&describeIPv6RoutesRequest{RouteTableIDs: []string{rtID}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var found []string
+	for _, r := range routes.RouteTables[0].Routes {
+		if r.DestinationIPv6CIDRBlock != nil {
+			found = append(found, *r.DestinationIPv6CIDRBlock+" "+*r.GatewayID)
+		}
+	}
+	expected := []string{*vpcBlock.IPv6CIDRBlock + " " + local, dest + " " + igwID}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatalf("expected %v, got %v", expected, found)
+	}
+
+	if err := conn.DeleteIPv6Route(&deleteIPv6RouteRequest{
+		DestinationIPv6CIDRBlock: &dest,
+		RouteTableID:             &rtID,
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = conn.DeleteIPv6Route(&deleteIPv6RouteRequest{
+		DestinationIPv6CIDRBlock: &dest,
+		RouteTableID:             &rtID,
+	})
+	if ec2err, ok := err.(*codaws.APIError); !ok || ec2err.Code != "InvalidRoute.NotFound" {
+		t.Fatalf("expected InvalidRoute.NotFound, got: %v", err)
+	}
+}
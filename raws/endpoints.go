@@ -0,0 +1,159 @@
+package raws
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// awsPartition describes one AWS partition: the regions in it and the
+// domain their hostnames are in.
+type awsPartition struct {
+	ID          string
+	Name        string
+	DNSSuffix   string
+	RegionRegex *regexp.Regexp
+	Regions     []string
+}
+
+// awsPartitions is the endpoints table shipped with the provider. Add new
+// regions here; region validation and partition lookups are driven by it.
+var awsPartitions = []*awsPartition{
+	&awsPartition{
+		ID:          "aws",
+		Name:        "AWS Standard",
+		DNSSuffix:   "amazonaws.com",
+		RegionRegex: regexp.MustCompile(`^(us|eu|ap|sa|ca|me|af|il|mx)-\w+-\d+$`),
+		Regions: []string{
+			"af-south-1",
+			"ap-east-1",
+			"ap-east-2",
+			"ap-northeast-1",
+			"ap-northeast-2",
+			"ap-northeast-3",
+			"ap-south-1",
+			"ap-south-2",
+			"ap-southeast-1",
+			"ap-southeast-2",
+			"ap-southeast-3",
+			"ap-southeast-4",
+			"ap-southeast-5",
+			"ap-southeast-7",
+			"ca-central-1",
+			"ca-west-1",
+			"eu-central-1",
+			"eu-central-2",
+			"eu-north-1",
+			"eu-south-1",
+			"eu-south-2",
+			"eu-west-1",
+			"eu-west-2",
+			"eu-west-3",
+			"il-central-1",
+			"me-central-1",
+			"me-south-1",
+			"mx-central-1",
+			"sa-east-1",
+			"us-east-1",
+			"us-east-2",
+			"us-west-1",
+			"us-west-2",
+		},
+	},
+	&awsPartition{
+		ID:          "aws-cn",
+		Name:        "AWS China",
+		DNSSuffix:   "amazonaws.com.cn",
+		RegionRegex: regexp.MustCompile(`^cn-\w+-\d+$`),
+		Regions: []string{
+			"cn-north-1",
+			"cn-northwest-1",
+		},
+	},
+	&awsPartition{
+		ID:          "aws-us-gov",
+		Name:        "AWS GovCloud (US)",
+		DNSSuffix:   "amazonaws.com",
+		RegionRegex: regexp.MustCompile(`^us-gov-\w+-\d+$`),
+		Regions: []string{
+			"us-gov-east-1",
+			"us-gov-west-1",
+		},
+	},
+}
+
+// partitionForRegion returns the partition a region belongs to. Regions
+// missing from the table are matched on their name, falling back to the
+// standard partition.
+func partitionForRegion(region string) *awsPartition {
+	for _, p := range awsPartitions {
+		for _, r := range p.Regions {
+			if r == region {
+				return p
+			}
+		}
+	}
+
+	// Check the more specific patterns first: us-gov-west-1 also looks
+	// like a standard region.
+	for i := len(awsPartitions) - 1; i >= 0; i-- {
+		if awsPartitions[i].RegionRegex.MatchString(region) {
+			return awsPartitions[i]
+		}
+	}
+
+	return awsPartitions[0]
+}
+
+// validateRegion returns an error, with the closest known region as a
+// suggestion, if region is not in the endpoints table.
+func validateRegion(region string) error {
+	best, bestDistance := "", -1
+	for _, p := range awsPartitions {
+		for _, r := range p.Regions {
+			if r == region {
+				return nil
+			}
+			if d := levenshtein(region, r); bestDistance < 0 || d < bestDistance {
+				best, bestDistance = r, d
+			}
+		}
+	}
+
+	if best != "" && bestDistance <= len(region)/2 {
+		return fmt.Errorf("Not a valid region: %s (did you mean %s?)", region, best)
+	}
+	return fmt.Errorf("Not a valid region: %s", region)
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
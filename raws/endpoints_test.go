@@ -0,0 +1,48 @@
+package raws
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRegion(t *testing.T) {
+	for _, region := range []string{"us-east-1", "eu-west-2", "ap-south-1", "cn-northwest-1", "us-gov-west-1"} {
+		if err := validateRegion(region); err != nil {
+			t.Fatalf("%s: %s", region, err)
+		}
+	}
+
+	err := validateRegion("eu-cental-1")
+	if err == nil {
+		t.Fatal("expected error for misspelled region")
+	}
+	if !strings.Contains(err.Error(), "did you mean eu-central-1?") {
+		t.Fatalf("expected suggestion, got: %s", err)
+	}
+
+	err = validateRegion("nowhere")
+	if err == nil {
+		t.Fatal("expected error for unknown region")
+	}
+	if strings.Contains(err.Error(), "did you mean") {
+		t.Fatalf("unexpected suggestion: %s", err)
+	}
+}
+
+func TestPartitionForRegion(t *testing.T) {
+	cases := map[string]string{
+		"us-east-1":      "aws",
+		"eu-central-1":   "aws",
+		"cn-north-1":     "aws-cn",
+		"cn-south-9":     "aws-cn",
+		"us-gov-west-1":  "aws-us-gov",
+		"us-gov-north-9": "aws-us-gov",
+		"us-west-9":      "aws",
+		"local":          "aws",
+	}
+	for region, expected := range cases {
+		if p := partitionForRegion(region); p.ID != expected {
+			t.Fatalf("%s: expected %s, got %s", region, expected, p.ID)
+		}
+	}
+}
@@ -0,0 +1,2741 @@
+package raws
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	fakeEC2Namespace = "http://ec2.amazonaws.com/doc/2015-04-15/"
+	fakeEC2OwnerID   = "123456789012"
+)
+
+// fakeEC2 is an in-memory EC2 that speaks the Query protocol over HTTP,
+// so the acceptance tests can run the full CRUD lifecycle without an AWS
+// account. It implements the VPC, subnet, route table, internet gateway,
+// security group and network interface calls the provider makes, with the same state
+// transitions and error codes as the real service: new VPCs and subnets
+// are "pending" on their first describe, gateways are "attaching" and
+// "detaching" and CIDR blocks "associating" and "disassociating" for one
+// describe, and deleting anything with dependents fails with
+// DependencyViolation.
+type fakeEC2 struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	lastID   int
+	requests int
+
+	vpcs        map[string]*fakeVpc
+	subnets     map[string]*fakeSubnet
+	routeTables map[string]*fakeRouteTable
+	gateways    map[string]*fakeInternetGateway
+	groups      map[string]*fakeSecurityGroup
+	acls        map[string]*fakeNetworkACL
+	interfaces  map[string]*fakeNetworkInterface
+	tags        map[string]map[string]string
+
+	// denied lists actions the caller has no IAM permission for. They
+	// fail with UnauthorizedOperation, with or without DryRun.
+	denied map[string]bool
+}
+
+func newFakeEC2() *fakeEC2 {
+	f := &fakeEC2{
+		vpcs:        make(map[string]*fakeVpc),
+		subnets:     make(map[string]*fakeSubnet),
+		routeTables: make(map[string]*fakeRouteTable),
+		gateways:    make(map[string]*fakeInternetGateway),
+		groups:      make(map[string]*fakeSecurityGroup),
+		acls:        make(map[string]*fakeNetworkACL),
+		interfaces:  make(map[string]*fakeNetworkInterface),
+		tags:        make(map[string]map[string]string),
+		denied:      make(map[string]bool),
+	}
+	f.Server = httptest.NewServer(f)
+	return f
+}
+
+type fakeTag struct {
+	Key   string `xml:"key"`
+	Value string `xml:"value"`
+}
+
+type fakeCidrBlockState struct {
+	State         string `xml:"state"`
+	StatusMessage string `xml:"statusMessage,omitempty"`
+}
+
+type fakeCidrBlockAssociation struct {
+	AssociationID  string             `xml:"associationId"`
+	CidrBlock      string             `xml:"cidrBlock"`
+	CidrBlockState fakeCidrBlockState `xml:"cidrBlockState"`
+}
+
+type fakeIpv6CidrBlockAssociation struct {
+	AssociationID      string             `xml:"associationId"`
+	Ipv6CidrBlock      string             `xml:"ipv6CidrBlock"`
+	Ipv6CidrBlockState fakeCidrBlockState `xml:"ipv6CidrBlockState"`
+}
+
+// fakeAdvance moves a CIDR block association on once it has been
+// described: associating blocks become associated and disassociating
+// blocks disappear. It reports whether the association is kept.
+func fakeAdvance(state *fakeCidrBlockState) bool {
+	switch state.State {
+	case "associating":
+		state.State = "associated"
+	case "disassociating":
+		return false
+	}
+	return true
+}
+
+type fakeVpc struct {
+	VpcID                       string                         `xml:"vpcId"`
+	State                       string                         `xml:"state"`
+	CidrBlock                   string                         `xml:"cidrBlock"`
+	CidrBlockAssociationSet     []fakeCidrBlockAssociation     `xml:"cidrBlockAssociationSet>item"`
+	Ipv6CidrBlockAssociationSet []fakeIpv6CidrBlockAssociation `xml:"ipv6CidrBlockAssociationSet>item"`
+	DhcpOptionsID               string                         `xml:"dhcpOptionsId"`
+	InstanceTenancy             string                         `xml:"instanceTenancy"`
+	IsDefault                   bool                           `xml:"isDefault"`
+	TagSet                      []fakeTag                      `xml:"tagSet>item"`
+
+	enableDNSSupport   bool
+	enableDNSHostnames bool
+}
+
+type fakeSubnet struct {
+	SubnetID                string    `xml:"subnetId"`
+	State                   string    `xml:"state"`
+	VpcID                   string    `xml:"vpcId"`
+	CidrBlock               string    `xml:"cidrBlock"`
+	AvailableIPAddressCount int       `xml:"availableIpAddressCount"`
+	AvailabilityZone        string    `xml:"availabilityZone"`
+	DefaultForAz            bool      `xml:"defaultForAz"`
+	MapPublicIPOnLaunch     bool      `xml:"mapPublicIpOnLaunch"`
+	TagSet                  []fakeTag `xml:"tagSet>item"`
+
+	AssignIpv6AddressOnCreation bool                           `xml:"assignIpv6AddressOnCreation"`
+	Ipv6CidrBlockAssociationSet []fakeIpv6CidrBlockAssociation `xml:"ipv6CidrBlockAssociationSet>item"`
+}
+
+// copy returns a copy of the subnet that doesn't change with it.
+func (s *fakeSubnet) copy() fakeSubnet {
+	c := *s
+	c.Ipv6CidrBlockAssociationSet = append([]fakeIpv6CidrBlockAssociation(nil), s.Ipv6CidrBlockAssociationSet...)
+	return c
+}
+
+// ipv6CidrBlock returns the IPv6 network of the subnet, or nil.
+func (s *fakeSubnet) ipv6CidrBlock() *net.IPNet {
+	for _, a := range s.Ipv6CidrBlockAssociationSet {
+		if a.Ipv6CidrBlockState.State == "associated" || a.Ipv6CidrBlockState.State == "associating" {
+			_, network, _ := net.ParseCIDR(a.Ipv6CidrBlock)
+			return network
+		}
+	}
+	return nil
+}
+
+type fakeRoute struct {
+	DestinationCidrBlock     string `xml:"destinationCidrBlock,omitempty"`
+	DestinationIpv6CidrBlock string `xml:"destinationIpv6CidrBlock,omitempty"`
+	GatewayID                string `xml:"gatewayId,omitempty"`
+	InstanceID               string `xml:"instanceId,omitempty"`
+	State                    string `xml:"state"`
+	Origin                   string `xml:"origin"`
+}
+
+type fakeRouteTableAssociation struct {
+	RouteTableAssociationID string `xml:"routeTableAssociationId"`
+	RouteTableID            string `xml:"routeTableId"`
+	SubnetID                string `xml:"subnetId,omitempty"`
+	Main                    bool   `xml:"main"`
+}
+
+type fakeRouteTable struct {
+	RouteTableID   string                      `xml:"routeTableId"`
+	VpcID          string                      `xml:"vpcId"`
+	Routes         []fakeRoute                 `xml:"routeSet>item"`
+	Associations   []fakeRouteTableAssociation `xml:"associationSet>item"`
+	PropagatingVgw []string                    `xml:"propagatingVgwSet>item>gatewayId"`
+	TagSet         []fakeTag                   `xml:"tagSet>item"`
+}
+
+type fakeAttachment struct {
+	VpcID string `xml:"vpcId"`
+	State string `xml:"state"`
+}
+
+type fakeInternetGateway struct {
+	InternetGatewayID string           `xml:"internetGatewayId"`
+	Attachments       []fakeAttachment `xml:"attachmentSet>item"`
+	TagSet            []fakeTag        `xml:"tagSet>item"`
+}
+
+type fakeGroupPair struct {
+	UserID  string `xml:"userId"`
+	GroupID string `xml:"groupId"`
+}
+
+type fakeIPRange struct {
+	CidrIP string `xml:"cidrIp"`
+}
+
+type fakeIPPermission struct {
+	IPProtocol string          `xml:"ipProtocol"`
+	FromPort   int             `xml:"fromPort"`
+	ToPort     int             `xml:"toPort"`
+	Groups     []fakeGroupPair `xml:"groups>item"`
+	IPRanges   []fakeIPRange   `xml:"ipRanges>item"`
+}
+
+type fakeSecurityGroup struct {
+	OwnerID             string             `xml:"ownerId"`
+	GroupID             string             `xml:"groupId"`
+	GroupName           string             `xml:"groupName"`
+	GroupDescription    string             `xml:"groupDescription"`
+	VpcID               string             `xml:"vpcId,omitempty"`
+	IPPermissions       []fakeIPPermission `xml:"ipPermissions>item"`
+	IPPermissionsEgress []fakeIPPermission `xml:"ipPermissionsEgress>item"`
+	TagSet              []fakeTag          `xml:"tagSet>item"`
+}
+
+// fakeNetworkACL only models what the provider reads: which ACL is the
+// default of a VPC.
+type fakeNetworkACL struct {
+	NetworkACLID string    `xml:"networkAclId"`
+	VpcID        string    `xml:"vpcId"`
+	IsDefault    bool      `xml:"default"`
+	TagSet       []fakeTag `xml:"tagSet>item"`
+}
+
+type fakeGroupIdentifier struct {
+	GroupID   string `xml:"groupId"`
+	GroupName string `xml:"groupName"`
+}
+
+// fakeNetworkInterface is never attached: the fake has no instances.
+type fakeNetworkInterface struct {
+	NetworkInterfaceID string                `xml:"networkInterfaceId"`
+	SubnetID           string                `xml:"subnetId"`
+	VpcID              string                `xml:"vpcId"`
+	AvailabilityZone   string                `xml:"availabilityZone"`
+	Description        string                `xml:"description"`
+	OwnerID            string                `xml:"ownerId"`
+	RequesterManaged   bool                  `xml:"requesterManaged"`
+	Status             string                `xml:"status"`
+	PrivateIPAddress   string                `xml:"privateIpAddress"`
+	Groups             []fakeGroupIdentifier `xml:"groupSet>item"`
+	TagSet             []fakeTag             `xml:"tagSet>item"`
+}
+
+// fakeResult is the body of a successful response. Every result embeds
+// fakeMeta so the request ID ends up in the response.
+type fakeResult interface {
+	setRequestID(id string)
+}
+
+type fakeMeta struct {
+	RequestID string `xml:"requestId"`
+}
+
+func (m *fakeMeta) setRequestID(id string) { m.RequestID = id }
+
+type fakeReturn struct {
+	fakeMeta
+	Return bool `xml:"return"`
+}
+
+// fakeError is an EC2 API error.
+type fakeError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *fakeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func fakeErrorf(code, format string, args ...interface{}) *fakeError {
+	return &fakeError{Status: http.StatusBadRequest, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (f *fakeEC2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		f.writeError(w, "", fakeErrorf("MalformedQueryString", "%s", err))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+	requestID := fmt.Sprintf("%08x-0000-4000-8000-%012x", f.requests, f.requests)
+
+	if r.Header.Get("Authorization") == "" && r.Form.Get("Signature") == "" && r.Form.Get("X-Amz-Signature") == "" {
+		f.writeError(w, requestID, &fakeError{
+			Status:  http.StatusUnauthorized,
+			Code:    "AuthFailure",
+			Message: "AWS was not able to validate the provided access credentials",
+		})
+		return
+	}
+
+	action := r.Form.Get("Action")
+	handler, ok := fakeEC2Actions[action]
+	if !ok {
+		f.writeError(w, requestID, fakeErrorf("InvalidAction", "The action %s is not valid for this web service.", action))
+		return
+	}
+
+	if f.denied[action] {
+		f.writeError(w, requestID, &fakeError{
+			Status:  http.StatusForbidden,
+			Code:    "UnauthorizedOperation",
+			Message: "You are not authorized to perform this operation.",
+		})
+		return
+	}
+	if dryRun, _, _ := fakeBool(r.Form, "DryRun"); dryRun {
+		f.writeError(w, requestID, &fakeError{
+			Status:  http.StatusPreconditionFailed,
+			Code:    "DryRunOperation",
+			Message: "Request would have succeeded, but DryRun flag is set.",
+		})
+		return
+	}
+
+	result, err := handler(f, r.Form)
+	if err != nil {
+		ferr, ok := err.(*fakeError)
+		if !ok {
+			ferr = &fakeError{Status: http.StatusInternalServerError, Code: "InternalError", Message: err.Error()}
+		}
+		f.writeError(w, requestID, ferr)
+		return
+	}
+
+	result.setRequestID(requestID)
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	start := xml.StartElement{
+		Name: xml.Name{Local: action + "Response"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: fakeEC2Namespace}},
+	}
+	if err := enc.EncodeElement(result, start); err != nil {
+		f.writeError(w, requestID, &fakeError{Status: http.StatusInternalServerError, Code: "InternalError", Message: err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+	w.Write(buf.Bytes())
+}
+
+func (f *fakeEC2) writeError(w http.ResponseWriter, requestID string, e *fakeError) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString("<Response><Errors><Error><Code>")
+	xml.EscapeText(&buf, []byte(e.Code))
+	buf.WriteString("</Code><Message>")
+	xml.EscapeText(&buf, []byte(e.Message))
+	buf.WriteString("</Message></Error></Errors><RequestID>")
+	xml.EscapeText(&buf, []byte(requestID))
+	buf.WriteString("</RequestID></Response>")
+
+	w.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+	w.WriteHeader(e.Status)
+	w.Write(buf.Bytes())
+}
+
+var fakeEC2Actions map[string]func(*fakeEC2, url.Values) (fakeResult, error)
+
+func init() {
+	fakeEC2Actions = map[string]func(*fakeEC2, url.Values) (fakeResult, error){
+		"CreateVpc":                     (*fakeEC2).createVpc,
+		"DescribeVpcs":                  (*fakeEC2).describeVpcs,
+		"DescribeVpcAttribute":          (*fakeEC2).describeVpcAttribute,
+		"ModifyVpcAttribute":            (*fakeEC2).modifyVpcAttribute,
+		"DeleteVpc":                     (*fakeEC2).deleteVpc,
+		"AssociateVpcCidrBlock":         (*fakeEC2).associateVpcCidrBlock,
+		"DisassociateVpcCidrBlock":      (*fakeEC2).disassociateVpcCidrBlock,
+		"CreateSubnet":                  (*fakeEC2).createSubnet,
+		"DescribeSubnets":               (*fakeEC2).describeSubnets,
+		"ModifySubnetAttribute":         (*fakeEC2).modifySubnetAttribute,
+		"DeleteSubnet":                  (*fakeEC2).deleteSubnet,
+		"AssociateSubnetCidrBlock":      (*fakeEC2).associateSubnetCidrBlock,
+		"DisassociateSubnetCidrBlock":   (*fakeEC2).disassociateSubnetCidrBlock,
+		"CreateRouteTable":              (*fakeEC2).createRouteTable,
+		"DescribeRouteTables":           (*fakeEC2).describeRouteTables,
+		"DeleteRouteTable":              (*fakeEC2).deleteRouteTable,
+		"CreateRoute":                   (*fakeEC2).createRoute,
+		"DeleteRoute":                   (*fakeEC2).deleteRoute,
+		"AssociateRouteTable":           (*fakeEC2).associateRouteTable,
+		"ReplaceRouteTableAssociation":  (*fakeEC2).replaceRouteTableAssociation,
+		"DisassociateRouteTable":        (*fakeEC2).disassociateRouteTable,
+		"CreateInternetGateway":         (*fakeEC2).createInternetGateway,
+		"DescribeInternetGateways":      (*fakeEC2).describeInternetGateways,
+		"AttachInternetGateway":         (*fakeEC2).attachInternetGateway,
+		"DetachInternetGateway":         (*fakeEC2).detachInternetGateway,
+		"DeleteInternetGateway":         (*fakeEC2).deleteInternetGateway,
+		"CreateSecurityGroup":           (*fakeEC2).createSecurityGroup,
+		"DescribeSecurityGroups":        (*fakeEC2).describeSecurityGroups,
+		"AuthorizeSecurityGroupIngress": (*fakeEC2).authorizeSecurityGroupIngress,
+		"RevokeSecurityGroupIngress":    (*fakeEC2).revokeSecurityGroupIngress,
+		"DeleteSecurityGroup":           (*fakeEC2).deleteSecurityGroup,
+		"DescribeNetworkAcls":           (*fakeEC2).describeNetworkAcls,
+		"CreateNetworkInterface":        (*fakeEC2).createNetworkInterface,
+		"DescribeNetworkInterfaces":     (*fakeEC2).describeNetworkInterfaces,
+		"DetachNetworkInterface":        (*fakeEC2).detachNetworkInterface,
+		"DeleteNetworkInterface":        (*fakeEC2).deleteNetworkInterface,
+		"CreateTags":                    (*fakeEC2).createTags,
+		"DeleteTags":                    (*fakeEC2).deleteTags,
+	}
+}
+
+func (f *fakeEC2) newID(prefix string) string {
+	f.lastID++
+	return fmt.Sprintf("%s-%08x", prefix, f.lastID)
+}
+
+// Request parameters
+
+func fakeRequired(p url.Values, name string) (string, error) {
+	v := p.Get(name)
+	if v == "" {
+		return "", fakeErrorf("MissingParameter", "The request must contain the parameter %s", name)
+	}
+	return v, nil
+}
+
+// fakeList returns the values of a Query list parameter: name.1,
+// name.2, ...
+func fakeList(p url.Values, name string) []string {
+	var result []string
+	for i := 1; ; i++ {
+		v, ok := p[fmt.Sprintf("%s.%d", name, i)]
+		if !ok {
+			return result
+		}
+		result = append(result, v[0])
+	}
+}
+
+// fakeMembers returns the members of a Query list of structures, each
+// with the name.N. prefix stripped from its keys.
+func fakeMembers(p url.Values, name string) []url.Values {
+	var result []url.Values
+	for i := 1; ; i++ {
+		prefix := fmt.Sprintf("%s.%d.", name, i)
+		member := make(url.Values)
+		for k, v := range p {
+			if strings.HasPrefix(k, prefix) {
+				member[strings.TrimPrefix(k, prefix)] = v
+			}
+		}
+		if len(member) == 0 {
+			return result
+		}
+		result = append(result, member)
+	}
+}
+
+func fakeBool(p url.Values, name string) (bool, bool, error) {
+	v := p.Get(name)
+	if v == "" {
+		return false, false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, false, fakeErrorf("InvalidParameterValue", "Value (%s) for parameter %s is invalid", v, name)
+	}
+	return b, true, nil
+}
+
+// fakeFilters reads the Filter.N.Name/Filter.N.Value.M parameters.
+func fakeFilters(p url.Values) map[string][]string {
+	filters := make(map[string][]string)
+	for _, m := range fakeMembers(p, "Filter") {
+		filters[m.Get("Name")] = append(filters[m.Get("Name")], fakeList(m, "Value")...)
+	}
+	return filters
+}
+
+// fakeMatch reports whether a resource with the given filterable values
+// and tags passes every filter.
+func fakeMatch(filters map[string][]string, values map[string]string, tags map[string]string) bool {
+	for name, wanted := range filters {
+		var have []string
+		switch {
+		case strings.HasPrefix(name, "tag:"):
+			if v, ok := tags[strings.TrimPrefix(name, "tag:")]; ok {
+				have = []string{v}
+			}
+		case name == "tag-key":
+			for k := range tags {
+				have = append(have, k)
+			}
+		default:
+			v, ok := values[name]
+			if !ok {
+				return false
+			}
+			have = strings.Split(v, ",")
+		}
+
+		found := false
+		for _, w := range wanted {
+			for _, h := range have {
+				if w == h {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fakeEC2) tagSet(id string) []fakeTag {
+	var keys []string
+	for k := range f.tags[id] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]fakeTag, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, fakeTag{Key: k, Value: f.tags[id][k]})
+	}
+	return result
+}
+
+// VPCs
+
+func (f *fakeEC2) createVpc(p url.Values) (fakeResult, error) {
+	cidr, err := fakeRequired(p, "CidrBlock")
+	if err != nil {
+		return nil, err
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil || network.String() != cidr {
+		return nil, fakeErrorf("InvalidParameterValue", "Value (%s) for parameter cidrBlock is invalid. This is not a valid CIDR block.", cidr)
+	}
+	if ones, _ := network.Mask.Size(); ones < 16 || ones > 28 {
+		return nil, fakeErrorf("InvalidVpc.Range", "The CIDR '%s' is invalid.", cidr)
+	}
+	tenancy := p.Get("InstanceTenancy")
+	switch tenancy {
+	case "":
+		tenancy = "default"
+	case "default", "dedicated":
+	default:
+		return nil, fakeErrorf("InvalidParameterValue", "Value (%s) for parameter instanceTenancy is invalid.", tenancy)
+	}
+
+	vpc := &fakeVpc{
+		VpcID:            f.newID("vpc"),
+		State:            "pending",
+		CidrBlock:        cidr,
+		DhcpOptionsID:    "dopt-00000001",
+		InstanceTenancy:  tenancy,
+		enableDNSSupport: true,
+	}
+	vpc.CidrBlockAssociationSet = []fakeCidrBlockAssociation{{
+		AssociationID:  f.newID("vpc-cidr-assoc"),
+		CidrBlock:      cidr,
+		CidrBlockState: fakeCidrBlockState{State: "associated"},
+	}}
+	f.vpcs[vpc.VpcID] = vpc
+
+	// Every VPC comes with a main route table, a default network ACL and
+	// a default security group that allows traffic from itself.
+	rt := &fakeRouteTable{
+		RouteTableID: f.newID("rtb"),
+		VpcID:        vpc.VpcID,
+		Routes:       []fakeRoute{fakeLocalRoute(cidr)},
+	}
+	rt.Associations = []fakeRouteTableAssociation{{
+		RouteTableAssociationID: f.newID("rtbassoc"),
+		RouteTableID:            rt.RouteTableID,
+		Main:                    true,
+	}}
+	f.routeTables[rt.RouteTableID] = rt
+
+	sg := &fakeSecurityGroup{
+		OwnerID:          fakeEC2OwnerID,
+		GroupID:          f.newID("sg"),
+		GroupName:        "default",
+		GroupDescription: "default VPC security group",
+		VpcID:            vpc.VpcID,
+		IPPermissionsEgress: []fakeIPPermission{{
+			IPProtocol: "-1",
+			IPRanges:   []fakeIPRange{{CidrIP: "0.0.0.0/0"}},
+		}},
+	}
+	sg.IPPermissions = []fakeIPPermission{{
+		IPProtocol: "-1",
+		Groups:     []fakeGroupPair{{UserID: fakeEC2OwnerID, GroupID: sg.GroupID}},
+	}}
+	f.groups[sg.GroupID] = sg
+
+	acl := &fakeNetworkACL{
+		NetworkACLID: f.newID("acl"),
+		VpcID:        vpc.VpcID,
+		IsDefault:    true,
+	}
+	f.acls[acl.NetworkACLID] = acl
+
+	result := vpc.copy()
+	return &struct {
+		fakeMeta
+		Vpc *fakeVpc `xml:"vpc"`
+	}{Vpc: &result}, nil
+}
+
+// copy returns a copy of the VPC that doesn't change with it.
+func (vpc *fakeVpc) copy() fakeVpc {
+	c := *vpc
+	c.CidrBlockAssociationSet = append([]fakeCidrBlockAssociation(nil), vpc.CidrBlockAssociationSet...)
+	c.Ipv6CidrBlockAssociationSet = append([]fakeIpv6CidrBlockAssociation(nil), vpc.Ipv6CidrBlockAssociationSet...)
+	return c
+}
+
+// ipv6CidrBlock returns the IPv6 network of the VPC, or nil.
+func (vpc *fakeVpc) ipv6CidrBlock() *net.IPNet {
+	for _, a := range vpc.Ipv6CidrBlockAssociationSet {
+		if a.Ipv6CidrBlockState.State == "associated" || a.Ipv6CidrBlockState.State == "associating" {
+			_, network, _ := net.ParseCIDR(a.Ipv6CidrBlock)
+			return network
+		}
+	}
+	return nil
+}
+
+// cidrBlocks returns the IPv4 networks associated with the VPC.
+func (vpc *fakeVpc) cidrBlocks() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, a := range vpc.CidrBlockAssociationSet {
+		if a.CidrBlockState.State == "associated" || a.CidrBlockState.State == "associating" {
+			_, network, _ := net.ParseCIDR(a.CidrBlock)
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+// createDefaultVpc gives the fake the default VPC a region comes with:
+// 172.31.0.0/16, DNS hostnames on, and a default /20 subnet that maps
+// public IPs on launch in each of us-west-2a, b and c. It returns the ID
+// of the VPC.
+func (f *fakeEC2) createDefaultVpc() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result, err := f.createVpc(url.Values{"CidrBlock": {"172.31.0.0/16"}})
+	if err != nil {
+		panic(err)
+	}
+	vpc := f.vpcs[result.(*struct {
+		fakeMeta
+		Vpc *fakeVpc `xml:"vpc"`
+	}).Vpc.VpcID]
+	vpc.State = "available"
+	vpc.IsDefault = true
+	vpc.enableDNSHostnames = true
+
+	for i, az := range []string{"us-west-2a", "us-west-2b", "us-west-2c"} {
+		result, err := f.createSubnet(url.Values{
+			"VpcId":            {vpc.VpcID},
+			"CidrBlock":        {fmt.Sprintf("172.31.%d.0/20", i*16)},
+			"AvailabilityZone": {az},
+		})
+		if err != nil {
+			panic(err)
+		}
+		subnet := f.subnets[result.(*struct {
+			fakeMeta
+			Subnet *fakeSubnet `xml:"subnet"`
+		}).Subnet.SubnetID]
+		subnet.State = "available"
+		subnet.DefaultForAz = true
+		subnet.MapPublicIPOnLaunch = true
+	}
+	return vpc.VpcID
+}
+
+func (f *fakeEC2) vpc(id string) (*fakeVpc, error) {
+	vpc, ok := f.vpcs[id]
+	if !ok {
+		return nil, fakeErrorf("InvalidVpcID.NotFound", "The vpc ID '%s' does not exist", id)
+	}
+	return vpc, nil
+}
+
+func (f *fakeEC2) describeVpcs(p url.Values) (fakeResult, error) {
+	ids := fakeList(p, "VpcId")
+	for _, id := range ids {
+		if _, err := f.vpc(id); err != nil {
+			return nil, err
+		}
+	}
+	filters := fakeFilters(p)
+	if len(ids) > 0 {
+		filters["vpc-id"] = ids
+	}
+
+	result := &struct {
+		fakeMeta
+		Vpcs []fakeVpc `xml:"vpcSet>item"`
+	}{}
+	for _, id := range f.sortedIDs(f.vpcs) {
+		vpc := f.vpcs[id]
+		var associationIDs, cidrBlocks, states []string
+		for _, a := range vpc.CidrBlockAssociationSet {
+			associationIDs = append(associationIDs, a.AssociationID)
+			cidrBlocks = append(cidrBlocks, a.CidrBlock)
+			states = append(states, a.CidrBlockState.State)
+		}
+		var ipv6AssociationIDs, ipv6CidrBlocks []string
+		for _, a := range vpc.Ipv6CidrBlockAssociationSet {
+			ipv6AssociationIDs = append(ipv6AssociationIDs, a.AssociationID)
+			ipv6CidrBlocks = append(ipv6CidrBlocks, a.Ipv6CidrBlock)
+		}
+		values := map[string]string{
+			"vpc-id":                                vpc.VpcID,
+			"cidr":                                  vpc.CidrBlock,
+			"cidr-block":                            vpc.CidrBlock,
+			"state":                                 vpc.State,
+			"isDefault":                             strconv.FormatBool(vpc.IsDefault),
+			"is-default":                            strconv.FormatBool(vpc.IsDefault),
+			"cidr-block-association.association-id": strings.Join(associationIDs, ","),
+			"cidr-block-association.cidr-block":     strings.Join(cidrBlocks, ","),
+			"cidr-block-association.state":          strings.Join(states, ","),
+			"ipv6-cidr-block-association.association-id":  strings.Join(ipv6AssociationIDs, ","),
+			"ipv6-cidr-block-association.ipv6-cidr-block": strings.Join(ipv6CidrBlocks, ","),
+		}
+		if !fakeMatch(filters, values, f.tags[id]) {
+			continue
+		}
+		vpc.TagSet = f.tagSet(id)
+		result.Vpcs = append(result.Vpcs, vpc.copy())
+		vpc.State = "available"
+
+		// Blocks finish associating after one describe, and are gone one
+		// describe after they started disassociating.
+		associations := vpc.CidrBlockAssociationSet[:0]
+		for _, a := range vpc.CidrBlockAssociationSet {
+			if fakeAdvance(&a.CidrBlockState) {
+				associations = append(associations, a)
+			}
+		}
+		vpc.CidrBlockAssociationSet = associations
+		ipv6Associations := vpc.Ipv6CidrBlockAssociationSet[:0]
+		for _, a := range vpc.Ipv6CidrBlockAssociationSet {
+			if fakeAdvance(&a.Ipv6CidrBlockState) {
+				ipv6Associations = append(ipv6Associations, a)
+			}
+		}
+		vpc.Ipv6CidrBlockAssociationSet = ipv6Associations
+	}
+	return result, nil
+}
+
+func (f *fakeEC2) describeVpcAttribute(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "VpcId")
+	if err != nil {
+		return nil, err
+	}
+	vpc, err := f.vpc(id)
+	if err != nil {
+		return nil, err
+	}
+
+	type value struct {
+		Value bool `xml:"value"`
+	}
+	result := &struct {
+		fakeMeta
+		VpcID              string `xml:"vpcId"`
+		EnableDNSSupport   *value `xml:"enableDnsSupport,omitempty"`
+		EnableDNSHostnames *value `xml:"enableDnsHostnames,omitempty"`
+	}{VpcID: id}
+	switch attr := p.Get("Attribute"); attr {
+	case "enableDnsSupport":
+		result.EnableDNSSupport = &value{vpc.enableDNSSupport}
+	case "enableDnsHostnames":
+		result.EnableDNSHostnames = &value{vpc.enableDNSHostnames}
+	case "":
+		return nil, fakeErrorf("MissingParameter", "The request must contain the parameter attribute")
+	default:
+		return nil, fakeErrorf("InvalidParameterValue", "Value (%s) for parameter attribute is invalid. Unknown attribute.", attr)
+	}
+	return result, nil
+}
+
+func (f *fakeEC2) modifyVpcAttribute(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "VpcId")
+	if err != nil {
+		return nil, err
+	}
+	vpc, err := f.vpc(id)
+	if err != nil {
+		return nil, err
+	}
+
+	support, hasSupport, err := fakeBool(p, "EnableDnsSupport.Value")
+	if err != nil {
+		return nil, err
+	}
+	hostnames, hasHostnames, err := fakeBool(p, "EnableDnsHostnames.Value")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case hasSupport && hasHostnames:
+		return nil, fakeErrorf("InvalidParameterCombination", "Only one attribute can be modified at a time")
+	case hasSupport:
+		vpc.enableDNSSupport = support
+	case hasHostnames:
+		vpc.enableDNSHostnames = hostnames
+	default:
+		return nil, fakeErrorf("MissingParameter", "The request must contain the parameter EnableDnsSupport or EnableDnsHostnames")
+	}
+	return &fakeReturn{Return: true}, nil
+}
+
+func (f *fakeEC2) deleteVpc(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "VpcId")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.vpc(id); err != nil {
+		return nil, err
+	}
+
+	dependency := fakeErrorf("DependencyViolation", "The vpc '%s' has dependencies and cannot be deleted.", id)
+	for _, s := range f.subnets {
+		if s.VpcID == id {
+			return nil, dependency
+		}
+	}
+	for _, igw := range f.gateways {
+		for _, a := range igw.Attachments {
+			if a.VpcID == id {
+				return nil, dependency
+			}
+		}
+	}
+	for _, rt := range f.routeTables {
+		if rt.VpcID == id && !rt.isMain() {
+			return nil, dependency
+		}
+	}
+	for _, sg := range f.groups {
+		if sg.VpcID == id && sg.GroupName != "default" {
+			return nil, dependency
+		}
+	}
+
+	for rtID, rt := range f.routeTables {
+		if rt.VpcID == id {
+			delete(f.routeTables, rtID)
+			delete(f.tags, rtID)
+		}
+	}
+	for sgID, sg := range f.groups {
+		if sg.VpcID == id {
+			delete(f.groups, sgID)
+			delete(f.tags, sgID)
+		}
+	}
+	for aclID, acl := range f.acls {
+		if acl.VpcID == id {
+			delete(f.acls, aclID)
+			delete(f.tags, aclID)
+		}
+	}
+	delete(f.vpcs, id)
+	delete(f.tags, id)
+	return &fakeReturn{Return: true}, nil
+}
+
+func (f *fakeEC2) associateVpcCidrBlock(p url.Values) (fakeResult, error) {
+	vpcID, err := fakeRequired(p, "VpcId")
+	if err != nil {
+		return nil, err
+	}
+	vpc, err := f.vpc(vpcID)
+	if err != nil {
+		return nil, err
+	}
+	amazonIpv6, _, err := fakeBool(p, "AmazonProvidedIpv6CidrBlock")
+	if err != nil {
+		return nil, err
+	}
+	if amazonIpv6 {
+		if p.Get("CidrBlock") != "" {
+			return nil, fakeErrorf("InvalidParameterCombination", "Only one of cidrBlock and amazonProvidedIpv6CidrBlock can be specified")
+		}
+		return f.associateVpcIpv6CidrBlock(vpc)
+	}
+	cidr, err := fakeRequired(p, "CidrBlock")
+	if err != nil {
+		return nil, err
+	}
+
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil || network.String() != cidr {
+		return nil, fakeErrorf("InvalidParameterValue", "Value (%s) for parameter cidrBlock is invalid. This is not a valid CIDR block.", cidr)
+	}
+	if ones, _ := network.Mask.Size(); ones < 16 || ones > 28 {
+		return nil, fakeErrorf("InvalidVpc.Range", "The CIDR '%s' is invalid.", cidr)
+	}
+	blocks := vpc.cidrBlocks()
+	for _, other := range blocks {
+		if other.Contains(network.IP) || network.Contains(other.IP) {
+			return nil, fakeErrorf("CidrConflict", "The CIDR '%s' conflicts with another CIDR block of vpc '%s'", cidr, vpcID)
+		}
+	}
+	if len(blocks) >= 5 {
+		return nil, fakeErrorf("CidrLimitExceeded", "The vpc '%s' already has the maximum number of CIDR blocks", vpcID)
+	}
+
+	association := fakeCidrBlockAssociation{
+		AssociationID:  f.newID("vpc-cidr-assoc"),
+		CidrBlock:      cidr,
+		CidrBlockState: fakeCidrBlockState{State: "associating"},
+	}
+	vpc.CidrBlockAssociationSet = append(vpc.CidrBlockAssociationSet, association)
+
+	return &struct {
+		fakeMeta
+		VpcID                string                   `xml:"vpcId"`
+		CidrBlockAssociation fakeCidrBlockAssociation `xml:"cidrBlockAssociation"`
+	}{VpcID: vpcID, CidrBlockAssociation: association}, nil
+}
+
+// associateVpcIpv6CidrBlock gives the VPC a /56 from the fake's own range
+// and adds a local route for it to the VPC's route tables.
+func (f *fakeEC2) associateVpcIpv6CidrBlock(vpc *fakeVpc) (fakeResult, error) {
+	if vpc.ipv6CidrBlock() != nil {
+		return nil, fakeErrorf("CidrLimitExceeded", "The vpc '%s' already has an IPv6 CIDR block", vpc.VpcID)
+	}
+	f.lastID++
+	_, network, _ := net.ParseCIDR(fmt.Sprintf("2600:1f14:%x:%x00::/56", f.lastID>>8, f.lastID&0xff))
+
+	association := fakeIpv6CidrBlockAssociation{
+		AssociationID:      f.newID("vpc-cidr-assoc"),
+		Ipv6CidrBlock:      network.String(),
+		Ipv6CidrBlockState: fakeCidrBlockState{State: "associating"},
+	}
+	vpc.Ipv6CidrBlockAssociationSet = append(vpc.Ipv6CidrBlockAssociationSet, association)
+	for _, rt := range f.routeTables {
+		if rt.VpcID == vpc.VpcID {
+			rt.Routes = append(rt.Routes, fakeLocalIpv6Route(association.Ipv6CidrBlock))
+		}
+	}
+
+	return &struct {
+		fakeMeta
+		VpcID                    string                       `xml:"vpcId"`
+		Ipv6CidrBlockAssociation fakeIpv6CidrBlockAssociation `xml:"ipv6CidrBlockAssociation"`
+	}{VpcID: vpc.VpcID, Ipv6CidrBlockAssociation: association}, nil
+}
+
+func (f *fakeEC2) disassociateVpcCidrBlock(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "AssociationId")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, vpcID := range f.sortedIDs(f.vpcs) {
+		vpc := f.vpcs[vpcID]
+		for i := range vpc.Ipv6CidrBlockAssociationSet {
+			a := &vpc.Ipv6CidrBlockAssociationSet[i]
+			if a.AssociationID != id || a.Ipv6CidrBlockState.State != "associated" {
+				continue
+			}
+			for _, s := range f.subnets {
+				if s.VpcID == vpcID && s.ipv6CidrBlock() != nil {
+					return nil, fakeErrorf("DependencyViolation", "The IPv6 CIDR block '%s' has subnets and cannot be disassociated.", a.Ipv6CidrBlock)
+				}
+			}
+
+			a.Ipv6CidrBlockState.State = "disassociating"
+			for _, rt := range f.routeTables {
+				if rt.VpcID != vpcID {
+					continue
+				}
+				routes := rt.Routes[:0]
+				for _, r := range rt.Routes {
+					if r.DestinationIpv6CidrBlock != a.Ipv6CidrBlock {
+						routes = append(routes, r)
+					}
+				}
+				rt.Routes = routes
+			}
+			return &struct {
+				fakeMeta
+				VpcID                    string                       `xml:"vpcId"`
+				Ipv6CidrBlockAssociation fakeIpv6CidrBlockAssociation `xml:"ipv6CidrBlockAssociation"`
+			}{VpcID: vpcID, Ipv6CidrBlockAssociation: *a}, nil
+		}
+		for i := range vpc.CidrBlockAssociationSet {
+			a := &vpc.CidrBlockAssociationSet[i]
+			if a.AssociationID != id || a.CidrBlockState.State != "associated" {
+				continue
+			}
+			if a.CidrBlock == vpc.CidrBlock {
+				return nil, fakeErrorf("OperationNotPermitted", "The vpc CIDR block with association ID %s may not be disassociated. It is the primary IPv4 CIDR block of the VPC", id)
+			}
+			_, network, _ := net.ParseCIDR(a.CidrBlock)
+			for _, s := range f.subnets {
+				_, subnet, _ := net.ParseCIDR(s.CidrBlock)
+				if s.VpcID == vpcID && network.Contains(subnet.IP) {
+					return nil, fakeErrorf("DependencyViolation", "The CIDR block '%s' has subnets and cannot be disassociated.", a.CidrBlock)
+				}
+			}
+
+			a.CidrBlockState.State = "disassociating"
+			return &struct {
+				fakeMeta
+				VpcID                string                   `xml:"vpcId"`
+				CidrBlockAssociation fakeCidrBlockAssociation `xml:"cidrBlockAssociation"`
+			}{VpcID: vpcID, CidrBlockAssociation: *a}, nil
+		}
+	}
+	return nil, fakeErrorf("InvalidVpcCidrBlockAssociationID.NotFound", "The vpc CIDR block association ID '%s' does not exist", id)
+}
+
+// Subnets
+
+func (f *fakeEC2) createSubnet(p url.Values) (fakeResult, error) {
+	vpcID, err := fakeRequired(p, "VpcId")
+	if err != nil {
+		return nil, err
+	}
+	cidr, err := fakeRequired(p, "CidrBlock")
+	if err != nil {
+		return nil, err
+	}
+	vpc, err := f.vpc(vpcID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil || network.String() != cidr {
+		return nil, fakeErrorf("InvalidParameterValue", "Value (%s) for parameter cidrBlock is invalid. This is not a valid CIDR block.", cidr)
+	}
+	ones, bits := network.Mask.Size()
+	inRange := false
+	for _, vpcNetwork := range vpc.cidrBlocks() {
+		vpcOnes, _ := vpcNetwork.Mask.Size()
+		if vpcNetwork.Contains(network.IP) && ones >= vpcOnes && ones <= 28 {
+			inRange = true
+		}
+	}
+	if !inRange {
+		return nil, fakeErrorf("InvalidSubnet.Range", "The CIDR '%s' is invalid.", cidr)
+	}
+	for _, s := range f.subnets {
+		if s.VpcID != vpcID {
+			continue
+		}
+		_, other, _ := net.ParseCIDR(s.CidrBlock)
+		if other.Contains(network.IP) || network.Contains(other.IP) {
+			return nil, fakeErrorf("InvalidSubnet.Conflict", "The CIDR '%s' conflicts with another subnet", cidr)
+		}
+	}
+
+	az := p.Get("AvailabilityZone")
+	if az == "" {
+		az = "us-west-2a"
+	}
+	subnet := &fakeSubnet{
+		SubnetID:                f.newID("subnet"),
+		State:                   "pending",
+		VpcID:                   vpcID,
+		CidrBlock:               cidr,
+		AvailableIPAddressCount: 1<<uint(bits-ones) - 5,
+		AvailabilityZone:        az,
+	}
+	f.subnets[subnet.SubnetID] = subnet
+
+	result := *subnet
+	return &struct {
+		fakeMeta
+		Subnet *fakeSubnet `xml:"subnet"`
+	}{Subnet: &result}, nil
+}
+
+func (f *fakeEC2) subnet(id string) (*fakeSubnet, error) {
+	subnet, ok := f.subnets[id]
+	if !ok {
+		return nil, fakeErrorf("InvalidSubnetID.NotFound", "The subnet ID '%s' does not exist", id)
+	}
+	return subnet, nil
+}
+
+func (f *fakeEC2) describeSubnets(p url.Values) (fakeResult, error) {
+	ids := fakeList(p, "SubnetId")
+	for _, id := range ids {
+		if _, err := f.subnet(id); err != nil {
+			return nil, err
+		}
+	}
+	filters := fakeFilters(p)
+	if len(ids) > 0 {
+		filters["subnet-id"] = ids
+	}
+
+	result := &struct {
+		fakeMeta
+		Subnets []fakeSubnet `xml:"subnetSet>item"`
+	}{}
+	for _, id := range f.sortedIDs(f.subnets) {
+		subnet := f.subnets[id]
+		values := map[string]string{
+			"subnet-id":         subnet.SubnetID,
+			"vpc-id":            subnet.VpcID,
+			"cidr":              subnet.CidrBlock,
+			"cidr-block":        subnet.CidrBlock,
+			"availability-zone": subnet.AvailabilityZone,
+			"state":             subnet.State,
+			"default-for-az":    strconv.FormatBool(subnet.DefaultForAz),
+		}
+		if !fakeMatch(filters, values, f.tags[id]) {
+			continue
+		}
+		subnet.TagSet = f.tagSet(id)
+		result.Subnets = append(result.Subnets, subnet.copy())
+		subnet.State = "available"
+
+		associations := subnet.Ipv6CidrBlockAssociationSet[:0]
+		for _, a := range subnet.Ipv6CidrBlockAssociationSet {
+			if fakeAdvance(&a.Ipv6CidrBlockState) {
+				associations = append(associations, a)
+			}
+		}
+		subnet.Ipv6CidrBlockAssociationSet = associations
+	}
+	return result, nil
+}
+
+func (f *fakeEC2) modifySubnetAttribute(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "SubnetId")
+	if err != nil {
+		return nil, err
+	}
+	subnet, err := f.subnet(id)
+	if err != nil {
+		return nil, err
+	}
+
+	mapPublic, hasMapPublic, err := fakeBool(p, "MapPublicIpOnLaunch.Value")
+	if err != nil {
+		return nil, err
+	}
+	assignIpv6, hasAssignIpv6, err := fakeBool(p, "AssignIpv6AddressOnCreation.Value")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case hasMapPublic && hasAssignIpv6:
+		return nil, fakeErrorf("InvalidParameterCombination", "Only one attribute can be modified at a time")
+	case hasMapPublic:
+		subnet.MapPublicIPOnLaunch = mapPublic
+	case hasAssignIpv6:
+		subnet.AssignIpv6AddressOnCreation = assignIpv6
+	default:
+		return nil, fakeErrorf("MissingParameter", "The request must contain the parameter MapPublicIpOnLaunch or AssignIpv6AddressOnCreation")
+	}
+	return &fakeReturn{Return: true}, nil
+}
+
+func (f *fakeEC2) associateSubnetCidrBlock(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "SubnetId")
+	if err != nil {
+		return nil, err
+	}
+	cidr, err := fakeRequired(p, "Ipv6CidrBlock")
+	if err != nil {
+		return nil, err
+	}
+	subnet, err := f.subnet(id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil || network.IP.To4() != nil || network.String() != cidr {
+		return nil, fakeErrorf("InvalidParameterValue", "Value (%s) for parameter ipv6CidrBlock is invalid. This is not a valid IPv6 CIDR block.", cidr)
+	}
+	vpcNetwork := f.vpcs[subnet.VpcID].ipv6CidrBlock()
+	if ones, _ := network.Mask.Size(); vpcNetwork == nil || !vpcNetwork.Contains(network.IP) || ones != 64 {
+		return nil, fakeErrorf("InvalidSubnet.Range", "The IPv6 CIDR '%s' is invalid.", cidr)
+	}
+	if subnet.ipv6CidrBlock() != nil {
+		return nil, fakeErrorf("CidrLimitExceeded", "The subnet '%s' already has an IPv6 CIDR block", id)
+	}
+	for _, s := range f.subnets {
+		if other := s.ipv6CidrBlock(); other != nil && other.String() == cidr {
+			return nil, fakeErrorf("InvalidSubnet.Conflict", "The CIDR '%s' conflicts with another subnet", cidr)
+		}
+	}
+
+	association := fakeIpv6CidrBlockAssociation{
+		AssociationID:      f.newID("subnet-cidr-assoc"),
+		Ipv6CidrBlock:      cidr,
+		Ipv6CidrBlockState: fakeCidrBlockState{State: "associating"},
+	}
+	subnet.Ipv6CidrBlockAssociationSet = append(subnet.Ipv6CidrBlockAssociationSet, association)
+	return &struct {
+		fakeMeta
+		SubnetID                 string                       `xml:"subnetId"`
+		Ipv6CidrBlockAssociation fakeIpv6CidrBlockAssociation `xml:"ipv6CidrBlockAssociation"`
+	}{SubnetID: id, Ipv6CidrBlockAssociation: association}, nil
+}
+
+func (f *fakeEC2) disassociateSubnetCidrBlock(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "AssociationId")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, subnet := range f.subnets {
+		for i := range subnet.Ipv6CidrBlockAssociationSet {
+			a := &subnet.Ipv6CidrBlockAssociationSet[i]
+			if a.AssociationID != id || a.Ipv6CidrBlockState.State != "associated" {
+				continue
+			}
+			a.Ipv6CidrBlockState.State = "disassociating"
+			return &struct {
+				fakeMeta
+				SubnetID                 string                       `xml:"subnetId"`
+				Ipv6CidrBlockAssociation fakeIpv6CidrBlockAssociation `xml:"ipv6CidrBlockAssociation"`
+			}{SubnetID: subnet.SubnetID, Ipv6CidrBlockAssociation: *a}, nil
+		}
+	}
+	return nil, fakeErrorf("InvalidSubnetCidrBlockAssociationID.NotFound", "The subnet CIDR block association ID '%s' does not exist", id)
+}
+
+func (f *fakeEC2) deleteSubnet(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "SubnetId")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.subnet(id); err != nil {
+		return nil, err
+	}
+	for _, eni := range f.interfaces {
+		if eni.SubnetID == id {
+			return nil, fakeErrorf("DependencyViolation", "The subnet '%s' has dependencies and cannot be deleted.", id)
+		}
+	}
+
+	// Deleting a subnet implicitly removes its route table association.
+	for _, rt := range f.routeTables {
+		for i, a := range rt.Associations {
+			if a.SubnetID == id {
+				rt.Associations = append(rt.Associations[:i], rt.Associations[i+1:]...)
+				break
+			}
+		}
+	}
+	delete(f.subnets, id)
+	delete(f.tags, id)
+	return &fakeReturn{Return: true}, nil
+}
+
+// Route tables
+
+func fakeLocalRoute(cidr string) fakeRoute {
+	return fakeRoute{
+		DestinationCidrBlock: cidr,
+		GatewayID:            "local",
+		State:                "active",
+		Origin:               "CreateRouteTable",
+	}
+}
+
+func fakeLocalIpv6Route(cidr string) fakeRoute {
+	return fakeRoute{
+		DestinationIpv6CidrBlock: cidr,
+		GatewayID:                "local",
+		State:                    "active",
+		Origin:                   "CreateRouteTable",
+	}
+}
+
+func (rt *fakeRouteTable) isMain() bool {
+	for _, a := range rt.Associations {
+		if a.Main {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeEC2) createRouteTable(p url.Values) (fakeResult, error) {
+	vpcID, err := fakeRequired(p, "VpcId")
+	if err != nil {
+		return nil, err
+	}
+	vpc, err := f.vpc(vpcID)
+	if err != nil {
+		return nil, err
+	}
+
+	rt := &fakeRouteTable{
+		RouteTableID: f.newID("rtb"),
+		VpcID:        vpcID,
+		Routes:       []fakeRoute{fakeLocalRoute(vpc.CidrBlock)},
+	}
+	if ipv6 := vpc.ipv6CidrBlock(); ipv6 != nil {
+		rt.Routes = append(rt.Routes, fakeLocalIpv6Route(ipv6.String()))
+	}
+	f.routeTables[rt.RouteTableID] = rt
+
+	result := *rt
+	return &struct {
+		fakeMeta
+		RouteTable *fakeRouteTable `xml:"routeTable"`
+	}{RouteTable: &result}, nil
+}
+
+func (f *fakeEC2) routeTable(id string) (*fakeRouteTable, error) {
+	rt, ok := f.routeTables[id]
+	if !ok {
+		return nil, fakeErrorf("InvalidRouteTableID.NotFound", "The routeTable ID '%s' does not exist", id)
+	}
+	return rt, nil
+}
+
+func (f *fakeEC2) describeRouteTables(p url.Values) (fakeResult, error) {
+	ids := fakeList(p, "RouteTableId")
+	for _, id := range ids {
+		if _, err := f.routeTable(id); err != nil {
+			return nil, err
+		}
+	}
+	filters := fakeFilters(p)
+	if len(ids) > 0 {
+		filters["route-table-id"] = ids
+	}
+
+	result := &struct {
+		fakeMeta
+		RouteTables []fakeRouteTable `xml:"routeTableSet>item"`
+	}{}
+	for _, id := range f.sortedIDs(f.routeTables) {
+		rt := f.routeTables[id]
+		var subnets, associations []string
+		for _, a := range rt.Associations {
+			associations = append(associations, a.RouteTableAssociationID)
+			if a.SubnetID != "" {
+				subnets = append(subnets, a.SubnetID)
+			}
+		}
+		values := map[string]string{
+			"route-table-id":                         rt.RouteTableID,
+			"vpc-id":                                 rt.VpcID,
+			"association.main":                       strconv.FormatBool(rt.isMain()),
+			"association.subnet-id":                  strings.Join(subnets, ","),
+			"association.route-table-association-id": strings.Join(associations, ","),
+		}
+		if !fakeMatch(filters, values, f.tags[id]) {
+			continue
+		}
+		rt.TagSet = f.tagSet(id)
+		result.RouteTables = append(result.RouteTables, *rt)
+	}
+	return result, nil
+}
+
+func (f *fakeEC2) deleteRouteTable(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "RouteTableId")
+	if err != nil {
+		return nil, err
+	}
+	rt, err := f.routeTable(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rt.Associations) > 0 {
+		return nil, fakeErrorf("DependencyViolation", "The routeTable '%s' has dependencies and cannot be deleted.", id)
+	}
+
+	delete(f.routeTables, id)
+	delete(f.tags, id)
+	return &fakeReturn{Return: true}, nil
+}
+
+// fakeRouteDestination reads the destination of a CreateRoute or
+// DeleteRoute call, which is either an IPv4 or an IPv6 CIDR block.
+func fakeRouteDestination(p url.Values) (string, bool, error) {
+	dest, ipv6Dest := p.Get("DestinationCidrBlock"), p.Get("DestinationIpv6CidrBlock")
+	switch {
+	case dest != "" && ipv6Dest != "":
+		return "", false, fakeErrorf("InvalidParameterCombination", "Only one of destinationCidrBlock and destinationIpv6CidrBlock can be specified")
+	case ipv6Dest != "":
+		return ipv6Dest, true, nil
+	case dest != "":
+		return dest, false, nil
+	}
+	return "", false, fakeErrorf("MissingParameter", "The request must contain the parameter destinationCidrBlock or destinationIpv6CidrBlock")
+}
+
+// destination returns the IPv4 or IPv6 destination of the route.
+func (r fakeRoute) destination() string {
+	if r.DestinationIpv6CidrBlock != "" {
+		return r.DestinationIpv6CidrBlock
+	}
+	return r.DestinationCidrBlock
+}
+
+func (f *fakeEC2) createRoute(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "RouteTableId")
+	if err != nil {
+		return nil, err
+	}
+	dest, ipv6, err := fakeRouteDestination(p)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := f.routeTable(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, network, err := net.ParseCIDR(dest); err != nil || network.String() != dest || (network.IP.To4() == nil) != ipv6 {
+		return nil, fakeErrorf("InvalidParameterValue", "Value (%s) for parameter destinationCidrBlock is invalid. This is not a valid CIDR block.", dest)
+	}
+
+	route := fakeRoute{
+		GatewayID:  p.Get("GatewayId"),
+		InstanceID: p.Get("InstanceId"),
+		State:      "active",
+		Origin:     "CreateRoute",
+	}
+	if ipv6 {
+		route.DestinationIpv6CidrBlock = dest
+	} else {
+		route.DestinationCidrBlock = dest
+	}
+	switch {
+	case route.GatewayID != "" && route.InstanceID != "":
+		return nil, fakeErrorf("InvalidParameterCombination", "Only one of gatewayId and instanceId can be specified")
+	case route.GatewayID != "":
+		igw, ok := f.gateways[route.GatewayID]
+		if !ok {
+			return nil, fakeErrorf("InvalidGatewayID.NotFound", "The gateway ID '%s' does not exist", route.GatewayID)
+		}
+		if !igw.attachedTo(rt.VpcID) {
+			return nil, fakeErrorf("InvalidParameterValue", "route table %s and network gateway %s belong to different networks", id, route.GatewayID)
+		}
+	case route.InstanceID != "":
+		return nil, fakeErrorf("InvalidInstanceID.NotFound", "The instance ID '%s' does not exist", route.InstanceID)
+	default:
+		return nil, fakeErrorf("MissingParameter", "The request must contain the parameter gatewayId or instanceId")
+	}
+	for _, r := range rt.Routes {
+		if r.destination() == dest {
+			return nil, fakeErrorf("RouteAlreadyExists", "The route identified by %s already exists.", dest)
+		}
+	}
+
+	rt.Routes = append(rt.Routes, route)
+	return &fakeReturn{Return: true}, nil
+}
+
+func (f *fakeEC2) deleteRoute(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "RouteTableId")
+	if err != nil {
+		return nil, err
+	}
+	dest, _, err := fakeRouteDestination(p)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := f.routeTable(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, r := range rt.Routes {
+		if r.destination() != dest {
+			continue
+		}
+		if r.GatewayID == "local" {
+			return nil, fakeErrorf("InvalidParameterValue", "cannot remove local route %s in route table %s", dest, id)
+		}
+		rt.Routes = append(rt.Routes[:i], rt.Routes[i+1:]...)
+		return &fakeReturn{Return: true}, nil
+	}
+	return nil, fakeErrorf("InvalidRoute.NotFound", "no route with destination-cidr-block %s in route table %s", dest, id)
+}
+
+// association returns the route table and index of an association.
+func (f *fakeEC2) association(id string) (*fakeRouteTable, int, error) {
+	for _, rt := range f.routeTables {
+		for i, a := range rt.Associations {
+			if a.RouteTableAssociationID == id {
+				return rt, i, nil
+			}
+		}
+	}
+	return nil, 0, fakeErrorf("InvalidAssociationID.NotFound", "The association ID '%s' does not exist", id)
+}
+
+func (f *fakeEC2) associateRouteTable(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "RouteTableId")
+	if err != nil {
+		return nil, err
+	}
+	subnetID, err := fakeRequired(p, "SubnetId")
+	if err != nil {
+		return nil, err
+	}
+	rt, err := f.routeTable(id)
+	if err != nil {
+		return nil, err
+	}
+	subnet, err := f.subnet(subnetID)
+	if err != nil {
+		return nil, err
+	}
+	if subnet.VpcID != rt.VpcID {
+		return nil, fakeErrorf("InvalidParameterValue", "Route table %s and subnet %s belong to different networks", id, subnetID)
+	}
+	for _, other := range f.routeTables {
+		for _, a := range other.Associations {
+			if a.SubnetID == subnetID {
+				return nil, fakeErrorf("Resource.AlreadyAssociated", "the specified association for route table %s conflicts with an existing association", id)
+			}
+		}
+	}
+
+	a := fakeRouteTableAssociation{
+		RouteTableAssociationID: f.newID("rtbassoc"),
+		RouteTableID:            id,
+		SubnetID:                subnetID,
+	}
+	rt.Associations = append(rt.Associations, a)
+	return &struct {
+		fakeMeta
+		AssociationID string `xml:"associationId"`
+	}{AssociationID: a.RouteTableAssociationID}, nil
+}
+
+func (f *fakeEC2) replaceRouteTableAssociation(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "AssociationId")
+	if err != nil {
+		return nil, err
+	}
+	rtID, err := fakeRequired(p, "RouteTableId")
+	if err != nil {
+		return nil, err
+	}
+	target, err := f.routeTable(rtID)
+	if err != nil {
+		return nil, err
+	}
+	rt, i, err := f.association(id)
+	if err != nil {
+		return nil, err
+	}
+	if target.VpcID != rt.VpcID {
+		return nil, fakeErrorf("InvalidParameterValue", "Route table %s belongs to a different network", rtID)
+	}
+
+	a := rt.Associations[i]
+	rt.Associations = append(rt.Associations[:i], rt.Associations[i+1:]...)
+	a.RouteTableAssociationID = f.newID("rtbassoc")
+	a.RouteTableID = rtID
+	target.Associations = append(target.Associations, a)
+	return &struct {
+		fakeMeta
+		NewAssociationID string `xml:"newAssociationId"`
+	}{NewAssociationID: a.RouteTableAssociationID}, nil
+}
+
+func (f *fakeEC2) disassociateRouteTable(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "AssociationId")
+	if err != nil {
+		return nil, err
+	}
+	rt, i, err := f.association(id)
+	if err != nil {
+		return nil, err
+	}
+	if rt.Associations[i].Main {
+		return nil, fakeErrorf("InvalidParameterValue", "cannot disassociate the main route table association %s", id)
+	}
+
+	rt.Associations = append(rt.Associations[:i], rt.Associations[i+1:]...)
+	return &fakeReturn{Return: true}, nil
+}
+
+// Network ACLs
+
+func (f *fakeEC2) describeNetworkAcls(p url.Values) (fakeResult, error) {
+	ids := fakeList(p, "NetworkAclId")
+	for _, id := range ids {
+		if _, ok := f.acls[id]; !ok {
+			return nil, fakeErrorf("InvalidNetworkAclID.NotFound", "The network ACL '%s' does not exist", id)
+		}
+	}
+	filters := fakeFilters(p)
+	if len(ids) > 0 {
+		filters["network-acl-id"] = ids
+	}
+
+	result := &struct {
+		fakeMeta
+		NetworkACLs []fakeNetworkACL `xml:"networkAclSet>item"`
+	}{}
+	for _, id := range f.sortedIDs(f.acls) {
+		acl := f.acls[id]
+		values := map[string]string{
+			"network-acl-id": acl.NetworkACLID,
+			"vpc-id":         acl.VpcID,
+			"default":        strconv.FormatBool(acl.IsDefault),
+		}
+		if !fakeMatch(filters, values, f.tags[id]) {
+			continue
+		}
+		acl.TagSet = f.tagSet(id)
+		result.NetworkACLs = append(result.NetworkACLs, *acl)
+	}
+	return result, nil
+}
+
+// Internet gateways
+
+func (igw *fakeInternetGateway) attachedTo(vpcID string) bool {
+	for _, a := range igw.Attachments {
+		if a.VpcID == vpcID {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeEC2) createInternetGateway(p url.Values) (fakeResult, error) {
+	igw := &fakeInternetGateway{InternetGatewayID: f.newID("igw")}
+	f.gateways[igw.InternetGatewayID] = igw
+
+	result := *igw
+	return &struct {
+		fakeMeta
+		InternetGateway *fakeInternetGateway `xml:"internetGateway"`
+	}{InternetGateway: &result}, nil
+}
+
+func (f *fakeEC2) internetGateway(id string) (*fakeInternetGateway, error) {
+	igw, ok := f.gateways[id]
+	if !ok {
+		return nil, fakeErrorf("InvalidInternetGatewayID.NotFound", "The internetGateway ID '%s' does not exist", id)
+	}
+	return igw, nil
+}
+
+func (f *fakeEC2) describeInternetGateways(p url.Values) (fakeResult, error) {
+	ids := fakeList(p, "InternetGatewayId")
+	for _, id := range ids {
+		if _, err := f.internetGateway(id); err != nil {
+			return nil, err
+		}
+	}
+	filters := fakeFilters(p)
+	if len(ids) > 0 {
+		filters["internet-gateway-id"] = ids
+	}
+
+	result := &struct {
+		fakeMeta
+		InternetGateways []fakeInternetGateway `xml:"internetGatewaySet>item"`
+	}{}
+	for _, id := range f.sortedIDs(f.gateways) {
+		igw := f.gateways[id]
+		var vpcs, states []string
+		for _, a := range igw.Attachments {
+			vpcs = append(vpcs, a.VpcID)
+			states = append(states, a.State)
+		}
+		values := map[string]string{
+			"internet-gateway-id": igw.InternetGatewayID,
+			"attachment.vpc-id":   strings.Join(vpcs, ","),
+			"attachment.state":    strings.Join(states, ","),
+		}
+		if !fakeMatch(filters, values, f.tags[id]) {
+			continue
+		}
+		igw.TagSet = f.tagSet(id)
+		out := *igw
+		out.Attachments = append([]fakeAttachment(nil), igw.Attachments...)
+		result.InternetGateways = append(result.InternetGateways, out)
+
+		// Attaching and detaching take one describe to settle.
+		var settled []fakeAttachment
+		for _, a := range igw.Attachments {
+			switch a.State {
+			case "attaching":
+				a.State = "available"
+			case "detaching":
+				continue
+			}
+			settled = append(settled, a)
+		}
+		igw.Attachments = settled
+	}
+	return result, nil
+}
+
+func (f *fakeEC2) attachInternetGateway(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "InternetGatewayId")
+	if err != nil {
+		return nil, err
+	}
+	vpcID, err := fakeRequired(p, "VpcId")
+	if err != nil {
+		return nil, err
+	}
+	igw, err := f.internetGateway(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.vpc(vpcID); err != nil {
+		return nil, err
+	}
+	if len(igw.Attachments) > 0 {
+		return nil, fakeErrorf("Resource.AlreadyAssociated", "resource %s is already attached to network %s", id, igw.Attachments[0].VpcID)
+	}
+	for _, other := range f.gateways {
+		if other.attachedTo(vpcID) {
+			return nil, fakeErrorf("Resource.AlreadyAssociated", "network %s already has an internet gateway attached", vpcID)
+		}
+	}
+
+	igw.Attachments = []fakeAttachment{{VpcID: vpcID, State: "attaching"}}
+	return &fakeReturn{Return: true}, nil
+}
+
+func (f *fakeEC2) detachInternetGateway(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "InternetGatewayId")
+	if err != nil {
+		return nil, err
+	}
+	vpcID, err := fakeRequired(p, "VpcId")
+	if err != nil {
+		return nil, err
+	}
+	igw, err := f.internetGateway(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.vpc(vpcID); err != nil {
+		return nil, err
+	}
+	if !igw.attachedTo(vpcID) {
+		return nil, fakeErrorf("Gateway.NotAttached", "resource %s is not attached to network %s", id, vpcID)
+	}
+	// Routes through a detached gateway are kept but go nowhere.
+	for _, rt := range f.routeTables {
+		for i := range rt.Routes {
+			if rt.Routes[i].GatewayID == id {
+				rt.Routes[i].State = "blackhole"
+			}
+		}
+	}
+
+	igw.Attachments = []fakeAttachment{{VpcID: vpcID, State: "detaching"}}
+	return &fakeReturn{Return: true}, nil
+}
+
+func (f *fakeEC2) deleteInternetGateway(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "InternetGatewayId")
+	if err != nil {
+		return nil, err
+	}
+	igw, err := f.internetGateway(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(igw.Attachments) > 0 {
+		return nil, fakeErrorf("DependencyViolation", "The internetGateway '%s' has dependencies and cannot be deleted.", id)
+	}
+
+	delete(f.gateways, id)
+	delete(f.tags, id)
+	return &fakeReturn{Return: true}, nil
+}
+
+// Security groups
+
+func (f *fakeEC2) createSecurityGroup(p url.Values) (fakeResult, error) {
+	name, err := fakeRequired(p, "GroupName")
+	if err != nil {
+		return nil, err
+	}
+	description, err := fakeRequired(p, "GroupDescription")
+	if err != nil {
+		return nil, err
+	}
+	vpcID := p.Get("VpcId")
+	if vpcID != "" {
+		if _, err := f.vpc(vpcID); err != nil {
+			return nil, err
+		}
+	}
+	if name == "default" {
+		return nil, fakeErrorf("InvalidParameterValue", "Cannot use reserved security group name: default")
+	}
+	for _, sg := range f.groups {
+		if sg.VpcID == vpcID && sg.GroupName == name {
+			return nil, fakeErrorf("InvalidGroup.Duplicate", "The security group '%s' already exists for VPC '%s'", name, vpcID)
+		}
+	}
+
+	sg := &fakeSecurityGroup{
+		OwnerID:          fakeEC2OwnerID,
+		GroupID:          f.newID("sg"),
+		GroupName:        name,
+		GroupDescription: description,
+		VpcID:            vpcID,
+	}
+	if vpcID != "" {
+		sg.IPPermissionsEgress = []fakeIPPermission{{
+			IPProtocol: "-1",
+			IPRanges:   []fakeIPRange{{CidrIP: "0.0.0.0/0"}},
+		}}
+	}
+	f.groups[sg.GroupID] = sg
+
+	return &struct {
+		fakeMeta
+		Return  bool   `xml:"return"`
+		GroupID string `xml:"groupId"`
+	}{Return: true, GroupID: sg.GroupID}, nil
+}
+
+func (f *fakeEC2) securityGroup(id string) (*fakeSecurityGroup, error) {
+	sg, ok := f.groups[id]
+	if !ok {
+		return nil, fakeErrorf("InvalidGroup.NotFound", "The security group '%s' does not exist", id)
+	}
+	return sg, nil
+}
+
+func (f *fakeEC2) describeSecurityGroups(p url.Values) (fakeResult, error) {
+	ids := fakeList(p, "GroupId")
+	for _, id := range ids {
+		if _, err := f.securityGroup(id); err != nil {
+			return nil, err
+		}
+	}
+	filters := fakeFilters(p)
+	if len(ids) > 0 {
+		filters["group-id"] = ids
+	}
+	if names := fakeList(p, "GroupName"); len(names) > 0 {
+		filters["group-name"] = names
+	}
+
+	result := &struct {
+		fakeMeta
+		SecurityGroups []fakeSecurityGroup `xml:"securityGroupInfo>item"`
+	}{}
+	for _, id := range f.sortedIDs(f.groups) {
+		sg := f.groups[id]
+		values := map[string]string{
+			"group-id":   sg.GroupID,
+			"group-name": sg.GroupName,
+			"vpc-id":     sg.VpcID,
+			"owner-id":   sg.OwnerID,
+		}
+		if !fakeMatch(filters, values, f.tags[id]) {
+			continue
+		}
+		sg.TagSet = f.tagSet(id)
+		result.SecurityGroups = append(result.SecurityGroups, *sg)
+	}
+	return result, nil
+}
+
+// fakeRule is a single source of a permission: one CIDR or one group.
+type fakeRule struct {
+	Protocol string
+	FromPort int
+	ToPort   int
+	CidrIP   string
+	GroupID  string
+}
+
+// expandRules splits the IpPermissions.N parameters into single-source
+// rules.
+func (f *fakeEC2) expandRules(p url.Values) ([]fakeRule, error) {
+	var rules []fakeRule
+	for _, m := range fakeMembers(p, "IpPermissions") {
+		protocol, err := fakeRequired(m, "IpProtocol")
+		if err != nil {
+			return nil, err
+		}
+		var from, to int
+		if protocol != "-1" {
+			if from, err = strconv.Atoi(m.Get("FromPort")); err != nil {
+				return nil, fakeErrorf("InvalidParameterValue", "Invalid value '%s' for fromPort", m.Get("FromPort"))
+			}
+			if to, err = strconv.Atoi(m.Get("ToPort")); err != nil {
+				return nil, fakeErrorf("InvalidParameterValue", "Invalid value '%s' for toPort", m.Get("ToPort"))
+			}
+		}
+
+		sources := 0
+		for _, r := range fakeMembers(m, "IpRanges") {
+			cidr := r.Get("CidrIp")
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return nil, fakeErrorf("InvalidParameterValue", "CIDR block %s is malformed", cidr)
+			}
+			rules = append(rules, fakeRule{protocol, from, to, cidr, ""})
+			sources++
+		}
+		for _, g := range fakeMembers(m, "Groups") {
+			groupID := g.Get("GroupId")
+			if _, err := f.securityGroup(groupID); err != nil {
+				return nil, err
+			}
+			rules = append(rules, fakeRule{protocol, from, to, "", groupID})
+			sources++
+		}
+		if sources == 0 {
+			return nil, fakeErrorf("MissingParameter", "The request must contain the parameter ipRanges or groups")
+		}
+	}
+	if len(rules) == 0 {
+		return nil, fakeErrorf("MissingParameter", "The request must contain the parameter ipPermissions")
+	}
+	return rules, nil
+}
+
+// findRule returns the index of the permission with the rule's protocol
+// and ports, and whether the rule's source is already in it.
+func (sg *fakeSecurityGroup) findRule(r fakeRule) (int, bool) {
+	for i, perm := range sg.IPPermissions {
+		if perm.IPProtocol != r.Protocol || perm.FromPort != r.FromPort || perm.ToPort != r.ToPort {
+			continue
+		}
+		for _, ipRange := range perm.IPRanges {
+			if r.CidrIP != "" && ipRange.CidrIP == r.CidrIP {
+				return i, true
+			}
+		}
+		for _, g := range perm.Groups {
+			if r.GroupID != "" && g.GroupID == r.GroupID {
+				return i, true
+			}
+		}
+		return i, false
+	}
+	return -1, false
+}
+
+func (f *fakeEC2) authorizeSecurityGroupIngress(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "GroupId")
+	if err != nil {
+		return nil, err
+	}
+	sg, err := f.securityGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := f.expandRules(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		if _, found := sg.findRule(r); found {
+			return nil, fakeErrorf("InvalidPermission.Duplicate", "the specified rule \"peer: %s%s, %s, from port: %d, to port: %d, ALLOW\" already exists",
+				r.CidrIP, r.GroupID, r.Protocol, r.FromPort, r.ToPort)
+		}
+	}
+
+	for _, r := range rules {
+		i, _ := sg.findRule(r)
+		if i < 0 {
+			sg.IPPermissions = append(sg.IPPermissions, fakeIPPermission{
+				IPProtocol: r.Protocol,
+				FromPort:   r.FromPort,
+				ToPort:     r.ToPort,
+			})
+			i = len(sg.IPPermissions) - 1
+		}
+		perm := &sg.IPPermissions[i]
+		if r.CidrIP != "" {
+			perm.IPRanges = append(perm.IPRanges, fakeIPRange{CidrIP: r.CidrIP})
+		} else {
+			perm.Groups = append(perm.Groups, fakeGroupPair{UserID: fakeEC2OwnerID, GroupID: r.GroupID})
+		}
+	}
+	return &fakeReturn{Return: true}, nil
+}
+
+func (f *fakeEC2) revokeSecurityGroupIngress(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "GroupId")
+	if err != nil {
+		return nil, err
+	}
+	sg, err := f.securityGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := f.expandRules(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		if _, found := sg.findRule(r); !found {
+			return nil, fakeErrorf("InvalidPermission.NotFound", "The specified rule does not exist in this security group.")
+		}
+	}
+
+	for _, r := range rules {
+		i, _ := sg.findRule(r)
+		perm := &sg.IPPermissions[i]
+		var ranges []fakeIPRange
+		for _, ipRange := range perm.IPRanges {
+			if ipRange.CidrIP != r.CidrIP {
+				ranges = append(ranges, ipRange)
+			}
+		}
+		var groups []fakeGroupPair
+		for _, g := range perm.Groups {
+			if g.GroupID != r.GroupID {
+				groups = append(groups, g)
+			}
+		}
+		perm.IPRanges, perm.Groups = ranges, groups
+		if len(ranges) == 0 && len(groups) == 0 {
+			sg.IPPermissions = append(sg.IPPermissions[:i], sg.IPPermissions[i+1:]...)
+		}
+	}
+	return &fakeReturn{Return: true}, nil
+}
+
+func (f *fakeEC2) deleteSecurityGroup(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "GroupId")
+	if err != nil {
+		return nil, err
+	}
+	sg, err := f.securityGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	if sg.GroupName == "default" {
+		return nil, fakeErrorf("CannotDelete", "the specified group: \"%s\" name: \"default\" cannot be deleted by a user", id)
+	}
+	for _, other := range f.groups {
+		if other.GroupID == id {
+			continue
+		}
+		for _, perm := range other.IPPermissions {
+			for _, g := range perm.Groups {
+				if g.GroupID == id {
+					return nil, fakeErrorf("DependencyViolation", "resource %s has a dependent object", id)
+				}
+			}
+		}
+	}
+	for _, eni := range f.interfaces {
+		for _, g := range eni.Groups {
+			if g.GroupID == id {
+				return nil, fakeErrorf("DependencyViolation", "resource %s has a dependent object", id)
+			}
+		}
+	}
+
+	delete(f.groups, id)
+	delete(f.tags, id)
+	return &fakeReturn{Return: true}, nil
+}
+
+// Network interfaces
+
+func (f *fakeEC2) createNetworkInterface(p url.Values) (fakeResult, error) {
+	subnetID, err := fakeRequired(p, "SubnetId")
+	if err != nil {
+		return nil, err
+	}
+	subnet, err := f.subnet(subnetID)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []fakeGroupIdentifier
+	for _, id := range fakeList(p, "SecurityGroupId") {
+		sg, err := f.securityGroup(id)
+		if err != nil {
+			return nil, err
+		}
+		if sg.VpcID != subnet.VpcID {
+			return nil, fakeErrorf("InvalidParameterValue", "Security group %s and subnet %s belong to different networks.", id, subnetID)
+		}
+		groups = append(groups, fakeGroupIdentifier{GroupID: sg.GroupID, GroupName: sg.GroupName})
+	}
+	if len(groups) == 0 {
+		for _, sg := range f.groups {
+			if sg.VpcID == subnet.VpcID && sg.GroupName == "default" {
+				groups = append(groups, fakeGroupIdentifier{GroupID: sg.GroupID, GroupName: sg.GroupName})
+			}
+		}
+	}
+
+	// Addresses are handed out in order after the four EC2 reserves.
+	_, network, _ := net.ParseCIDR(subnet.CidrBlock)
+	ip := network.IP.To4()
+	used := 0
+	for _, eni := range f.interfaces {
+		if eni.SubnetID == subnetID {
+			used++
+		}
+	}
+	address := net.IPv4(ip[0], ip[1], ip[2], ip[3]+byte(4+used))
+
+	eni := &fakeNetworkInterface{
+		NetworkInterfaceID: f.newID("eni"),
+		SubnetID:           subnetID,
+		VpcID:              subnet.VpcID,
+		AvailabilityZone:   subnet.AvailabilityZone,
+		Description:        p.Get("Description"),
+		OwnerID:            fakeEC2OwnerID,
+		Status:             "available",
+		PrivateIPAddress:   address.String(),
+		Groups:             groups,
+	}
+	f.interfaces[eni.NetworkInterfaceID] = eni
+
+	result := *eni
+	return &struct {
+		fakeMeta
+		NetworkInterface *fakeNetworkInterface `xml:"networkInterface"`
+	}{NetworkInterface: &result}, nil
+}
+
+func (f *fakeEC2) networkInterface(id string) (*fakeNetworkInterface, error) {
+	eni, ok := f.interfaces[id]
+	if !ok {
+		return nil, fakeErrorf("InvalidNetworkInterfaceID.NotFound", "The networkInterface ID '%s' does not exist", id)
+	}
+	return eni, nil
+}
+
+func (f *fakeEC2) describeNetworkInterfaces(p url.Values) (fakeResult, error) {
+	ids := fakeList(p, "NetworkInterfaceId")
+	for _, id := range ids {
+		if _, err := f.networkInterface(id); err != nil {
+			return nil, err
+		}
+	}
+	filters := fakeFilters(p)
+	if len(ids) > 0 {
+		filters["network-interface-id"] = ids
+	}
+
+	result := &struct {
+		fakeMeta
+		NetworkInterfaces []fakeNetworkInterface `xml:"networkInterfaceSet>item"`
+	}{}
+	for _, id := range f.sortedIDs(f.interfaces) {
+		eni := f.interfaces[id]
+		var groupIDs []string
+		for _, g := range eni.Groups {
+			groupIDs = append(groupIDs, g.GroupID)
+		}
+		values := map[string]string{
+			"network-interface-id": eni.NetworkInterfaceID,
+			"subnet-id":            eni.SubnetID,
+			"vpc-id":               eni.VpcID,
+			"status":               eni.Status,
+			"group-id":             strings.Join(groupIDs, ","),
+		}
+		if !fakeMatch(filters, values, f.tags[id]) {
+			continue
+		}
+		eni.TagSet = f.tagSet(id)
+		out := *eni
+		out.Groups = append([]fakeGroupIdentifier(nil), eni.Groups...)
+		result.NetworkInterfaces = append(result.NetworkInterfaces, out)
+	}
+	return result, nil
+}
+
+func (f *fakeEC2) detachNetworkInterface(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "AttachmentId")
+	if err != nil {
+		return nil, err
+	}
+	return nil, fakeErrorf("InvalidAttachmentID.NotFound", "Interface attachment '%s' does not exist", id)
+}
+
+func (f *fakeEC2) deleteNetworkInterface(p url.Values) (fakeResult, error) {
+	id, err := fakeRequired(p, "NetworkInterfaceId")
+	if err != nil {
+		return nil, err
+	}
+	eni, err := f.networkInterface(id)
+	if err != nil {
+		return nil, err
+	}
+	if eni.RequesterManaged {
+		return nil, fakeErrorf("OperationNotPermitted", "You are not allowed to manage '%s' attachments.", id)
+	}
+	delete(f.interfaces, id)
+	delete(f.tags, id)
+	return &fakeReturn{Return: true}, nil
+}
+
+// Tags
+
+// exists returns the NotFound error for a resource ID that doesn't
+// exist, based on its prefix.
+func (f *fakeEC2) exists(id string) error {
+	var err error
+	switch {
+	case strings.HasPrefix(id, "vpc-"):
+		_, err = f.vpc(id)
+	case strings.HasPrefix(id, "subnet-"):
+		_, err = f.subnet(id)
+	case strings.HasPrefix(id, "rtb-"):
+		_, err = f.routeTable(id)
+	case strings.HasPrefix(id, "igw-"):
+		_, err = f.internetGateway(id)
+	case strings.HasPrefix(id, "sg-"):
+		_, err = f.securityGroup(id)
+	case strings.HasPrefix(id, "eni-"):
+		_, err = f.networkInterface(id)
+	case strings.HasPrefix(id, "acl-"):
+		if _, ok := f.acls[id]; !ok {
+			err = fakeErrorf("InvalidNetworkAclID.NotFound", "The network ACL '%s' does not exist", id)
+		}
+	default:
+		err = fakeErrorf("InvalidID", "The ID '%s' is not valid", id)
+	}
+	return err
+}
+
+func (f *fakeEC2) createTags(p url.Values) (fakeResult, error) {
+	ids := fakeList(p, "ResourceId")
+	if len(ids) == 0 {
+		return nil, fakeErrorf("MissingParameter", "The request must contain the parameter resourceIdSet")
+	}
+	tags := fakeMembers(p, "Tag")
+	if len(tags) == 0 {
+		return nil, fakeErrorf("MissingParameter", "The request must contain the parameter tagSet")
+	}
+	for _, id := range ids {
+		if err := f.exists(id); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range tags {
+		if strings.HasPrefix(t.Get("Key"), "aws:") {
+			return nil, fakeErrorf("InvalidParameterValue", "Tag keys starting with 'aws:' are reserved for internal use")
+		}
+	}
+
+	for _, id := range ids {
+		if f.tags[id] == nil {
+			f.tags[id] = make(map[string]string)
+		}
+		for _, t := range tags {
+			f.tags[id][t.Get("Key")] = t.Get("Value")
+		}
+	}
+	return &fakeReturn{Return: true}, nil
+}
+
+func (f *fakeEC2) deleteTags(p url.Values) (fakeResult, error) {
+	ids := fakeList(p, "ResourceId")
+	if len(ids) == 0 {
+		return nil, fakeErrorf("MissingParameter", "The request must contain the parameter resourceIdSet")
+	}
+	for _, id := range ids {
+		if err := f.exists(id); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, id := range ids {
+		for _, t := range fakeMembers(p, "Tag") {
+			v, ok := f.tags[id][t.Get("Key")]
+			if !ok {
+				continue
+			}
+			if _, hasValue := t["Value"]; hasValue && t.Get("Value") != v {
+				continue
+			}
+			delete(f.tags[id], t.Get("Key"))
+		}
+	}
+	return &fakeReturn{Return: true}, nil
+}
+
+// sortedIDs returns the keys of one of the fake's resource maps in order,
+// so describe results are stable.
+func (f *fakeEC2) sortedIDs(m interface{}) []string {
+	var ids []string
+	switch m := m.(type) {
+	case map[string]*fakeVpc:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*fakeSubnet:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*fakeRouteTable:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*fakeInternetGateway:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*fakeSecurityGroup:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*fakeNetworkACL:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*fakeNetworkInterface:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func testFakeEC2Call(t *testing.T, f *fakeEC2, params url.Values) (apiError, []byte) {
+	req, err := http.NewRequest("POST", f.URL, strings.NewReader(params.Encode()))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=fake")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resp.StatusCode >= 400 {
+		return parseAPIError(body), body
+	}
+	return apiError{}, body
+}
+
+func TestFakeEC2_vpcLifecycle(t *testing.T) {
+	f := newFakeEC2()
+	defer f.Close()
+
+	_, body := testFakeEC2Call(t, f, url.Values{"Action": {"CreateVpc"}, "CidrBlock": {"10.1.0.0/16"}})
+	var created struct {
+		VpcID string `xml:"vpc>vpcId"`
+		State string `xml:"vpc>state"`
+	}
+	if err := xml.Unmarshal(body, &created); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if created.VpcID == "" || created.State != "pending" {
+		t.Fatalf("bad: %s", body)
+	}
+
+	for _, state := range []string{"pending", "available"} {
+		_, body = testFakeEC2Call(t, f, url.Values{"Action": {"DescribeVpcs"}, "VpcId.1": {created.VpcID}})
+		if !strings.Contains(string(body), "<state>"+state+"</state>") {
+			t.Fatalf("expected %s: %s", state, body)
+		}
+	}
+
+	_, body = testFakeEC2Call(t, f, url.Values{
+		"Action":           {"DescribeRouteTables"},
+		"Filter.1.Name":    {"vpc-id"},
+		"Filter.1.Value.1": {created.VpcID},
+		"Filter.2.Name":    {"association.main"},
+		"Filter.2.Value.1": {"true"},
+	})
+	if strings.Count(string(body), "<routeTableId>") != 2 || !strings.Contains(string(body), "<gatewayId>local</gatewayId>") {
+		t.Fatalf("expected a main route table with a local route: %s", body)
+	}
+
+	_, body = testFakeEC2Call(t, f, url.Values{
+		"Action": {"CreateSubnet"}, "VpcId": {created.VpcID}, "CidrBlock": {"10.1.1.0/24"},
+	})
+	var subnet struct {
+		SubnetID string `xml:"subnet>subnetId"`
+	}
+	if err := xml.Unmarshal(body, &subnet); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		Params url.Values
+		Code   string
+	}{
+		{url.Values{"Action": {"CreateSubnet"}, "VpcId": {created.VpcID}, "CidrBlock": {"10.1.1.0/25"}}, "InvalidSubnet.Conflict"},
+		{url.Values{"Action": {"CreateSubnet"}, "VpcId": {created.VpcID}, "CidrBlock": {"10.2.0.0/24"}}, "InvalidSubnet.Range"},
+		{url.Values{"Action": {"DeleteVpc"}, "VpcId": {created.VpcID}}, "DependencyViolation"},
+		{url.Values{"Action": {"DescribeVpcs"}, "VpcId.1": {"vpc-missing"}}, "InvalidVpcID.NotFound"},
+		{url.Values{"Action": {"CreateVpc"}}, "MissingParameter"},
+		{url.Values{"Action": {"RunInstances"}}, "InvalidAction"},
+		{url.Values{"Action": {"DeleteSubnet"}, "SubnetId": {subnet.SubnetID}}, ""},
+		{url.Values{"Action": {"DeleteVpc"}, "VpcId": {created.VpcID}}, ""},
+		{url.Values{"Action": {"DeleteVpc"}, "VpcId": {created.VpcID}}, "InvalidVpcID.NotFound"},
+	}
+	for i, tc := range cases {
+		apiErr, body := testFakeEC2Call(t, f, tc.Params)
+		if apiErr.Code != tc.Code {
+			t.Fatalf("%d: expected %q, got %q: %s", i, tc.Code, apiErr.Code, body)
+		}
+	}
+
+	_, body = testFakeEC2Call(t, f, url.Values{"Action": {"DescribeSecurityGroups"}})
+	if strings.Contains(string(body), "<groupId>") {
+		t.Fatalf("expected the default security group to be deleted with the VPC: %s", body)
+	}
+}
+
+func TestFakeEC2_securityGroupRules(t *testing.T) {
+	f := newFakeEC2()
+	defer f.Close()
+
+	_, body := testFakeEC2Call(t, f, url.Values{"Action": {"CreateVpc"}, "CidrBlock": {"10.1.0.0/16"}})
+	var vpc struct {
+		VpcID string `xml:"vpc>vpcId"`
+	}
+	xml.Unmarshal(body, &vpc)
+
+	_, body = testFakeEC2Call(t, f, url.Values{
+		"Action": {"CreateSecurityGroup"}, "GroupName": {"web"}, "GroupDescription": {"web"}, "VpcId": {vpc.VpcID},
+	})
+	var sg struct {
+		GroupID string `xml:"groupId"`
+	}
+	xml.Unmarshal(body, &sg)
+
+	rule := url.Values{
+		"GroupId":                           {sg.GroupID},
+		"IpPermissions.1.IpProtocol":        {"tcp"},
+		"IpPermissions.1.FromPort":          {"80"},
+		"IpPermissions.1.ToPort":            {"80"},
+		"IpPermissions.1.IpRanges.1.CidrIp": {"10.0.0.0/8"},
+	}
+	call := func(action string) string {
+		p := url.Values{"Action": {action}}
+		for k, v := range rule {
+			p[k] = v
+		}
+		apiErr, _ := testFakeEC2Call(t, f, p)
+		return apiErr.Code
+	}
+
+	for i, tc := range []struct{ Action, Code string }{
+		{"AuthorizeSecurityGroupIngress", ""},
+		{"AuthorizeSecurityGroupIngress", "InvalidPermission.Duplicate"},
+		{"RevokeSecurityGroupIngress", ""},
+		{"RevokeSecurityGroupIngress", "InvalidPermission.NotFound"},
+	} {
+		if code := call(tc.Action); code != tc.Code {
+			t.Fatalf("%d: %s: expected %q, got %q", i, tc.Action, tc.Code, code)
+		}
+	}
+
+	apiErr, _ := testFakeEC2Call(t, f, url.Values{"Action": {"DeleteVpc"}, "VpcId": {vpc.VpcID}})
+	if apiErr.Code != "DependencyViolation" {
+		t.Fatalf("expected DependencyViolation, got %q", apiErr.Code)
+	}
+}
+
+func TestFakeEC2_dryRun(t *testing.T) {
+	f := newFakeEC2()
+	defer f.Close()
+	f.denied["CreateSubnet"] = true
+
+	apiErr, _ := testFakeEC2Call(t, f, url.Values{"Action": {"CreateVpc"}, "CidrBlock": {"10.1.0.0/16"}, "DryRun": {"true"}})
+	if apiErr.Code != "DryRunOperation" || len(f.vpcs) != 0 {
+		t.Fatalf("expected DryRunOperation and no VPC, got %q and %d VPCs", apiErr.Code, len(f.vpcs))
+	}
+
+	apiErr, _ = testFakeEC2Call(t, f, url.Values{"Action": {"CreateSubnet"}, "VpcId": {"vpc-1"}, "CidrBlock": {"10.1.1.0/24"}, "DryRun": {"true"}})
+	if apiErr.Code != "UnauthorizedOperation" {
+		t.Fatalf("expected UnauthorizedOperation, got %q", apiErr.Code)
+	}
+}
+
+func TestFakeEC2_networkAcls(t *testing.T) {
+	f := newFakeEC2()
+	defer f.Close()
+
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateVpc"}, "CidrBlock": {"10.1.0.0/16"}})
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateVpc"}, "CidrBlock": {"10.2.0.0/16"}})
+
+	_, body := testFakeEC2Call(t, f, url.Values{
+		"Action":           {"DescribeNetworkAcls"},
+		"Filter.1.Name":    {"default"},
+		"Filter.1.Value.1": {"true"},
+		"Filter.2.Name":    {"vpc-id"},
+		"Filter.2.Value.1": {"vpc-00000001"},
+	})
+	if strings.Count(string(body), "<networkAclId>") != 1 || !strings.Contains(string(body), "<vpcId>vpc-00000001</vpcId>") {
+		t.Fatalf("bad: %s", body)
+	}
+}
+
+func TestFakeEC2_vpcCidrBlockAssociations(t *testing.T) {
+	f := newFakeEC2()
+	defer f.Close()
+
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateVpc"}, "CidrBlock": {"10.1.0.0/16"}})
+	_, body := testFakeEC2Call(t, f, url.Values{"Action": {"AssociateVpcCidrBlock"}, "VpcId": {"vpc-00000001"}, "CidrBlock": {"10.2.0.0/16"}})
+	var associated struct {
+		AssociationID string `xml:"cidrBlockAssociation>associationId"`
+		State         string `xml:"cidrBlockAssociation>cidrBlockState>state"`
+	}
+	if err := xml.Unmarshal(body, &associated); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if associated.AssociationID == "" || associated.State != "associating" {
+		t.Fatalf("bad: %s", body)
+	}
+
+	describe := url.Values{
+		"Action":           {"DescribeVpcs"},
+		"Filter.1.Name":    {"cidr-block-association.association-id"},
+		"Filter.1.Value.1": {associated.AssociationID},
+	}
+	for _, state := range []string{"associating", "associated"} {
+		_, body = testFakeEC2Call(t, f, describe)
+		if !strings.Contains(string(body), "<cidrBlock>10.2.0.0/16</cidrBlock><cidrBlockState><state>"+state+"</state>") {
+			t.Fatalf("expected %s: %s", state, body)
+		}
+	}
+
+	primary := f.vpcs["vpc-00000001"].CidrBlockAssociationSet[0].AssociationID
+	cases := []struct {
+		Params url.Values
+		Code   string
+	}{
+		{url.Values{"Action": {"AssociateVpcCidrBlock"}, "VpcId": {"vpc-00000001"}, "CidrBlock": {"10.2.128.0/17"}}, "CidrConflict"},
+		{url.Values{"Action": {"AssociateVpcCidrBlock"}, "VpcId": {"vpc-00000001"}, "CidrBlock": {"10.3.0.0/8"}}, "InvalidParameterValue"},
+		{url.Values{"Action": {"CreateSubnet"}, "VpcId": {"vpc-00000001"}, "CidrBlock": {"10.2.1.0/24"}}, ""},
+		{url.Values{"Action": {"DisassociateVpcCidrBlock"}, "AssociationId": {associated.AssociationID}}, "DependencyViolation"},
+		{url.Values{"Action": {"DisassociateVpcCidrBlock"}, "AssociationId": {primary}}, "OperationNotPermitted"},
+		{url.Values{"Action": {"DisassociateVpcCidrBlock"}, "AssociationId": {"vpc-cidr-assoc-missing"}}, "InvalidVpcCidrBlockAssociationID.NotFound"},
+	}
+	for i, tc := range cases {
+		apiErr, body := testFakeEC2Call(t, f, tc.Params)
+		if apiErr.Code != tc.Code {
+			t.Fatalf("%d: expected %q, got %q: %s", i, tc.Code, apiErr.Code, body)
+		}
+	}
+
+	for id := range f.subnets {
+		testFakeEC2Call(t, f, url.Values{"Action": {"DeleteSubnet"}, "SubnetId": {id}})
+	}
+	if apiErr, body := testFakeEC2Call(t, f, url.Values{"Action": {"DisassociateVpcCidrBlock"}, "AssociationId": {associated.AssociationID}}); apiErr.Code != "" {
+		t.Fatalf("err: %s", body)
+	}
+	_, body = testFakeEC2Call(t, f, describe)
+	if !strings.Contains(string(body), "<state>disassociating</state>") {
+		t.Fatalf("expected disassociating: %s", body)
+	}
+	_, body = testFakeEC2Call(t, f, describe)
+	if strings.Contains(string(body), "<vpcId>") {
+		t.Fatalf("expected the association to be gone: %s", body)
+	}
+}
+
+func TestFakeEC2_ipv6(t *testing.T) {
+	f := newFakeEC2()
+	defer f.Close()
+
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateVpc"}, "CidrBlock": {"10.1.0.0/16"}})
+	_, body := testFakeEC2Call(t, f, url.Values{"Action": {"AssociateVpcCidrBlock"}, "VpcId": {"vpc-00000001"}, "AmazonProvidedIpv6CidrBlock": {"true"}})
+	var vpcBlock struct {
+		AssociationID string `xml:"ipv6CidrBlockAssociation>associationId"`
+		CidrBlock     string `xml:"ipv6CidrBlockAssociation>ipv6CidrBlock"`
+	}
+	if err := xml.Unmarshal(body, &vpcBlock); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, network, err := net.ParseCIDR(vpcBlock.CidrBlock)
+	if err != nil {
+		t.Fatalf("bad: %s", body)
+	}
+	if ones, _ := network.Mask.Size(); ones != 56 {
+		t.Fatalf("expected a /56: %s", body)
+	}
+	subnetCidr := (&net.IPNet{IP: network.IP, Mask: net.CIDRMask(64, 128)}).String()
+
+	_, body = testFakeEC2Call(t, f, url.Values{"Action": {"DescribeRouteTables"}})
+	if !strings.Contains(string(body), "<destinationIpv6CidrBlock>"+vpcBlock.CidrBlock+"</destinationIpv6CidrBlock><gatewayId>local</gatewayId>") {
+		t.Fatalf("expected a local IPv6 route: %s", body)
+	}
+	testFakeEC2Call(t, f, url.Values{"Action": {"DescribeVpcs"}})
+
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateSubnet"}, "VpcId": {"vpc-00000001"}, "CidrBlock": {"10.1.1.0/24"}})
+	var subnetID string
+	for id := range f.subnets {
+		subnetID = id
+	}
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateInternetGateway"}})
+	var igwID string
+	for id := range f.gateways {
+		igwID = id
+	}
+	testFakeEC2Call(t, f, url.Values{"Action": {"AttachInternetGateway"}, "InternetGatewayId": {igwID}, "VpcId": {"vpc-00000001"}})
+	var rtID string
+	for id := range f.routeTables {
+		rtID = id
+	}
+
+	cases := []struct {
+		Params url.Values
+		Code   string
+	}{
+		{url.Values{"Action": {"AssociateVpcCidrBlock"}, "VpcId": {"vpc-00000001"}, "AmazonProvidedIpv6CidrBlock": {"true"}}, "CidrLimitExceeded"},
+		{url.Values{"Action": {"AssociateSubnetCidrBlock"}, "SubnetId": {subnetID}, "Ipv6CidrBlock": {"2001:db8::/64"}}, "InvalidSubnet.Range"},
+		{url.Values{"Action": {"AssociateSubnetCidrBlock"}, "SubnetId": {subnetID}, "Ipv6CidrBlock": {vpcBlock.CidrBlock}}, "InvalidSubnet.Range"},
+		{url.Values{"Action": {"AssociateSubnetCidrBlock"}, "SubnetId": {subnetID}, "Ipv6CidrBlock": {subnetCidr}}, ""},
+		{url.Values{"Action": {"ModifySubnetAttribute"}, "SubnetId": {subnetID}, "AssignIpv6AddressOnCreation.Value": {"true"}}, ""},
+		{url.Values{"Action": {"DisassociateVpcCidrBlock"}, "AssociationId": {vpcBlock.AssociationID}}, "DependencyViolation"},
+		{url.Values{"Action": {"CreateRoute"}, "RouteTableId": {rtID}, "DestinationIpv6CidrBlock": {"::/0"}, "GatewayId": {igwID}}, ""},
+		{url.Values{"Action": {"CreateRoute"}, "RouteTableId": {rtID}, "DestinationIpv6CidrBlock": {"::/0"}, "GatewayId": {igwID}}, "RouteAlreadyExists"},
+		{url.Values{"Action": {"CreateRoute"}, "RouteTableId": {rtID}, "DestinationIpv6CidrBlock": {"0.0.0.0/0"}, "GatewayId": {igwID}}, "InvalidParameterValue"},
+		{url.Values{"Action": {"CreateRoute"}, "RouteTableId": {rtID}, "DestinationCidrBlock": {"0.0.0.0/0"}, "GatewayId": {igwID}}, ""},
+		{url.Values{"Action": {"DeleteRoute"}, "RouteTableId": {rtID}, "DestinationIpv6CidrBlock": {"::/0"}}, ""},
+		{url.Values{"Action": {"DeleteRoute"}, "RouteTableId": {rtID}, "DestinationIpv6CidrBlock": {"::/0"}}, "InvalidRoute.NotFound"},
+	}
+	for i, tc := range cases {
+		apiErr, body := testFakeEC2Call(t, f, tc.Params)
+		if apiErr.Code != tc.Code {
+			t.Fatalf("%d: expected %q, got %q: %s", i, tc.Code, apiErr.Code, body)
+		}
+	}
+
+	_, body = testFakeEC2Call(t, f, url.Values{"Action": {"DescribeSubnets"}, "SubnetId.1": {subnetID}})
+	if !strings.Contains(string(body), "<assignIpv6AddressOnCreation>true</assignIpv6AddressOnCreation>") ||
+		!strings.Contains(string(body), "<ipv6CidrBlock>"+subnetCidr+"</ipv6CidrBlock><ipv6CidrBlockState><state>associating</state>") {
+		t.Fatalf("bad: %s", body)
+	}
+	_, body = testFakeEC2Call(t, f, url.Values{"Action": {"DescribeRouteTables"}, "RouteTableId.1": {rtID}})
+	if strings.Contains(string(body), "::/0") || !strings.Contains(string(body), "<destinationCidrBlock>0.0.0.0/0</destinationCidrBlock>") {
+		t.Fatalf("bad routes: %s", body)
+	}
+}
+
+func TestFakeEC2_defaultVpc(t *testing.T) {
+	f := newFakeEC2()
+	defer f.Close()
+	vpcID := f.createDefaultVpc()
+
+	_, body := testFakeEC2Call(t, f, url.Values{"Action": {"DescribeVpcs"}, "Filter.1.Name": {"isDefault"}, "Filter.1.Value.1": {"true"}})
+	if !strings.Contains(string(body), "<vpcId>"+vpcID+"</vpcId>") || !strings.Contains(string(body), "<isDefault>true</isDefault>") {
+		t.Fatalf("bad: %s", body)
+	}
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateVpc"}, "CidrBlock": {"10.1.0.0/16"}})
+	_, body = testFakeEC2Call(t, f, url.Values{"Action": {"DescribeVpcs"}, "Filter.1.Name": {"isDefault"}, "Filter.1.Value.1": {"true"}})
+	if strings.Count(string(body), "<vpcId>") != 1 {
+		t.Fatalf("expected only the default VPC: %s", body)
+	}
+
+	_, body = testFakeEC2Call(t, f, url.Values{
+		"Action":           {"DescribeSubnets"},
+		"Filter.1.Name":    {"availability-zone"},
+		"Filter.1.Value.1": {"us-west-2b"},
+		"Filter.2.Name":    {"default-for-az"},
+		"Filter.2.Value.1": {"true"},
+	})
+	if strings.Count(string(body), "<subnetId>") != 1 ||
+		!strings.Contains(string(body), "<cidrBlock>172.31.16.0/20</cidrBlock>") ||
+		!strings.Contains(string(body), "<mapPublicIpOnLaunch>true</mapPublicIpOnLaunch>") {
+		t.Fatalf("bad: %s", body)
+	}
+}
+
+func TestFakeEC2_networkInterfaces(t *testing.T) {
+	f := newFakeEC2()
+	defer f.Close()
+
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateVpc"}, "CidrBlock": {"10.1.0.0/16"}})
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateSubnet"}, "VpcId": {"vpc-00000001"}, "CidrBlock": {"10.1.1.0/24"}})
+	testFakeEC2Call(t, f, url.Values{"Action": {"CreateSecurityGroup"}, "GroupName": {"web"}, "GroupDescription": {"web"}, "VpcId": {"vpc-00000001"}})
+	var subnetID, sgID string
+	for id := range f.subnets {
+		subnetID = id
+	}
+	for id, sg := range f.groups {
+		if sg.GroupName == "web" {
+			sgID = id
+		}
+	}
+
+	_, body := testFakeEC2Call(t, f, url.Values{"Action": {"CreateNetworkInterface"}, "SubnetId": {subnetID}, "SecurityGroupId.1": {sgID}})
+	var created struct {
+		NetworkInterfaceID string `xml:"networkInterface>networkInterfaceId"`
+		PrivateIPAddress   string `xml:"networkInterface>privateIpAddress"`
+	}
+	if err := xml.Unmarshal(body, &created); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if created.PrivateIPAddress != "10.1.1.4" {
+		t.Fatalf("bad: %s", body)
+	}
+
+	_, body = testFakeEC2Call(t, f, url.Values{"Action": {"DescribeNetworkInterfaces"}, "Filter.1.Name": {"vpc-id"}, "Filter.1.Value.1": {"vpc-00000001"}})
+	if !strings.Contains(string(body), "<networkInterfaceId>"+created.NetworkInterfaceID+"</networkInterfaceId>") ||
+		!strings.Contains(string(body), "<groupId>"+sgID+"</groupId>") {
+		t.Fatalf("bad: %s", body)
+	}
+
+	cases := []struct {
+		Params url.Values
+		Code   string
+	}{
+		{url.Values{"Action": {"DeleteSubnet"}, "SubnetId": {subnetID}}, "DependencyViolation"},
+		{url.Values{"Action": {"DeleteSecurityGroup"}, "GroupId": {sgID}}, "DependencyViolation"},
+		{url.Values{"Action": {"DetachNetworkInterface"}, "AttachmentId": {"eni-attach-1"}}, "InvalidAttachmentID.NotFound"},
+		{url.Values{"Action": {"DeleteNetworkInterface"}, "NetworkInterfaceId": {created.NetworkInterfaceID}}, ""},
+		{url.Values{"Action": {"DeleteNetworkInterface"}, "NetworkInterfaceId": {created.NetworkInterfaceID}}, "InvalidNetworkInterfaceID.NotFound"},
+		{url.Values{"Action": {"DeleteSecurityGroup"}, "GroupId": {sgID}}, ""},
+		{url.Values{"Action": {"DeleteSubnet"}, "SubnetId": {subnetID}}, ""},
+	}
+	for i, tc := range cases {
+		apiErr, body := testFakeEC2Call(t, f, tc.Params)
+		if apiErr.Code != tc.Code {
+			t.Fatalf("%d: expected %q, got %q: %s", i, tc.Code, apiErr.Code, body)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package raws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	fixtureModeRecord = "record"
+	fixtureModeReplay = "replay"
+
+	// fixtureAccountID replaces the real account ID in recorded traffic.
+	fixtureAccountID = "123456789012"
+)
+
+// fixtureIgnoredParams are request parameters that change on every
+// request or carry credentials. They are neither recorded nor matched on.
+var fixtureIgnoredParams = map[string]bool{
+	"Action":           true,
+	"Version":          true,
+	"AWSAccessKeyId":   true,
+	"Signature":        true,
+	"SignatureMethod":  true,
+	"SignatureVersion": true,
+	"SecurityToken":    true,
+	"Timestamp":        true,
+	"Expires":          true,
+	"ClientToken":      true,
+}
+
+var fixtureAccountPattern = regexp.MustCompile(`<(ownerId|userId)>(\d{12})</`)
+
+// fixtureInteraction is one recorded request and its response.
+type fixtureInteraction struct {
+	Action string     `json:"action"`
+	Params url.Values `json:"params"`
+	Status int        `json:"status"`
+	Body   string     `json:"body"`
+}
+
+// fixture is the recorded API traffic of one acceptance test. In replay
+// mode a request is answered with the next recorded response for the same
+// action and parameters; once those run out the last one is repeated, so
+// an extra poll of a waiter still gets an answer.
+type fixture struct {
+	Path string `json:"-"`
+	Mode string `json:"-"`
+
+	mu           sync.Mutex
+	Interactions []*fixtureInteraction `json:"interactions"`
+	used         map[string]int
+	accounts     map[string]bool
+}
+
+// loadFixture opens the fixture at path. In record mode it starts empty.
+func loadFixture(path, mode string) (*fixture, error) {
+	f := &fixture{
+		Path:     path,
+		Mode:     mode,
+		used:     make(map[string]int),
+		accounts: make(map[string]bool),
+	}
+	if mode == fixtureModeRecord {
+		return f, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("Error decoding fixture %s: %s", path, err)
+	}
+	return f, nil
+}
+
+func (f *fixture) save() error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(f.Path, append(data, '\n'), 0644)
+}
+
+// fixtureParams returns the parameters of a request that identify it.
+func fixtureParams(req *http.Request, body []byte) url.Values {
+	params := make(url.Values)
+	all := req.URL.Query()
+	if form, err := url.ParseQuery(string(body)); err == nil {
+		for k, v := range form {
+			all[k] = v
+		}
+	}
+	for k, v := range all {
+		if fixtureIgnoredParams[k] || strings.HasPrefix(k, "X-Amz-") {
+			continue
+		}
+		params[k] = v
+	}
+	return params
+}
+
+// fixtureKey is what replay matches requests on: the action and its
+// parameters in a canonical order.
+func fixtureKey(action string, params url.Values) string {
+	return action + "?" + params.Encode()
+}
+
+// scrub removes secrets from recorded text and replaces every account ID
+// seen so far with fixtureAccountID.
+func (f *fixture) scrub(s string) string {
+	for _, m := range fixtureAccountPattern.FindAllStringSubmatch(s, -1) {
+		f.accounts[m[2]] = true
+	}
+	s = secretElementPattern.ReplaceAllString(s, "<$1>"+redacted+"</")
+	for account := range f.accounts {
+		s = strings.Replace(s, account, fixtureAccountID, -1)
+	}
+	return s
+}
+
+func (f *fixture) record(action string, params url.Values, resp *http.Response) error {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := &fixtureInteraction{
+		Action: action,
+		Status: resp.StatusCode,
+		Body:   f.scrub(string(body)),
+		Params: make(url.Values),
+	}
+	for k, v := range params {
+		for _, s := range v {
+			i.Params.Add(k, f.scrub(s))
+		}
+	}
+	f.Interactions = append(f.Interactions, i)
+	return f.save()
+}
+
+func (f *fixture) replay(req *http.Request, action string, params url.Values) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := fixtureKey(action, params)
+	var matches []*fixtureInteraction
+	for _, i := range f.Interactions {
+		if fixtureKey(i.Action, i.Params) == key {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("No recorded response for %s in %s", key, f.Path)
+	}
+
+	n := f.used[key]
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+	f.used[key]++
+	i := matches[n]
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/xml;charset=UTF-8"}},
+		Body:          ioutil.NopCloser(strings.NewReader(i.Body)),
+		ContentLength: int64(len(i.Body)),
+		Request:       req,
+	}, nil
+}
+
+// fixtureTransport records the traffic of a connection to a fixture, or
+// answers it from one without touching the network.
+type fixtureTransport struct {
+	Fixture *fixture
+	Base    http.RoundTripper
+}
+
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	action := requestAction(req, body)
+	params := fixtureParams(req, body)
+
+	if t.Fixture.Mode == fixtureModeReplay {
+		return t.Fixture.replay(req, action, params)
+	}
+
+	r := cloneRequest(req)
+	if body != nil {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	resp, err := t.Base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Fixture.record(action, params, resp); err != nil {
+		return nil, fmt.Errorf("Error recording %s to %s: %s", action, t.Fixture.Path, err)
+	}
+	return resp, nil
+}
+
+func TestFixtureTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raws-fixtures")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "TestAccExample.json")
+
+	states := []string{"pending", "available"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := states[0]
+		if len(states) > 1 {
+			states = states[1:]
+		}
+		fmt.Fprintf(w, `<DescribeVpcsResponse><vpcSet><item><vpcId>vpc-1</vpcId><state>%s</state>`+
+			`<ownerId>999988887777</ownerId></item></vpcSet></DescribeVpcsResponse>`, state)
+	}))
+	defer ts.Close()
+
+	send := func(f *fixture, base http.RoundTripper, form string) string {
+		req, _ := http.NewRequest("POST", ts.URL, strings.NewReader(form))
+		req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150101")
+		resp, err := (&fixtureTransport{Fixture: f, Base: base}).RoundTrip(req)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	f, err := loadFixture(path, fixtureModeRecord)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	first := send(f, http.DefaultTransport,
+		"Action=DescribeVpcs&VpcId.1=vpc-1&Version=2015-04-15&X-Amz-Signature=abcdef&Timestamp=2015-01-01T00%3A00%3A00Z")
+	send(f, http.DefaultTransport, "Action=DescribeVpcs&VpcId.1=vpc-1&Timestamp=2015-01-01T00%3A00%3A01Z")
+	if !strings.Contains(first, "999988887777") {
+		t.Fatalf("the caller should get the unscrubbed response: %s", first)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, secret := range []string{"AKIDEXAMPLE", "abcdef", "Signature", "Timestamp", "999988887777"} {
+		if strings.Contains(string(data), secret) {
+			t.Fatalf("fixture contains %q:\n%s", secret, data)
+		}
+	}
+
+	// Replay never touches the network and matches on action and
+	// parameters, not the order they were sent in.
+	f, err = loadFixture(path, fixtureModeReplay)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ts.Close()
+	for _, state := range []string{"pending", "available", "available"} {
+		body := send(f, nil, "VpcId.1=vpc-1&Version=2015-04-16&Action=DescribeVpcs")
+		if !strings.Contains(body, "<state>"+state+"</state>") {
+			t.Fatalf("expected %s: %s", state, body)
+		}
+		if !strings.Contains(body, fixtureAccountID) {
+			t.Fatalf("expected the account ID to be scrubbed: %s", body)
+		}
+	}
+
+	req, _ := http.NewRequest("POST", ts.URL, strings.NewReader("Action=DescribeVpcs&VpcId.1=vpc-2"))
+	if _, err := (&fixtureTransport{Fixture: f}).RoundTrip(req); err == nil {
+		t.Fatal("expected error for a request that was not recorded")
+	}
+}
@@ -0,0 +1,169 @@
+package raws
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMetadataEndpoint = "http://169.254.169.254/"
+	defaultMetadataTimeout  = 2 * time.Second
+
+	// metadataTokenTTL is how long an IMDSv2 session token is requested
+	// for. Tokens are renewed a minute before they expire.
+	metadataTokenTTL = 6 * time.Hour
+)
+
+// metadataClient is a small client for the EC2 instance metadata service.
+// It uses IMDSv2 session tokens and falls back to IMDSv1 only when the
+// service doesn't hand out tokens. The endpoint is configurable so that it
+// can be pointed at a local stub.
+type metadataClient struct {
+	// Endpoint is the root of the metadata service, e.g.
+	// http://169.254.169.254/.
+	Endpoint string
+	Client   *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	v1          bool
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// newMetadataClient returns a client for the metadata service at endpoint,
+// or the default link-local address if it is empty. For backwards
+// compatibility the endpoint may include the /latest/meta-data/ path.
+func newMetadataClient(endpoint string, timeout time.Duration) *metadataClient {
+	if endpoint == "" {
+		endpoint = defaultMetadataEndpoint
+	}
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint += "/"
+	}
+	endpoint = strings.TrimSuffix(endpoint, "latest/meta-data/")
+	if timeout <= 0 {
+		timeout = defaultMetadataTimeout
+	}
+
+	return &metadataClient{
+		Endpoint: endpoint,
+		Client: &http.Client{
+			// The metadata service is link-local; never send it
+			// through a proxy from the environment.
+			Transport: &http.Transport{Proxy: nil},
+			Timeout:   timeout,
+		},
+	}
+}
+
+// get returns the body of the metadata document at the given path,
+// e.g. "placement/availability-zone".
+func (m *metadataClient) get(path string) (string, error) {
+	token, err := m.sessionToken(false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := m.getWithToken(path, token)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && token != "" {
+		// The token expired or was revoked; get a new one once.
+		resp.Body.Close()
+		if token, err = m.sessionToken(true); err != nil {
+			return "", err
+		}
+		resp, err = m.getWithToken(path, token)
+	}
+	if err != nil {
+		return "", m.unreachable(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Error reading metadata %q: %s", path, resp.Status)
+	}
+
+	return string(body), nil
+}
+
+func (m *metadataClient) getWithToken(path, token string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", m.Endpoint+"latest/meta-data/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-aws-ec2-metadata-token", token)
+	}
+	return m.Client.Do(req)
+}
+
+// sessionToken returns a cached IMDSv2 session token, requesting a new one
+// if it is about to expire or refresh is set. It returns an empty token if
+// the service only supports IMDSv1.
+func (m *metadataClient) sessionToken(refresh bool) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.v1 {
+		return "", nil
+	}
+	if !refresh && m.token != "" && m.clock().Before(m.tokenExpiry.Add(-time.Minute)) {
+		return m.token, nil
+	}
+
+	req, err := http.NewRequest("PUT", m.Endpoint+"latest/api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", strconv.Itoa(int(metadataTokenTTL/time.Second)))
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return "", m.unreachable(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", m.unreachable(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		log.Printf("[WARN] Instance metadata service at %s doesn't support IMDSv2 (%s), using IMDSv1",
+			m.Endpoint, resp.Status)
+		m.v1 = true
+		return "", nil
+	default:
+		return "", fmt.Errorf("Error getting an instance metadata session token from %s: %s", m.Endpoint, resp.Status)
+	}
+
+	m.token = strings.TrimSpace(string(body))
+	m.tokenExpiry = m.clock().Add(metadataTokenTTL)
+	return m.token, nil
+}
+
+// unreachable explains a failure to talk to the metadata service.
+func (m *metadataClient) unreachable(err error) error {
+	return fmt.Errorf("Instance metadata service at %s is not reachable: %s. "+
+		"If not running on EC2, set the region and credentials in the provider; "+
+		"in a container, the instance may need a metadata hop limit of 2", m.Endpoint, err)
+}
+
+func (m *metadataClient) clock() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,177 @@
+package raws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testIMDSv2Server is a metadata service that only answers requests with a
+// session token, like an instance with IMDSv1 disabled.
+type testIMDSv2Server struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	tokens int
+	valid  string
+}
+
+func newTestIMDSv2Server() *testIMDSv2Server {
+	s := &testIMDSv2Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.URL.Path == "/latest/api/token" {
+			if r.Method != "PUT" || r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+				http.Error(w, "bad token request", http.StatusBadRequest)
+				return
+			}
+			s.tokens++
+			s.valid = fmt.Sprintf("token-%d", s.tokens)
+			fmt.Fprint(w, s.valid)
+			return
+		}
+
+		if r.Header.Get("X-aws-ec2-metadata-token") != s.valid {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/latest/meta-data/placement/availability-zone":
+			fmt.Fprint(w, "eu-central-1a")
+		case "/latest/meta-data/iam/security-credentials/":
+			fmt.Fprintln(w, "test-role")
+		case "/latest/meta-data/iam/security-credentials/test-role":
+			fmt.Fprintf(w, `{"Code": "Success", "AccessKeyId": "v2_key", "SecretAccessKey": "v2_secret", "Token": "v2_token", "Expiration": %q}`,
+				time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return s
+}
+
+func TestMetadataClient_v2(t *testing.T) {
+	ts := newTestIMDSv2Server()
+	defer ts.Close()
+
+	clock := time.Now()
+	m := newMetadataClient(ts.URL, 0)
+	m.now = func() time.Time { return clock }
+
+	for i := 0; i < 2; i++ {
+		az, err := m.get("placement/availability-zone")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if az != "eu-central-1a" {
+			t.Fatalf("bad: %s", az)
+		}
+	}
+	if ts.tokens != 1 {
+		t.Fatalf("expected the session token to be reused, got %d tokens", ts.tokens)
+	}
+
+	// Tokens are renewed before they expire...
+	clock = clock.Add(metadataTokenTTL)
+	if _, err := m.get("placement/availability-zone"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ts.tokens != 2 {
+		t.Fatalf("expected a new token, got %d tokens", ts.tokens)
+	}
+
+	// ...and when the service no longer accepts them.
+	ts.mu.Lock()
+	ts.valid = "revoked"
+	ts.mu.Unlock()
+	if _, err := m.get("placement/availability-zone"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ts.tokens != 3 {
+		t.Fatalf("expected a new token, got %d tokens", ts.tokens)
+	}
+
+	if _, err := m.get("no/such/path"); err == nil {
+		t.Fatal("expected error for a missing document")
+	}
+}
+
+func TestMetadataClient_v1(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/latest/meta-data/placement/availability-zone" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "us-west-2b")
+	}))
+	defer ts.Close()
+
+	m := newMetadataClient(ts.URL+"/latest/meta-data/", 0)
+	az, err := m.get("placement/availability-zone")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if az != "us-west-2b" {
+		t.Fatalf("bad: %s", az)
+	}
+}
+
+func TestMetadataClient_unreachable(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	for _, endpoint := range []string{slow.URL, closed.URL} {
+		m := newMetadataClient(endpoint, 50*time.Millisecond)
+		_, err := m.get("placement/availability-zone")
+		if err == nil || !strings.Contains(err.Error(), "is not reachable") {
+			t.Fatalf("%s: expected an unreachable error, got: %v", endpoint, err)
+		}
+	}
+}
+
+func TestConfigAWSRegion_IMDSv2(t *testing.T) {
+	ts := newTestIMDSv2Server()
+	defer ts.Close()
+
+	c := Config{MetadataEndpoint: ts.URL}
+	region, err := c.AWSRegion()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if region != "eu-central-1" {
+		t.Fatalf("bad region: %s", region)
+	}
+}
+
+func TestMetadataCredentials_IMDSv2(t *testing.T) {
+	ts := newTestIMDSv2Server()
+	defer ts.Close()
+
+	c := Config{MetadataEndpoint: ts.URL}
+	creds, err := (&metadataCredentials{Client: c.metadata()}).Credentials()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if creds.AccessKeyID != "v2_key" || creds.SecurityToken != "v2_token" {
+		t.Fatalf("bad: %#v", creds)
+	}
+
+	// Region discovery shares the session token.
+	if _, err := c.AWSRegion(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ts.tokens != 1 {
+		t.Fatalf("expected one session token, got %d", ts.tokens)
+	}
+}
@@ -0,0 +1,296 @@
+package raws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// metricsPathEnv names the environment variable holding the path the API
+// call metrics are written to as JSON.
+const metricsPathEnv = "RAWS_METRICS_PATH"
+
+// metricsWriteInterval is how often, at most, the metrics file is
+// rewritten while API calls are being made.
+const metricsWriteInterval = 5 * time.Second
+
+// providerMetrics counts the API calls of every provider instance in the
+// plugin process.
+var providerMetrics = newAPIMetrics(os.Getenv(metricsPathEnv))
+
+// actionMetrics are the counters of one API action. A call retried by
+// retryTransport counts once, with its latency including the retries.
+type actionMetrics struct {
+	Service   string         `json:"service"`
+	Action    string         `json:"action"`
+	Calls     int            `json:"calls"`
+	Retries   int            `json:"retries"`
+	Errors    map[string]int `json:"errors,omitempty"`
+	LatencyMs int64          `json:"latency_ms"`
+
+	latency time.Duration
+}
+
+// apiMetrics counts API calls by action, error code, retries and total
+// latency. If path is set they are written there as JSON at most every
+// metricsWriteInterval, so little is lost if Terraform kills the plugin,
+// and once more with the summary log when the plugin shuts down.
+type apiMetrics struct {
+	path string
+
+	mu      sync.Mutex
+	actions map[string]*actionMetrics
+
+	// writeScheduled is set while a write of the file is waiting for
+	// its timer.
+	writeScheduled bool
+
+	// writeMu keeps concurrent writes of the file in order.
+	writeMu sync.Mutex
+
+	shutdownOnce sync.Once
+	signalOnce   sync.Once
+
+	// afterFunc is replaced in tests.
+	afterFunc func(time.Duration, func())
+}
+
+func newAPIMetrics(path string) *apiMetrics {
+	return &apiMetrics{
+		path:    path,
+		actions: make(map[string]*actionMetrics),
+	}
+}
+
+// action returns the counters of an action. m.mu must be held.
+func (m *apiMetrics) action(service, action string) *actionMetrics {
+	key := service + " " + action
+	a, ok := m.actions[key]
+	if !ok {
+		a = &actionMetrics{Service: service, Action: action}
+		m.actions[key] = a
+	}
+	return a
+}
+
+// record counts a finished call. code is the API error code it failed
+// with, or empty if it succeeded.
+func (m *apiMetrics) record(service, action, code string, latency time.Duration) {
+	m.mu.Lock()
+	a := m.action(service, action)
+	a.Calls++
+	a.latency += latency
+	if code != "" {
+		if a.Errors == nil {
+			a.Errors = make(map[string]int)
+		}
+		a.Errors[code]++
+	}
+	if m.path != "" && !m.writeScheduled {
+		m.writeScheduled = true
+		m.after(metricsWriteInterval, m.scheduledWrite)
+	}
+	m.mu.Unlock()
+}
+
+func (m *apiMetrics) retried(service, action string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.action(service, action).Retries++
+}
+
+// snapshot returns a copy of the counters, slowest action first.
+func (m *apiMetrics) snapshot() []actionMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]actionMetrics, 0, len(m.actions))
+	for _, a := range m.actions {
+		c := *a
+		c.LatencyMs = int64(a.latency / time.Millisecond)
+		c.Errors = make(map[string]int, len(a.Errors))
+		for code, n := range a.Errors {
+			c.Errors[code] = n
+		}
+		result = append(result, c)
+	}
+	sort.Sort(byLatency(result))
+	return result
+}
+
+type byLatency []actionMetrics
+
+func (s byLatency) Len() int      { return len(s) }
+func (s byLatency) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byLatency) Less(i, j int) bool {
+	if s[i].latency != s[j].latency {
+		return s[i].latency > s[j].latency
+	}
+	return s[i].Service+s[i].Action < s[j].Service+s[j].Action
+}
+
+// summary formats the counters for the log.
+func (m *apiMetrics) summary() string {
+	actions := m.snapshot()
+
+	var calls, retries int
+	var latency time.Duration
+	var buf bytes.Buffer
+	for _, a := range actions {
+		calls += a.Calls
+		retries += a.Retries
+		latency += a.latency
+		fmt.Fprintf(&buf, "\n  %s %s: %d calls, %d retries, %s", a.Service, a.Action, a.Calls, a.Retries, a.latency)
+		if len(a.Errors) > 0 {
+			codes := make([]string, 0, len(a.Errors))
+			for code := range a.Errors {
+				codes = append(codes, code)
+			}
+			sort.Strings(codes)
+			buf.WriteString(", errors:")
+			for _, code := range codes {
+				fmt.Fprintf(&buf, " %s=%d", code, a.Errors[code])
+			}
+		}
+	}
+	return fmt.Sprintf("%d API calls, %d retries, %s%s", calls, retries, latency, buf.String())
+}
+
+func (m *apiMetrics) write() error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	data, err := json.MarshalIndent(map[string]interface{}{"actions": m.snapshot()}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see half of it.
+	tmp := m.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+func (m *apiMetrics) scheduledWrite() {
+	m.mu.Lock()
+	m.writeScheduled = false
+	m.mu.Unlock()
+
+	m.writeFile()
+}
+
+func (m *apiMetrics) writeFile() {
+	if err := m.write(); err != nil {
+		log.Printf("[WARN] Error writing API metrics to %s: %s", m.path, err)
+	}
+}
+
+func (m *apiMetrics) after(d time.Duration, f func()) {
+	if m.afterFunc != nil {
+		m.afterFunc(d, f)
+		return
+	}
+	time.AfterFunc(d, f)
+}
+
+// shutdown logs a summary of the API calls and, if RAWS_METRICS_PATH is
+// set, writes them there a last time. Only the first call does anything.
+func (m *apiMetrics) shutdown() {
+	m.shutdownOnce.Do(func() {
+		log.Printf("[TRACE] API call summary: %s", m.summary())
+		if m.path != "" {
+			m.writeFile()
+		}
+	})
+}
+
+// watchSignals shuts the metrics down before the plugin is terminated
+// with SIGTERM. Interrupts are left alone: the plugin keeps running after
+// one while Terraform stops the operation.
+func (m *apiMetrics) watchSignals() {
+	m.signalOnce.Do(func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGTERM)
+		go func() {
+			<-c
+			m.shutdown()
+			os.Exit(1)
+		}()
+	})
+}
+
+// ShutdownMetrics logs the summary of the API calls made by the plugin
+// and writes the metrics file a last time. main calls it once Terraform
+// has told the plugin to quit.
+func ShutdownMetrics() {
+	providerMetrics.shutdown()
+}
+
+// metricsTransport counts every API call sent through it.
+type metricsTransport struct {
+	Service string
+	Metrics *apiMetrics
+	Base    http.RoundTripper
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	action := requestAction(req, body)
+
+	r := cloneRequest(req)
+	if body != nil {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	start := t.clock()
+	resp, err := t.base().RoundTrip(r)
+	latency := t.clock().Sub(start)
+
+	code := ""
+	switch {
+	case err != nil:
+		code = "RequestError"
+	case resp.StatusCode >= 400:
+		if code = readAPIError(resp).Code; code == "" {
+			code = resp.Status
+		}
+	}
+	t.Metrics.record(t.Service, action, code, latency)
+
+	return resp, err
+}
+
+func (t *metricsTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *metricsTransport) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
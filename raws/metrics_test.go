@@ -0,0 +1,135 @@
+package raws
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raws-metrics")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.json")
+
+	metrics := newAPIMetrics(path)
+	var scheduled []func()
+	metrics.afterFunc = func(d time.Duration, f func()) {
+		if d != metricsWriteInterval {
+			t.Fatalf("bad write interval: %s", d)
+		}
+		scheduled = append(scheduled, f)
+	}
+	clock := time.Unix(0, 0)
+	send := func(action string, responses ...fakeResponse) {
+		rt := &metricsTransport{
+			Service: "ec2",
+			Metrics: metrics,
+			Base: &retryTransport{
+				MaxRetries: 3,
+				Base:       &fakeTransport{Responses: responses},
+				OnRetry: func(action string) {
+					metrics.retried("ec2", action)
+				},
+				sleep: func(time.Duration) {},
+			},
+			now: func() time.Time {
+				clock = clock.Add(100 * time.Millisecond)
+				return clock
+			},
+		}
+		if _, err := rt.RoundTrip(testRetryRequest(action)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	send("DescribeInternetGateways", fakeResponse{503, "RequestLimitExceeded", nil}, fakeResponse{200, "", nil})
+	send("DescribeInternetGateways", fakeResponse{200, "", nil})
+	send("DeleteVpc", fakeResponse{400, "DependencyViolation", nil})
+
+	expected := []actionMetrics{
+		{Service: "ec2", Action: "DescribeInternetGateways", Calls: 2, Retries: 1, Errors: map[string]int{}, LatencyMs: 200},
+		{Service: "ec2", Action: "DeleteVpc", Calls: 1, Errors: map[string]int{"DependencyViolation": 1}, LatencyMs: 100},
+	}
+	actual := metrics.snapshot()
+	for i := range actual {
+		actual[i].latency = 0
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad metrics:\n%#v\nexpected:\n%#v", actual, expected)
+	}
+
+	summary := metrics.summary()
+	for _, s := range []string{
+		"3 API calls, 1 retries, 300ms",
+		"ec2 DescribeInternetGateways: 2 calls, 1 retries, 200ms",
+		"ec2 DeleteVpc: 1 calls, 0 retries, 100ms, errors: DependencyViolation=1",
+	} {
+		if !strings.Contains(summary, s) {
+			t.Fatalf("summary is missing %q:\n%s", s, summary)
+		}
+	}
+
+	// One write is scheduled for all the calls, and nothing is written
+	// before it runs
+	if len(scheduled) != 1 {
+		t.Fatalf("expected 1 scheduled write, got %d", len(scheduled))
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("metrics file written before its timer: %v", err)
+	}
+	scheduled[0]()
+	written := readMetricsFile(t, path)
+	if len(written) != 2 || written[0].Action != "DescribeInternetGateways" || written[0].LatencyMs != 200 {
+		t.Fatalf("bad metrics file: %#v", written)
+	}
+
+	// The next call schedules the next write, and shutdown writes the
+	// file without waiting for it
+	send("DeleteVpc", fakeResponse{200, "", nil})
+	if len(scheduled) != 2 {
+		t.Fatalf("expected 2 scheduled writes, got %d", len(scheduled))
+	}
+	metrics.shutdown()
+	written = readMetricsFile(t, path)
+	if len(written) != 2 || written[0].Action != "DeleteVpc" || written[0].Calls != 2 {
+		t.Fatalf("bad metrics file: %#v", written)
+	}
+}
+
+func TestMetricsTransport_noPath(t *testing.T) {
+	metrics := newAPIMetrics("")
+	metrics.afterFunc = func(time.Duration, func()) {
+		t.Fatal("write scheduled without RAWS_METRICS_PATH")
+	}
+	rt := &metricsTransport{
+		Service: "ec2",
+		Metrics: metrics,
+		Base:    &fakeTransport{Responses: []fakeResponse{{200, "", nil}}},
+	}
+	if _, err := rt.RoundTrip(testRetryRequest("DescribeVpcs")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	metrics.shutdown()
+}
+
+func readMetricsFile(t *testing.T, path string) []actionMetrics {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var written struct {
+		Actions []actionMetrics `json:"actions"`
+	}
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return written.Actions
+}
@@ -1,9 +1,13 @@
 package raws
 
 import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/hashcode"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
-	"os"
 )
 
 func Provider() terraform.ResourceProvider {
@@ -11,34 +15,240 @@ func Provider() terraform.ResourceProvider {
 		Schema: map[string]*schema.Schema{
 			"access_key": &schema.Schema{
 				Type:        schema.TypeString,
-				Required:    true,
-				DefaultFunc: envDefaultFunc("AWS_ACCESS_KEY"),
+				Optional:    true,
+				Default:     "",
 				Description: descriptions["access_key"],
 			},
 
 			"secret_key": &schema.Schema{
 				Type:        schema.TypeString,
-				Required:    true,
-				DefaultFunc: envDefaultFunc("AWS_SECRET_KEY"),
+				Optional:    true,
+				Default:     "",
 				Description: descriptions["secret_key"],
 			},
 
+			"token": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: envDefaultFunc("AWS_SESSION_TOKEN"),
+				Description: descriptions["token"],
+			},
+
+			"profile": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: envDefaultFunc("AWS_PROFILE"),
+				Description: descriptions["profile"],
+			},
+
+			"shared_credentials_file": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: envDefaultFunc("AWS_SHARED_CREDENTIALS_FILE"),
+				Description: descriptions["shared_credentials_file"],
+			},
+
+			"shared_config_file": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: envDefaultFunc("AWS_CONFIG_FILE"),
+				Description: descriptions["shared_config_file"],
+			},
+
 			"region": &schema.Schema{
-				Type:         schema.TypeString,
-				Required:     true,
-				DefaultFunc:  envDefaultFunc("AWS_REGION"),
-				Description:  descriptions["region"],
-				InputDefault: "us-east-1",
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: envDefaultFunc("AWS_REGION"),
+				Description: descriptions["region"],
+			},
+
+			"metadata_endpoint": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: envDefaultFunc("AWS_EC2_METADATA_SERVICE_ENDPOINT"),
+				Description: descriptions["metadata_endpoint"],
+			},
+
+			"metadata_timeout": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: descriptions["metadata_timeout"],
+			},
+
+			"assume_role": &schema.Schema{
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: descriptions["assume_role"],
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"role_arn": &schema.Schema{
+							Type:     schema.TypeString,
+							Required: true,
+						},
+
+						"session_name": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+
+						"external_id": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+
+						"duration": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+
+						"policy": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+
+			"endpoints": endpointsSchema(),
+
+			"insecure": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: descriptions["insecure"],
+			},
+
+			"http_proxy": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: descriptions["http_proxy"],
+			},
+
+			"ca_bundle": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: envDefaultFunc("AWS_CA_BUNDLE"),
+				Description: descriptions["ca_bundle"],
+			},
+
+			"request_timeout": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: descriptions["request_timeout"],
+			},
+
+			"skip_region_validation": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: descriptions["skip_region_validation"],
+			},
+
+			"max_retries": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     defaultMaxRetries,
+				Description: descriptions["max_retries"],
+			},
+
+			"validate_permissions": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: descriptions["validate_permissions"],
+			},
+
+			"max_requests_per_second": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     0,
+				Description: descriptions["max_requests_per_second"],
+			},
+
+			"max_requests_burst": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     0,
+				Description: descriptions["max_requests_burst"],
+			},
+
+			"allowed_account_ids": &schema.Schema{
+				Type:          schema.TypeSet,
+				Optional:      true,
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				ConflictsWith: []string{"forbidden_account_ids"},
+				Description:   descriptions["allowed_account_ids"],
+				Set: func(v interface{}) int {
+					return hashcode.String(v.(string))
+				},
+			},
+
+			"forbidden_account_ids": &schema.Schema{
+				Type:          schema.TypeSet,
+				Optional:      true,
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				ConflictsWith: []string{"allowed_account_ids"},
+				Description:   descriptions["forbidden_account_ids"],
+				Set: func(v interface{}) int {
+					return hashcode.String(v.(string))
+				},
+			},
+
+			"default_tags": &schema.Schema{
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: descriptions["default_tags"],
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"tags": &schema.Schema{
+							Type:     schema.TypeMap,
+							Optional: true,
+						},
+					},
+				},
+			},
+
+			"ignore_tags": &schema.Schema{
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: descriptions["ignore_tags"],
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"keys": &schema.Schema{
+							Type:     schema.TypeSet,
+							Optional: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+							Set: func(v interface{}) int {
+								return hashcode.String(v.(string))
+							},
+						},
+
+						"key_prefixes": &schema.Schema{
+							Type:     schema.TypeSet,
+							Optional: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+							Set: func(v interface{}) int {
+								return hashcode.String(v.(string))
+							},
+						},
+					},
+				},
 			},
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
-			"raws_vpc":                     resourceRawsVpc(),
-			"raws_subnet":                  resourceRawsSubnet(),
-			"raws_security_group":          resourceRawsSecurityGroup(),
-			"raws_route_table":             resourceRawsRouteTable(),
-			"raws_route_table_association": resourceRawsRouteTableAssociation(),
-			"raws_internet_gateway":        resourceRawsInternetGateway(),
+			"raws_vpc":                             resourceRawsVpc(),
+			"raws_subnet":                          resourceRawsSubnet(),
+			"raws_security_group":                  resourceRawsSecurityGroup(),
+			"raws_route_table":                     resourceRawsRouteTable(),
+			"raws_route_table_association":         resourceRawsRouteTableAssociation(),
+			"raws_internet_gateway":                resourceRawsInternetGateway(),
+			"raws_vpc_ipv4_cidr_block_association": resourceRawsVpcIpv4CidrBlockAssociation(),
+			"raws_default_vpc":                     resourceRawsDefaultVpc(),
+			"raws_default_subnet":                  resourceRawsDefaultSubnet(),
 		},
 		ConfigureFunc: providerConfigure,
 	}
@@ -49,13 +259,78 @@ var descriptions map[string]string
 func init() {
 	descriptions = map[string]string{
 		"region": "The region where AWS operations will take place. Examples\n" +
-			"are us-east-1, us-west-2, etc.",
+			"are us-east-1, us-west-2, etc. Defaults to the region of the\n" +
+			"EC2 instance Terraform runs on.",
 
 		"access_key": "The access key for API operations. You can retrieve this\n" +
 			"from the 'Security & Credentials' section of the AWS console.",
 
 		"secret_key": "The secret key for API operations. You can retrieve this\n" +
 			"from the 'Security & Credentials' section of the AWS console.",
+
+		"token": "The session token for temporary credentials set in access_key and\n" +
+			"secret_key, e.g. from an SSO login.",
+
+		"profile": "The profile for API operations. If not set, the default profile\n" +
+			"created with `aws configure` will be used.",
+
+		"shared_credentials_file": "The path to the shared credentials file. If not set\n" +
+			"this defaults to ~/.aws/credentials.",
+
+		"metadata_endpoint": "The URL of the EC2 instance metadata service, used to find the\n" +
+			"region and instance profile credentials. Defaults to http://169.254.169.254/.",
+
+		"metadata_timeout": "How long to wait for each instance metadata request, as a Go\n" +
+			"duration string such as \"5s\". Defaults to 2s.",
+
+		"shared_config_file": "The path to the shared config file, read for the profile's\n" +
+			"credential_process. If not set this defaults to ~/.aws/config.",
+
+		"assume_role": "An IAM role to assume with STS. The resolved credentials are\n" +
+			"used to call AssumeRole and all operations use the temporary credentials.\n" +
+			"duration is a Go duration string such as \"1h\".",
+
+		"endpoints": "Override the endpoint URL of individual services, e.g. to\n" +
+			"target a local AWS emulator.",
+
+		"insecure": "Explicitly allow the provider to perform \"insecure\" SSL requests.\n" +
+			"If omitted, default value is `false`.",
+
+		"http_proxy": "The URL of an HTTP proxy for API requests, e.g. http://proxy:3128.\n" +
+			"If not set, the HTTPS_PROXY and NO_PROXY environment variables are used.",
+
+		"ca_bundle": "The path to a PEM file of CA certificates to trust instead of the\n" +
+			"system roots, e.g. for a proxy that intercepts TLS.",
+
+		"request_timeout": "The maximum time an API call may take, including retries, as a\n" +
+			"Go duration string such as \"5m\". If not set, calls don't time out.",
+
+		"skip_region_validation": "Skip validating the region name. Useful for AWS-like\n" +
+			"implementations that use their own region names.",
+
+		"max_retries": "The maximum number of times an API call is retried when it is\n" +
+			"throttled, fails with a server error or hits EC2 eventual consistency.\n" +
+			"Calls that change infrastructure are not retried after a network or\n" +
+			"server error, and a NotFound is only retried for 30 seconds.",
+
+		"validate_permissions": "Send every request that changes infrastructure with DryRun\n" +
+			"first, so a missing IAM permission fails before it is made.",
+
+		"max_requests_per_second": "Limit the API requests made by this provider to this\n" +
+			"many per second, shared across all resources. 0 means no limit.",
+
+		"max_requests_burst": "The number of requests that may be sent at once before\n" +
+			"max_requests_per_second applies. Defaults to max_requests_per_second.",
+
+		"allowed_account_ids": "Only allow the provider to run against these AWS account IDs.",
+
+		"forbidden_account_ids": "Refuse to run the provider against these AWS account IDs.",
+
+		"default_tags": "Tags applied to every resource created by this provider.\n" +
+			"Tags set on a resource override default tags with the same key.",
+
+		"ignore_tags": "Tag keys and key prefixes managed outside Terraform. They are\n" +
+			"never changed by the provider and don't show up as differences.",
 	}
 }
 
@@ -70,11 +345,166 @@ func envDefaultFunc(k string) schema.SchemaDefaultFunc {
 }
 
 func providerConfigure(d *schema.ResourceData) (interface{}, error) {
-	config := Config{
-		AccessKey: d.Get("access_key").(string),
-		SecretKey: d.Get("secret_key").(string),
-		Region:    d.Get("region").(string),
+	config, err := providerConfig(d)
+	if err != nil {
+		return nil, err
 	}
 
 	return config.Client()
 }
+
+// providerConfig reads the provider block into a Config.
+func providerConfig(d *schema.ResourceData) (*Config, error) {
+	config := &Config{
+		AccessKey:            d.Get("access_key").(string),
+		SecretKey:            d.Get("secret_key").(string),
+		Profile:              d.Get("profile").(string),
+		Token:                d.Get("token").(string),
+		CredsFilename:        d.Get("shared_credentials_file").(string),
+		ConfigFilename:       d.Get("shared_config_file").(string),
+		Region:               d.Get("region").(string),
+		MetadataEndpoint:     d.Get("metadata_endpoint").(string),
+		Insecure:             d.Get("insecure").(bool),
+		HTTPProxy:            d.Get("http_proxy").(string),
+		CABundle:             d.Get("ca_bundle").(string),
+		SkipRegionValidation: d.Get("skip_region_validation").(bool),
+		MaxRetries:           d.Get("max_retries").(int),
+		ValidatePermissions:  d.Get("validate_permissions").(bool),
+		MaxRequestsPerSecond: d.Get("max_requests_per_second").(int),
+		MaxRequestsBurst:     d.Get("max_requests_burst").(int),
+	}
+
+	if v := d.Get("metadata_timeout").(string); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid metadata_timeout %q: %s", v, err)
+		}
+		config.MetadataTimeout = timeout
+	}
+
+	if v := d.Get("request_timeout").(string); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid request_timeout %q: %s", v, err)
+		}
+		config.RequestTimeout = timeout
+	}
+
+	if v, ok := d.GetOk("allowed_account_ids"); ok {
+		config.AllowedAccountIds = expandStringSet(v.(*schema.Set))
+	}
+
+	if v, ok := d.GetOk("forbidden_account_ids"); ok {
+		config.ForbiddenAccountIds = expandStringSet(v.(*schema.Set))
+	}
+
+	if v, ok := d.GetOk("default_tags"); ok {
+		blocks := v.([]interface{})
+		if len(blocks) > 1 {
+			return nil, fmt.Errorf("Only one default_tags block may be specified")
+		}
+		if m, ok := blocks[0].(map[string]interface{}); ok {
+			config.DefaultTags = expandStringMap(m["tags"].(map[string]interface{}))
+		}
+	}
+
+	if v, ok := d.GetOk("ignore_tags"); ok {
+		blocks := v.([]interface{})
+		if len(blocks) > 1 {
+			return nil, fmt.Errorf("Only one ignore_tags block may be specified")
+		}
+		if m, ok := blocks[0].(map[string]interface{}); ok {
+			config.IgnoreTagKeys = expandStringSet(m["keys"].(*schema.Set))
+			config.IgnoreTagKeyPrefixes = expandStringSet(m["key_prefixes"].(*schema.Set))
+		}
+	}
+
+	if v, ok := d.GetOk("endpoints"); ok {
+		endpoints := v.([]interface{})
+		if len(endpoints) > 1 {
+			return nil, fmt.Errorf("Only one endpoints block may be specified")
+		}
+		config.Endpoints = expandEndpoints(endpoints[0].(map[string]interface{}))
+	}
+
+	if v, ok := d.GetOk("assume_role"); ok {
+		roles := v.([]interface{})
+		if len(roles) > 1 {
+			return nil, fmt.Errorf("Only one assume_role block may be specified")
+		}
+		role, err := expandAssumeRole(roles[0].(map[string]interface{}))
+		if err != nil {
+			return nil, err
+		}
+		config.AssumeRole = role
+	}
+
+	// With no region in the configuration or AWS_REGION, fall back to
+	// the region of the instance we're running on.
+	if config.Region == "" {
+		region, err := config.AWSRegion()
+		if err != nil {
+			return nil, fmt.Errorf("No region set and none found in instance metadata: %s", err)
+		}
+		config.Region = region
+	}
+
+	return config, nil
+}
+
+// endpointServices lists the services whose endpoint can be overridden in
+// the endpoints block. Add new service connections here.
+var endpointServices = []string{
+	"ec2",
+	"sts",
+}
+
+func endpointsSchema() *schema.Schema {
+	services := make(map[string]*schema.Schema)
+	for _, name := range endpointServices {
+		services[name] = &schema.Schema{
+			Type:        schema.TypeString,
+			Optional:    true,
+			Default:     "",
+			Description: fmt.Sprintf("Use this to override the default %s endpoint URL.", name),
+		}
+	}
+
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		Description: descriptions["endpoints"],
+		Elem: &schema.Resource{
+			Schema: services,
+		},
+	}
+}
+
+func expandEndpoints(m map[string]interface{}) map[string]string {
+	endpoints := make(map[string]string)
+	for _, name := range endpointServices {
+		if v, ok := m[name].(string); ok && v != "" {
+			endpoints[name] = v
+		}
+	}
+	return endpoints
+}
+
+func expandAssumeRole(m map[string]interface{}) (*AssumeRole, error) {
+	role := &AssumeRole{
+		RoleARN:     m["role_arn"].(string),
+		SessionName: m["session_name"].(string),
+		ExternalID:  m["external_id"].(string),
+		Policy:      m["policy"].(string),
+	}
+
+	if v := m["duration"].(string); v != "" {
+		duration, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid assume_role duration %q: %s", v, err)
+		}
+		role.Duration = duration
+	}
+
+	return role, nil
+}
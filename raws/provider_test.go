@@ -2,7 +2,11 @@ package raws
 
 import (
 	"log"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/hashicorp/terraform/helper/schema"
@@ -12,13 +16,72 @@ import (
 var testAccProviders map[string]terraform.ResourceProvider
 var testAccProvider *schema.Provider
 
+// The acceptance tests run against an in-process fake EC2 unless
+// RAWS_ACC_AWS is set, in which case they use a real AWS account.
+//
+// RAWS_ACC_FIXTURES=record also runs them against AWS, recording each
+// test's EC2 traffic to test-fixtures/<test>.json. With
+// RAWS_ACC_FIXTURES=replay the tests are answered from those fixtures
+// without network access; tests with no fixture are skipped.
+var (
+	testAccFakeEC2     *fakeEC2
+	testAccFakeEC2Once sync.Once
+
+	testAccFixture *fixture
+)
+
 func init() {
 	testAccProvider = Provider().(*schema.Provider)
+	testAccProvider.ConfigureFunc = testAccProviderConfigure
 	testAccProviders = map[string]terraform.ResourceProvider{
-		"aws": testAccProvider,
+		"raws": testAccProvider,
 	}
 }
 
+func testAccUseAWS() bool {
+	return os.Getenv("RAWS_ACC_AWS") != "" || testAccFixtureMode() == fixtureModeRecord
+}
+
+func testAccFixtureMode() string {
+	return os.Getenv("RAWS_ACC_FIXTURES")
+}
+
+func testAccProviderConfigure(d *schema.ResourceData) (interface{}, error) {
+	config, err := providerConfig(d)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case testAccFixtureMode() == fixtureModeReplay:
+		config.AccessKey = "fake_access_key"
+		config.SecretKey = "fake_secret_key"
+		config.AssumeRole = nil
+		config.wrapTransport = testAccFixtureTransport
+	case testAccFixtureMode() == fixtureModeRecord:
+		config.wrapTransport = testAccFixtureTransport
+	case !testAccUseAWS():
+		testAccFakeEC2Once.Do(func() {
+			testAccFakeEC2 = newFakeEC2()
+			// Like a real region, the fake has a default VPC to adopt.
+			testAccFakeEC2.createDefaultVpc()
+		})
+		config.AccessKey = "fake_access_key"
+		config.SecretKey = "fake_secret_key"
+		config.AssumeRole = nil
+		config.Endpoints = map[string]string{"ec2": testAccFakeEC2.URL}
+	}
+
+	return config.Client()
+}
+
+func testAccFixtureTransport(service string, base http.RoundTripper) http.RoundTripper {
+	if service != "ec2" {
+		return base
+	}
+	return &fixtureTransport{Fixture: testAccFixture, Base: base}
+}
+
 func TestProvider(t *testing.T) {
 	if err := Provider().(*schema.Provider).InternalValidate(); err != nil {
 		t.Fatalf("err: %s", err)
@@ -29,15 +92,75 @@ func TestProvider_impl(t *testing.T) {
 	var _ terraform.ResourceProvider = Provider()
 }
 
+func TestProviderConfig_region(t *testing.T) {
+	ts := newTestIMDSv2Server()
+	defer ts.Close()
+	defer os.Setenv("AWS_REGION", os.Getenv("AWS_REGION"))
+
+	cases := []struct {
+		Config map[string]interface{}
+		Env    string
+		Region string
+	}{
+		{
+			map[string]interface{}{"region": "us-west-1", "metadata_endpoint": ts.URL},
+			"ap-southeast-2",
+			"us-west-1",
+		},
+		{
+			map[string]interface{}{"metadata_endpoint": ts.URL},
+			"ap-southeast-2",
+			"ap-southeast-2",
+		},
+		{
+			map[string]interface{}{"metadata_endpoint": ts.URL},
+			"",
+			"eu-central-1",
+		},
+	}
+
+	p := Provider().(*schema.Provider)
+	for i, tc := range cases {
+		os.Setenv("AWS_REGION", tc.Env)
+		d := schema.TestResourceDataRaw(t, p.Schema, tc.Config)
+		config, err := providerConfig(d)
+		if err != nil {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+		if config.Region != tc.Region {
+			t.Fatalf("%d: expected region %s, got %s", i, tc.Region, config.Region)
+		}
+	}
+}
+
 func testAccPreCheck(t *testing.T) {
-	if v := os.Getenv("AWS_ACCESS_KEY_ID"); v == "" {
-		t.Fatal("AWS_ACCESS_KEY_ID must be set for acceptance tests")
+	switch mode := testAccFixtureMode(); mode {
+	case "":
+	case fixtureModeRecord, fixtureModeReplay:
+		name := strings.Replace(t.Name(), "/", "_", -1)
+		path := filepath.Join("test-fixtures", name+".json")
+		f, err := loadFixture(path, mode)
+		if os.IsNotExist(err) {
+			t.Skipf("No fixture recorded for %s", t.Name())
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		testAccFixture = f
+	default:
+		t.Fatalf("RAWS_ACC_FIXTURES must be %q or %q, got %q", fixtureModeRecord, fixtureModeReplay, mode)
 	}
-	if v := os.Getenv("AWS_SECRET_ACCESS_KEY"); v == "" {
-		t.Fatal("AWS_SECRET_ACCESS_KEY must be set for acceptance tests")
+
+	if testAccUseAWS() {
+		if v := os.Getenv("AWS_ACCESS_KEY_ID"); v == "" {
+			t.Fatal("AWS_ACCESS_KEY_ID must be set for acceptance tests against AWS")
+		}
+		if v := os.Getenv("AWS_SECRET_ACCESS_KEY"); v == "" {
+			t.Fatal("AWS_SECRET_ACCESS_KEY must be set for acceptance tests against AWS")
+		}
 	}
-	if v := os.Getenv("AWS_DEFAULT_REGION"); v == "" {
+	if v := os.Getenv("AWS_REGION"); v == "" {
 		log.Println("[INFO] Test: Using us-west-2 as test region")
-		os.Setenv("AWS_DEFAULT_REGION", "us-west-2")
+		os.Setenv("AWS_REGION", "us-west-2")
 	}
 }
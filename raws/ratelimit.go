@@ -0,0 +1,86 @@
+package raws
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by every connection of a provider
+// instance, so parallel resource operations together stay under the
+// configured request rate.
+type rateLimiter struct {
+	rate  float64 // tokens added per second
+	burst float64 // bucket size
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	// now and sleep are replaced in tests.
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func newRateLimiter(perSecond, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = perSecond
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:   float64(perSecond),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// Wait blocks until a request may be sent and returns how long it waited.
+func (l *rateLimiter) Wait() time.Duration {
+	l.mu.Lock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+
+	// Take the token now, even if that leaves the bucket in debt; the
+	// caller then sleeps until the debt would have been paid back. This
+	// keeps waiters in order without holding the lock while sleeping.
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait > 0 {
+		l.sleep(wait)
+	}
+	return wait
+}
+
+// rateLimitTransport waits on the shared limiter before every request.
+type rateLimitTransport struct {
+	Limiter *rateLimiter
+	Base    http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if wait := t.Limiter.Wait(); wait > 0 {
+		log.Printf("[DEBUG] Waited %s for the API rate limit (%s %s)", wait, req.Method, req.URL.Host)
+	}
+
+	if t.Base != nil {
+		return t.Base.RoundTrip(req)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
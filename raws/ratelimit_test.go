@@ -0,0 +1,78 @@
+package raws
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClock is a fake clock that only moves when the limiter sleeps.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestRateLimiter(t *testing.T) {
+	clock := &testClock{now: time.Unix(0, 0)}
+	l := newRateLimiter(10, 5)
+	l.now = clock.Now
+	l.sleep = clock.Sleep
+
+	// The burst goes through without waiting.
+	for i := 0; i < 5; i++ {
+		if wait := l.Wait(); wait != 0 {
+			t.Fatalf("request %d: unexpected wait %s", i, wait)
+		}
+	}
+
+	// After that requests are spaced at the configured rate.
+	for i := 0; i < 5; i++ {
+		wait := l.Wait()
+		if wait < 90*time.Millisecond || wait > 110*time.Millisecond {
+			t.Fatalf("request %d: expected ~100ms wait, got %s", i, wait)
+		}
+	}
+
+	// An idle bucket refills up to the burst size only.
+	clock.Sleep(time.Hour)
+	for i := 0; i < 5; i++ {
+		if wait := l.Wait(); wait != 0 {
+			t.Fatalf("refill %d: unexpected wait %s", i, wait)
+		}
+	}
+	if wait := l.Wait(); wait == 0 {
+		t.Fatal("expected bucket to be empty after the burst")
+	}
+}
+
+func TestRateLimiter_concurrent(t *testing.T) {
+	l := newRateLimiter(1000, 1)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Wait()
+		}()
+	}
+	wg.Wait()
+
+	// 49 requests over the burst at 1000/s take at least ~49ms.
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("limiter did not throttle concurrent callers: %s", elapsed)
+	}
+}
@@ -0,0 +1,72 @@
+package raws
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// resourceRawsDefaultSubnet is raws_subnet for the default subnet of an
+// availability zone: it is found rather than created, and left alone on
+// destroy.
+func resourceRawsDefaultSubnet() *schema.Resource {
+	r := resourceRawsSubnet()
+	r.Create = resourceRawsDefaultSubnetCreate
+	r.Delete = resourceRawsDefaultSubnetDelete
+
+	r.Schema["availability_zone"] = &schema.Schema{
+		Type:     schema.TypeString,
+		Required: true,
+		ForceNew: true,
+	}
+	r.Schema["vpc_id"] = &schema.Schema{
+		Type:     schema.TypeString,
+		Computed: true,
+	}
+	r.Schema["cidr_block"] = &schema.Schema{
+		Type:     schema.TypeString,
+		Computed: true,
+	}
+	// Default subnets map public IPs on launch; leaving this out keeps
+	// whatever the subnet has.
+	r.Schema["map_public_ip_on_launch"] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Computed: true,
+	}
+	return r
+}
+
+func resourceRawsDefaultSubnetCreate(d *schema.ResourceData, meta interface{}) error {
+	ec2conn := meta.(*AWSClient).codaConn
+	az := d.Get("availability_zone").(string)
+	resp, err := ec2conn.DescribeSubnets(&ec2.DescribeSubnetsRequest{
+		Filters: buildEC2Filters(map[string]string{
+			"availability-zone": az,
+			"default-for-az":    "true",
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("Error finding the default subnet in %s: %s", az, err)
+	}
+	if len(resp.Subnets) == 0 {
+		return fmt.Errorf("No default subnet found in %s", az)
+	}
+	d.SetId(*resp.Subnets[0].SubnetID)
+	log.Printf("[INFO] Adopting default subnet: %s", d.Id())
+	adoptTags(d, meta, resp.Subnets[0].Tags)
+
+	remote, err := readRemote(resourceRawsDefaultSubnet(), d.Id(), meta)
+	if err != nil {
+		return err
+	}
+	return updateSubnet(d, meta, changedFrom(d, remote))
+}
+
+func resourceRawsDefaultSubnetDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Printf("[WARN] Not deleting default subnet %s, only removing it from state", d.Id())
+	d.SetId("")
+	return nil
+}
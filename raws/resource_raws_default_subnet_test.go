@@ -0,0 +1,115 @@
+package raws
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccDefaultSubnet_basic(t *testing.T) {
+	var v ec2.Subnet
+
+	testCheck := func(*terraform.State) error {
+		if !*v.DefaultForAZ {
+			return fmt.Errorf("subnet %s is not the default for its zone", *v.SubnetID)
+		}
+		if *v.AvailabilityZone != "us-west-2a" {
+			return fmt.Errorf("bad availability zone: %s", *v.AvailabilityZone)
+		}
+		return nil
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckDefaultSubnetDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccDefaultSubnetConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSubnetExists("raws_default_subnet.a", &v),
+					testCheck,
+					testAccCheckTags(&v.Tags, "Name", "Default subnet"),
+					resource.TestCheckResourceAttr(
+						"raws_default_subnet.a", "map_public_ip_on_launch", "true"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDefaultSubnet_noPublicIP(t *testing.T) {
+	var v ec2.Subnet
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			if err := testAccCheckDefaultSubnetDestroy(s); err != nil {
+				return err
+			}
+			// Default subnets map public IPs on launch
+			conn := testAccProvider.Meta().(*AWSClient).codaConn
+			return modifySubnetMapPublicIP(conn, *v.SubnetID, true)
+		},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccDefaultSubnetConfigNoPublicIP,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSubnetExists("raws_default_subnet.a", &v),
+					func(*terraform.State) error {
+						if *v.MapPublicIPOnLaunch {
+							return fmt.Errorf("subnet %s still maps public IPs on launch", *v.SubnetID)
+						}
+						return nil
+					},
+					resource.TestCheckResourceAttr(
+						"raws_default_subnet.a", "map_public_ip_on_launch", "false"),
+				),
+			},
+		},
+	})
+}
+
+// testAccCheckDefaultSubnetDestroy checks that destroying
+// raws_default_subnet left the subnet in place.
+func testAccCheckDefaultSubnetDestroy(s *terraform.State) error {
+	conn := testAccProvider.Meta().(*AWSClient).codaConn
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "raws_default_subnet" {
+			continue
+		}
+		resp, err := conn.DescribeSubnets(&ec2.DescribeSubnetsRequest{
+			SubnetIDs: []string{rs.Primary.ID},
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.Subnets) == 0 {
+			return fmt.Errorf("Default subnet %s was deleted", rs.Primary.ID)
+		}
+	}
+
+	return nil
+}
+
+const testAccDefaultSubnetConfig = `
+resource "raws_default_subnet" "a" {
+	availability_zone = "us-west-2a"
+
+	tags {
+		Name = "Default subnet"
+	}
+}
+`
+
+const testAccDefaultSubnetConfigNoPublicIP = `
+resource "raws_default_subnet" "a" {
+	availability_zone = "us-west-2a"
+	map_public_ip_on_launch = false
+}
+`
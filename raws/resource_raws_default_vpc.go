@@ -0,0 +1,63 @@
+package raws
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// resourceRawsDefaultVpc is raws_vpc for the VPC every region comes with:
+// it is found rather than created, and left alone on destroy, keeping the
+// attributes and tags Terraform last gave it.
+func resourceRawsDefaultVpc() *schema.Resource {
+	r := resourceRawsVpc()
+	r.Create = resourceRawsDefaultVpcCreate
+	r.Delete = resourceRawsDefaultVpcDelete
+	r.Importer = &schema.ResourceImporter{
+		State: schema.ImportStatePassthrough,
+	}
+
+	r.Schema["cidr_block"] = &schema.Schema{
+		Type:     schema.TypeString,
+		Computed: true,
+	}
+	r.Schema["instance_tenancy"] = &schema.Schema{
+		Type:     schema.TypeString,
+		Computed: true,
+	}
+	// It is never deleted, so there is nothing to force
+	delete(r.Schema, "force_destroy")
+	return r
+}
+
+func resourceRawsDefaultVpcCreate(d *schema.ResourceData, meta interface{}) error {
+	ec2conn := meta.(*AWSClient).codaConn
+	resp, err := ec2conn.DescribeVPCs(&ec2.DescribeVPCsRequest{
+		Filters: buildEC2Filters(map[string]string{
+			"isDefault": "true",
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("Error finding the default VPC: %s", err)
+	}
+	if len(resp.VPCs) == 0 {
+		return fmt.Errorf("No default VPC found in %s", meta.(*AWSClient).region)
+	}
+	d.SetId(*resp.VPCs[0].VPCID)
+	log.Printf("[INFO] Adopting default VPC: %s", d.Id())
+	adoptTags(d, meta, resp.VPCs[0].Tags)
+
+	remote, err := readRemote(resourceRawsDefaultVpc(), d.Id(), meta)
+	if err != nil {
+		return err
+	}
+	return updateVpc(d, meta, changedFrom(d, remote))
+}
+
+func resourceRawsDefaultVpcDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Printf("[WARN] Not deleting default VPC %s, only removing it from state", d.Id())
+	d.SetId("")
+	return nil
+}
@@ -0,0 +1,173 @@
+package raws
+
+import (
+	"fmt"
+	"testing"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccDefaultVpc_basic(t *testing.T) {
+	var vpc ec2.VPC
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckDefaultVpcDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccDefaultVpcConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVpcExists("raws_default_vpc.default", &vpc),
+					testAccCheckVpcCidr(&vpc, "172.31.0.0/16"),
+					testAccCheckVpcDefaults("raws_default_vpc.default", &vpc),
+					testAccCheckTags(&vpc.Tags, "Name", "Default VPC"),
+					resource.TestCheckResourceAttr(
+						"raws_default_vpc.default", "cidr_block", "172.31.0.0/16"),
+					resource.TestCheckResourceAttr(
+						"raws_default_vpc.default", "enable_dns_hostnames", "true"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDefaultVpc_existingTags(t *testing.T) {
+	var vpc ec2.VPC
+
+	// Tag the default VPC outside of Terraform before it is adopted
+	tagDefaultVpc := func(create bool) error {
+		if testAccProvider.Meta() == nil {
+			if err := testAccProvider.Configure(terraform.NewResourceConfig(nil)); err != nil {
+				return err
+			}
+		}
+		conn := testAccProvider.Meta().(*AWSClient).codaConn
+		resp, err := conn.DescribeVPCs(&ec2.DescribeVPCsRequest{
+			Filters: buildEC2Filters(map[string]string{"isDefault": "true"}),
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.VPCs) == 0 {
+			return fmt.Errorf("No default VPC found")
+		}
+		tags := []ec2.Tag{tag("raws-test-existing", "kept")}
+		if create {
+			return conn.CreateTags(&ec2.CreateTagsRequest{Resources: []string{*resp.VPCs[0].VPCID}, Tags: tags})
+		}
+		return conn.DeleteTags(&ec2.DeleteTagsRequest{Resources: []string{*resp.VPCs[0].VPCID}, Tags: tags})
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			if err := testAccCheckDefaultVpcDestroy(s); err != nil {
+				return err
+			}
+			return tagDefaultVpc(false)
+		},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				PreConfig: func() {
+					if err := tagDefaultVpc(true); err != nil {
+						t.Fatalf("err: %s", err)
+					}
+				},
+				Config: testAccDefaultVpcConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVpcExists("raws_default_vpc.default", &vpc),
+					testAccCheckTags(&vpc.Tags, "Name", "Default VPC"),
+					testAccCheckTags(&vpc.Tags, "raws-test-existing", "kept"),
+					resource.TestCheckResourceAttr(
+						"raws_default_vpc.default", "tags.raws-test-existing", "kept"),
+				),
+				// The next plan removes the tag the config doesn't have
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func TestAccDefaultVpc_disabledDns(t *testing.T) {
+	var vpc ec2.VPC
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			if err := testAccCheckDefaultVpcDestroy(s); err != nil {
+				return err
+			}
+			// Give the default VPC back its DNS settings
+			conn := testAccProvider.Meta().(*AWSClient).codaConn
+			for _, attr := range []*ec2.ModifyVPCAttributeRequest{
+				&ec2.ModifyVPCAttributeRequest{VPCID: vpc.VPCID, EnableDNSSupport: &ec2.AttributeBooleanValue{Value: codaws.Boolean(true)}},
+				&ec2.ModifyVPCAttributeRequest{VPCID: vpc.VPCID, EnableDNSHostnames: &ec2.AttributeBooleanValue{Value: codaws.Boolean(true)}},
+			} {
+				if err := conn.ModifyVPCAttribute(attr); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccDefaultVpcConfigDisabledDns,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVpcExists("raws_default_vpc.default", &vpc),
+					testAccCheckVpcAttribute(&vpc, "enableDnsSupport", false),
+					testAccCheckVpcAttribute(&vpc, "enableDnsHostnames", false),
+					resource.TestCheckResourceAttr(
+						"raws_default_vpc.default", "enable_dns_support", "false"),
+					resource.TestCheckResourceAttr(
+						"raws_default_vpc.default", "enable_dns_hostnames", "false"),
+				),
+			},
+		},
+	})
+}
+
+// testAccCheckDefaultVpcDestroy checks that destroying raws_default_vpc left
+// the VPC in place.
+func testAccCheckDefaultVpcDestroy(s *terraform.State) error {
+	conn := testAccProvider.Meta().(*AWSClient).codaConn
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "raws_default_vpc" {
+			continue
+		}
+		resp, err := conn.DescribeVPCs(&ec2.DescribeVPCsRequest{
+			VPCIDs: []string{rs.Primary.ID},
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.VPCs) == 0 {
+			return fmt.Errorf("Default VPC %s was deleted", rs.Primary.ID)
+		}
+	}
+
+	return nil
+}
+
+const testAccDefaultVpcConfig = `
+resource "raws_default_vpc" "default" {
+	enable_dns_hostnames = true
+
+	tags {
+		Name = "Default VPC"
+	}
+}
+`
+
+const testAccDefaultVpcConfigDisabledDns = `
+resource "raws_default_vpc" "default" {
+	enable_dns_support = false
+	enable_dns_hostnames = false
+}
+`
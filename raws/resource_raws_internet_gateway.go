@@ -17,6 +17,10 @@ func resourceRawsInternetGateway() *schema.Resource {
 		Read:   resourceRawsInternetGatewayRead,
 		Update: resourceRawsInternetGatewayUpdate,
 		Delete: resourceRawsInternetGatewayDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+		CustomizeDiff: customizeDiffTags,
 
 		Schema: map[string]*schema.Schema{
 			"vpc_id": &schema.Schema{
@@ -24,6 +28,9 @@ func resourceRawsInternetGateway() *schema.Resource {
 				Required: true,
 				ForceNew: true,
 			},
+
+			"tags":     tagsSchema(),
+			"tags_all": tagsAllSchema(),
 		},
 	}
 }
@@ -31,14 +38,20 @@ func resourceRawsInternetGateway() *schema.Resource {
 func resourceRawsInternetGatewayCreate(d *schema.ResourceData, meta interface{}) error {
 	ec2conn := meta.(*AWSClient).codaConn
 	log.Printf("[DEBUG] Creating internet gateway")
-	resp, err := ec2conn.CreateInternetGateway(nil)
+	resp, err := ec2conn.CreateInternetGateway(&ec2.CreateInternetGatewayRequest{})
 	if err != nil {
 		return fmt.Errorf("Error creating internet gateway: %s", err)
 	}
 	ig := resp.InternetGateway
 	d.SetId(*ig.InternetGatewayID)
 	log.Printf("[INFO] InternetGateway ID: %s", d.Id())
-	return resourceAwsInternetGatewayAttach(d, meta)
+	if err := setTags(ec2conn, d, meta); err != nil {
+		return err
+	}
+	if err := resourceAwsInternetGatewayAttach(d, meta); err != nil {
+		return err
+	}
+	return resourceRawsInternetGatewayRead(d, meta)
 }
 
 func resourceRawsInternetGatewayRead(d *schema.ResourceData, meta interface{}) error {
@@ -52,15 +65,27 @@ func resourceRawsInternetGatewayRead(d *schema.ResourceData, meta interface{}) e
 		return nil
 	}
 	ig := igRaw.(*ec2.InternetGateway)
-	d.Set("vpc_id", *ig.Attachments[0].VPCID)
+	if len(ig.Attachments) > 0 {
+		d.Set("vpc_id", *ig.Attachments[0].VPCID)
+	}
+	readTags(d, meta, ig.Tags)
 	return nil
 }
 
 func resourceRawsInternetGatewayUpdate(d *schema.ResourceData, meta interface{}) error {
-	if err := resourceAwsInternetGatewayDetach(d, meta); err != nil {
+	ec2conn := meta.(*AWSClient).codaConn
+	if d.HasChange("vpc_id") {
+		if err := resourceAwsInternetGatewayDetach(d, meta); err != nil {
+			return err
+		}
+		if err := resourceAwsInternetGatewayAttach(d, meta); err != nil {
+			return err
+		}
+	}
+	if err := setTags(ec2conn, d, meta); err != nil {
 		return err
 	}
-	return resourceAwsInternetGatewayAttach(d, meta)
+	return resourceRawsInternetGatewayRead(d, meta)
 }
 
 func resourceRawsInternetGatewayDelete(d *schema.ResourceData, meta interface{}) error {
@@ -68,38 +93,47 @@ func resourceRawsInternetGatewayDelete(d *schema.ResourceData, meta interface{})
 	if err := resourceAwsInternetGatewayDetach(d, meta); err != nil {
 		return err
 	}
-	log.Printf("[INFO] Deleting Internet Gateway: %s", d.Id())
-	return resource.Retry(5*time.Minute, func() error {
-		IgId := d.Id()
+	return deleteInternetGateway(ec2conn, d.Id())
+}
+
+// deleteInternetGateway deletes a detached internet gateway, retrying
+// while the detachment propagates, and waits for it to be gone.
+func deleteInternetGateway(conn EC2API, IgId string) error {
+	log.Printf("[INFO] Deleting Internet Gateway: %s", IgId)
+	err := resource.Retry(5*time.Minute, func() *resource.RetryError {
 		DelIGOpts := &ec2.DeleteInternetGatewayRequest{
 			InternetGatewayID: &IgId,
 		}
-		err := ec2conn.DeleteInternetGateway(DelIGOpts)
-		if err != nil {
-			ec2err, ok := err.(*codaws.APIError)
-			if !ok {
-				return err
-			}
-			switch ec2err.Code {
-			case "InvalidInternetGatewayID.NotFound":
-				return nil
-			case "DependencyViolation":
-				return err
-			default:
-				return resource.RetryError{err}
-			}
+		err := conn.DeleteInternetGateway(DelIGOpts)
+		if err == nil {
+			return nil
+		}
+		ec2err, ok := err.(*codaws.APIError)
+		if !ok {
+			return resource.RetryableError(err)
+		}
+		switch ec2err.Code {
+		case "InvalidInternetGatewayID.NotFound":
+			return nil
+		case "DependencyViolation":
+			// The detachment may not have propagated yet
+			return resource.RetryableError(err)
 		}
-		return fmt.Errorf("Error deleting internet gateway: %s", err)
+		return resource.NonRetryableError(fmt.Errorf("Error deleting internet gateway: %s", err))
 	})
-	log.Printf("[DEBUG] Waiting for internet gateway (%s) to delete", d.Id())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] Waiting for internet gateway (%s) to delete", IgId)
 	stateConf := &resource.StateChangeConf{
 		Pending: []string{"available"},
-		Target:  "",
-		Refresh: IGStateRefreshFunc(ec2conn, d.Id()),
+		Target:  []string{},
+		Refresh: IGStateRefreshFunc(conn, IgId),
 		Timeout: 10 * time.Minute,
 	}
 	if _, err := stateConf.WaitForState(); err != nil {
-		return fmt.Errorf("Error waiting for internet gateway (%s) to destroy: %s", d.Id(), err)
+		return fmt.Errorf("Error waiting for internet gateway (%s) to destroy: %s", IgId, err)
 	}
 	return nil
 }
@@ -118,7 +152,7 @@ func resourceAwsInternetGatewayAttach(d *schema.ResourceData, meta interface{})
 	log.Printf("[DEBUG] Waiting for internet gateway (%s) to Attach", d.Id())
 	stateConf := &resource.StateChangeConf{
 		Pending: []string{"detached", "attaching"},
-		Target:  "available",
+		Target:  []string{"available"},
 		Refresh: IGAttachStateRefreshFunc(ec2conn, d.Id(), "available"),
 		Timeout: 1 * time.Minute,
 	}
@@ -159,7 +193,7 @@ func resourceAwsInternetGatewayDetach(d *schema.ResourceData, meta interface{})
 	log.Printf("[DEBUG] Waiting for internet gateway (%s) to detach", d.Id())
 	stateConf := &resource.StateChangeConf{
 		Pending: []string{"attached", "detaching", "available"},
-		Target:  "detached",
+		Target:  []string{"detached"},
 		Refresh: IGAttachStateRefreshFunc(ec2conn, d.Id(), "detached"),
 		Timeout: 1 * time.Minute,
 	}
@@ -169,8 +203,11 @@ func resourceAwsInternetGatewayDetach(d *schema.ResourceData, meta interface{})
 	return nil
 }
 
-func IGStateRefreshFunc(ec2conn *ec2.EC2, id string) resource.StateRefreshFunc {
+func IGStateRefreshFunc(ec2conn EC2API, id string) resource.StateRefreshFunc {
 	return func() (interface{}, string, error) {
+		DescribeIGWOpts := &ec2.DescribeInternetGatewaysRequest{
+			InternetGatewayIDs: []string{id},
+		}
 		resp, err := ec2conn.DescribeInternetGateways(DescribeIGWOpts)
 		if err != nil {
 			ec2err, ok := err.(*codaws.APIError)
@@ -184,16 +221,20 @@ func IGStateRefreshFunc(ec2conn *ec2.EC2, id string) resource.StateRefreshFunc {
 		if resp == nil {
 			return nil, "", nil
 		}
-		for _, igw := range resp.InternetGateways {
-			if *igw.InternetGatewayID == id {
-				ig := &igw
+		var ig *ec2.InternetGateway
+		for i := range resp.InternetGateways {
+			if *resp.InternetGateways[i].InternetGatewayID == id {
+				ig = &resp.InternetGateways[i]
 			}
 		}
+		if ig == nil {
+			return nil, "", nil
+		}
 		return ig, "available", nil
 	}
 }
 
-func IGAttachStateRefreshFunc(conn *ec2.EC2, id string, expected string) resource.StateRefreshFunc {
+func IGAttachStateRefreshFunc(conn EC2API, id string, expected string) resource.StateRefreshFunc {
 	var start time.Time
 	return func() (interface{}, string, error) {
 		if start.IsZero() {
@@ -224,5 +265,4 @@ func IGAttachStateRefreshFunc(conn *ec2.EC2, id string, expected string) resourc
 		}
 		return ig, *ig.Attachments[0].State, nil
 	}
-	return nil
 }
@@ -0,0 +1,184 @@
+package raws
+
+import (
+	"fmt"
+	"testing"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccInternetGateway(t *testing.T) {
+	var ig ec2.InternetGateway
+
+	testCheck := func(*terraform.State) error {
+		if len(ig.Attachments) != 1 {
+			return fmt.Errorf("bad attachments: %d", len(ig.Attachments))
+		}
+		if *ig.Attachments[0].State != "available" {
+			return fmt.Errorf("bad attachment state: %s", *ig.Attachments[0].State)
+		}
+		return nil
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckInternetGatewayDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccInternetGatewayConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckInternetGatewayExists("raws_internet_gateway.foo", &ig),
+					testCheck,
+					testAccCheckTags(&ig.Tags, "foo", "bar"),
+				),
+			},
+			resource.TestStep{
+				Config: testAccInternetGatewayConfigTagsUpdate,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckInternetGatewayExists("raws_internet_gateway.foo", &ig),
+					testAccCheckTags(&ig.Tags, "foo", ""),
+					testAccCheckTags(&ig.Tags, "bar", "baz"),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckInternetGatewayDestroy(s *terraform.State) error {
+	conn := testAccProvider.Meta().(*AWSClient).codaConn
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "raws_internet_gateway" {
+			continue
+		}
+
+		// Try to find the resource
+		resp, err := conn.DescribeInternetGateways(&ec2.DescribeInternetGatewaysRequest{
+			InternetGatewayIDs: []string{rs.Primary.ID},
+		})
+		if err == nil {
+			if len(resp.InternetGateways) > 0 {
+				return fmt.Errorf("still exists")
+			}
+
+			return nil
+		}
+
+		// Verify the error is what we want
+		ec2err, ok := err.(*codaws.APIError)
+		if !ok {
+			return err
+		}
+		if ec2err.Code != "InvalidInternetGatewayID.NotFound" {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func testAccCheckInternetGatewayExists(n string, ig *ec2.InternetGateway) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+
+		if rs.Primary.ID == "" {
+			return fmt.Errorf("No ID is set")
+		}
+
+		conn := testAccProvider.Meta().(*AWSClient).codaConn
+		resp, err := conn.DescribeInternetGateways(&ec2.DescribeInternetGatewaysRequest{
+			InternetGatewayIDs: []string{rs.Primary.ID},
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.InternetGateways) == 0 {
+			return fmt.Errorf("InternetGateway not found")
+		}
+
+		*ig = resp.InternetGateways[0]
+
+		return nil
+	}
+}
+
+func TestDeleteInternetGateway(t *testing.T) {
+	igwID := "igw-1"
+	conn := &mockEC2{internetGateways: []ec2.InternetGateway{
+		ec2.InternetGateway{InternetGatewayID: &igwID},
+	}}
+	if err := deleteInternetGateway(conn, igwID); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(conn.internetGateways) != 0 {
+		t.Fatalf("internet gateway not deleted: %v", conn.calls)
+	}
+
+	// Already gone
+	conn = &mockEC2{errs: map[string]error{
+		"DeleteInternetGateway": &codaws.APIError{Code: "InvalidInternetGatewayID.NotFound"},
+	}}
+	if err := deleteInternetGateway(conn, igwID); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	conn = &mockEC2{errs: map[string]error{
+		"DeleteInternetGateway": &codaws.APIError{Code: "UnauthorizedOperation"},
+	}}
+	if err := deleteInternetGateway(conn, igwID); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestAccInternetGateway_importBasic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckInternetGatewayDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccInternetGatewayConfig,
+			},
+			resource.TestStep{
+				ResourceName:      "raws_internet_gateway.foo",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+const testAccInternetGatewayConfig = `
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+}
+
+resource "raws_internet_gateway" "foo" {
+	vpc_id = "${raws_vpc.foo.id}"
+
+	tags {
+		foo = "bar"
+	}
+}
+`
+
+const testAccInternetGatewayConfigTagsUpdate = `
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+}
+
+resource "raws_internet_gateway" "foo" {
+	vpc_id = "${raws_vpc.foo.id}"
+
+	tags {
+		bar = "baz"
+	}
+}
+`
@@ -6,11 +6,11 @@ import (
 	"log"
 	"time"
 
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
 	"github.com/hashicorp/terraform/helper/hashcode"
 	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/helper/schema"
-	codaws "github.com/awslabs/aws-sdk-go/aws"
-	"github.com/awslabs/aws-sdk-go/gen/ec2"
 )
 
 func resourceRawsRouteTable() *schema.Resource {
@@ -19,6 +19,10 @@ func resourceRawsRouteTable() *schema.Resource {
 		Read:   resourceRawsRouteTableRead,
 		Update: resourceRawsRouteTableUpdate,
 		Delete: resourceRawsRouteTableDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+		CustomizeDiff: customizeDiffTags,
 
 		Schema: map[string]*schema.Schema{
 			"vpc_id": &schema.Schema{
@@ -34,7 +38,12 @@ func resourceRawsRouteTable() *schema.Resource {
 					Schema: map[string]*schema.Schema{
 						"cidr_block": &schema.Schema{
 							Type:     schema.TypeString,
-							Required: true,
+							Optional: true,
+						},
+
+						"ipv6_cidr_block": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
 						},
 
 						"gateway_id": &schema.Schema{
@@ -50,6 +59,9 @@ func resourceRawsRouteTable() *schema.Resource {
 				},
 				Set: resourceAwsRouteTableHash,
 			},
+
+			"tags":     tagsSchema(),
+			"tags_all": tagsAllSchema(),
 		},
 	}
 }
@@ -60,7 +72,7 @@ func resourceRawsRouteTableCreate(d *schema.ResourceData, meta interface{}) erro
 	CreateRouteOpts := &ec2.CreateRouteTableRequest{
 		VPCID: &vpcId,
 	}
-	log.Printf("[DEBUG] RouteTable create config: %#v", CreateRouteOpts)
+	log.Printf("[DEBUG] Creating route table in VPC %s", vpcId)
 	resp, err := ec2conn.CreateRouteTable(CreateRouteOpts)
 	if err != nil {
 		return fmt.Errorf("Error creating route table: %s", err)
@@ -70,7 +82,7 @@ func resourceRawsRouteTableCreate(d *schema.ResourceData, meta interface{}) erro
 	log.Printf("[DEBUG] Waiting for route table (%s) to become available", d.Id())
 	stateConf := &resource.StateChangeConf{
 		Pending: []string{"pending"},
-		Target:  "ready",
+		Target:  []string{"ready"},
 		Refresh: resourceAwsRouteTableStateRefreshFunc(ec2conn, d.Id()),
 		Timeout: 1 * time.Minute,
 	}
@@ -87,27 +99,61 @@ func resourceRawsRouteTableRead(d *schema.ResourceData, meta interface{}) error
 		return err
 	}
 	if rtRaw == nil {
+		log.Printf("[WARN] Route table (%s) not found, removing from state", d.Id())
+		d.SetId("")
 		return nil
 	}
 	rt := rtRaw.(*ec2.RouteTable)
 	d.Set("vpc_id", *rt.VPCID)
 	route := &schema.Set{F: resourceAwsRouteTableHash}
 	for _, r := range rt.Routes {
-		if *r.GatewayID == "local" {
+		if r.GatewayID != nil && *r.GatewayID == "local" {
+			continue
+		}
+		if r.DestinationCIDRBlock == nil || *r.DestinationCIDRBlock == "" {
 			continue
 		}
 		m := make(map[string]interface{})
-		m["cidr_block"] = r.DestinationCIDRBlock
-		if *r.GatewayID != "" {
+		m["cidr_block"] = *r.DestinationCIDRBlock
+		if r.GatewayID != nil && *r.GatewayID != "" {
 			m["gateway_id"] = *r.GatewayID
 		}
 
-		if *r.InstanceID != "" {
+		if r.InstanceID != nil && *r.InstanceID != "" {
 			m["instance_id"] = *r.InstanceID
 		}
 		route.Add(m)
 	}
+
+	// The generated client doesn't see IPv6 destinations
+	routeTableId := d.Id()
+	resp, err := ec2conn.DescribeIPv6Routes(&describeIPv6RoutesRequest{
+		RouteTableIDs: []string{routeTableId},
+	})
+	if err != nil {
+		return fmt.Errorf("Error reading the IPv6 routes of route table %s: %s", d.Id(), err)
+	}
+	for _, table := range resp.RouteTables {
+		for _, r := range table.Routes {
+			if r.GatewayID != nil && *r.GatewayID == "local" {
+				continue
+			}
+			if r.DestinationIPv6CIDRBlock == nil || *r.DestinationIPv6CIDRBlock == "" {
+				continue
+			}
+			m := make(map[string]interface{})
+			m["ipv6_cidr_block"] = *r.DestinationIPv6CIDRBlock
+			if r.GatewayID != nil && *r.GatewayID != "" {
+				m["gateway_id"] = *r.GatewayID
+			}
+			if r.InstanceID != nil && *r.InstanceID != "" {
+				m["instance_id"] = *r.InstanceID
+			}
+			route.Add(m)
+		}
+	}
 	d.Set("route", route)
+	readTags(d, meta, rt.Tags)
 
 	return nil
 }
@@ -117,41 +163,97 @@ func resourceRawsRouteTableUpdate(d *schema.ResourceData, meta interface{}) erro
 	routeId := d.Id()
 	if d.HasChange("route") {
 		o, n := d.GetChange("route")
-		ors := o.(*schema.Set).Difference(n.(*schema.Set))
-		nrs := n.(*schema.Set).Difference(o.(*schema.Set))
-		for _, route := range ors.List() {
-			m := route.(map[string]interface{})
-			DestCIDR := m["cidr_block"].(string)
-			DelRouteOpts := &ec2.DeleteRouteRequest{
-				RouteTableID:         &routeId,
+		err := updateRouteTableRoutes(ec2conn, routeId, o.(*schema.Set), n.(*schema.Set), func(routes *schema.Set) {
+			d.Set("route", routes)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := setTags(ec2conn, d, meta); err != nil {
+		return err
+	}
+	return resourceRawsRouteTableRead(d, meta)
+}
+
+// updateRouteTableRoutes deletes the routes in o that are not in n before
+// creating the routes in n that are not in o, so a route can move to a
+// new target without conflicting with itself. After each step the routes
+// known to exist are passed to setRoutes, so a failure part way through
+// leaves an accurate state.
+func updateRouteTableRoutes(conn EC2API, id string, o, n *schema.Set, setRoutes func(*schema.Set)) error {
+	for _, route := range o.Difference(n).List() {
+		m := route.(map[string]interface{})
+		DestCIDR, DestIPv6CIDR := routeDestination(m)
+		log.Printf("[INFO] Deleting route from %s: %s%s", id, DestCIDR, DestIPv6CIDR)
+		var err error
+		if DestIPv6CIDR != "" {
+			err = conn.DeleteIPv6Route(&deleteIPv6RouteRequest{
+				RouteTableID:             &id,
+				DestinationIPv6CIDRBlock: &DestIPv6CIDR,
+			})
+		} else {
+			err = conn.DeleteRoute(&ec2.DeleteRouteRequest{
+				RouteTableID:         &id,
 				DestinationCIDRBlock: &DestCIDR,
-			}
-			if err := ec2conn.DeleteRoute(DelRouteOpts); err != nil {
-				return err
-			}
+			})
 		}
-		routes := o.(*schema.Set).Intersection(n.(*schema.Set))
-		d.Set("route", routes)
+		if err != nil {
+			return err
+		}
+	}
+	routes := o.Intersection(n)
+	setRoutes(routes)
 
-		for _, route := range nrs.List() {
-			m := route.(map[string]interface{})
-			Gateway := m["gateway_id"].(string)
-			CIDRBlock := m["cidr_block"].(string)
-			Instance := m["instance_id"].(string)
-			CreateRouteOpts := &ec2.CreateRouteRequest{
-				RouteTableID:         &routeId,
+	for _, route := range n.Difference(o).List() {
+		m := route.(map[string]interface{})
+		Gateway, _ := m["gateway_id"].(string)
+		Instance, _ := m["instance_id"].(string)
+		CIDRBlock, IPv6CIDRBlock := routeDestination(m)
+		if (CIDRBlock == "") == (IPv6CIDRBlock == "") {
+			return fmt.Errorf("Error creating route in %s: exactly one of cidr_block and ipv6_cidr_block must be set", id)
+		}
+
+		var GatewayID, InstanceID codaws.StringValue
+		if Gateway != "" {
+			GatewayID = &Gateway
+		}
+		if Instance != "" {
+			InstanceID = &Instance
+		}
+		log.Printf("[INFO] Creating route in %s: %s%s", id, CIDRBlock, IPv6CIDRBlock)
+		var err error
+		if IPv6CIDRBlock != "" {
+			err = conn.CreateIPv6Route(&createIPv6RouteRequest{
+				RouteTableID:             &id,
+				DestinationIPv6CIDRBlock: &IPv6CIDRBlock,
+				GatewayID:                GatewayID,
+				InstanceID:               InstanceID,
+			})
+		} else {
+			err = conn.CreateRoute(&ec2.CreateRouteRequest{
+				RouteTableID:         &id,
 				DestinationCIDRBlock: &CIDRBlock,
-				GatewayID:            &Gateway,
-				InstanceID:           &Instance,
-			}
-			if err := ec2conn.CreateRoute(CreateRouteOpts); err != nil {
-				return err
-			}
-			routes.Add(route)
-			d.Set("route", routes)
+				GatewayID:            GatewayID,
+				InstanceID:           InstanceID,
+			})
+		}
+		if err != nil {
+			return err
 		}
+		routes.Add(route)
+		setRoutes(routes)
 	}
-	return resourceRawsRouteTableRead(d, meta)
+
+	return nil
+}
+
+// routeDestination returns the IPv4 and IPv6 destinations of a route. Only
+// one of them should be set.
+func routeDestination(m map[string]interface{}) (string, string) {
+	cidr, _ := m["cidr_block"].(string)
+	ipv6CIDR, _ := m["ipv6_cidr_block"].(string)
+	return cidr, ipv6CIDR
 }
 
 func resourceRawsRouteTableDelete(d *schema.ResourceData, meta interface{}) error {
@@ -166,7 +268,7 @@ func resourceRawsRouteTableDelete(d *schema.ResourceData, meta interface{}) erro
 	rt := rtRaw.(*ec2.RouteTable)
 
 	for _, a := range rt.Associations {
-		log.Printf("[INFO] Disassociating association: %s", a.RouteTableAssociationID)
+		log.Printf("[INFO] Disassociating association: %s", *a.RouteTableAssociationID)
 		DisaccocRouteTableOpts := &ec2.DisassociateRouteTableRequest{
 			AssociationID: a.RouteTableAssociationID,
 		}
@@ -193,7 +295,7 @@ func resourceRawsRouteTableDelete(d *schema.ResourceData, meta interface{}) erro
 	log.Printf("[DEBUG] Waiting for route table (%s) to become destroyed", d.Id())
 	stateConf := &resource.StateChangeConf{
 		Pending: []string{"ready"},
-		Target:  "",
+		Target:  []string{},
 		Refresh: resourceAwsRouteTableStateRefreshFunc(ec2conn, d.Id()),
 		Timeout: 1 * time.Minute,
 	}
@@ -203,10 +305,17 @@ func resourceRawsRouteTableDelete(d *schema.ResourceData, meta interface{}) erro
 	return nil
 }
 
+// resourceAwsRouteTableHash hashes a route by its destination and target.
+// The IPv6 destination is only added when set, so routes to IPv4
+// destinations keep the hashes they had before IPv6 was supported.
 func resourceAwsRouteTableHash(v interface{}) int {
 	var buf bytes.Buffer
 	m := v.(map[string]interface{})
-	buf.WriteString(fmt.Sprintf("%s-", m["cidr_block"].(string)))
+	cidr, ipv6CIDR := routeDestination(m)
+	buf.WriteString(fmt.Sprintf("%s-", cidr))
+	if ipv6CIDR != "" {
+		buf.WriteString(fmt.Sprintf("ipv6:%s-", ipv6CIDR))
+	}
 
 	if v, ok := m["gateway_id"]; ok {
 		buf.WriteString(fmt.Sprintf("%s-", v.(string)))
@@ -218,7 +327,7 @@ func resourceAwsRouteTableHash(v interface{}) int {
 	return hashcode.String(buf.String())
 }
 
-func resourceAwsRouteTableStateRefreshFunc(conn *ec2.EC2, id string) resource.StateRefreshFunc {
+func resourceAwsRouteTableStateRefreshFunc(conn EC2API, id string) resource.StateRefreshFunc {
 	return func() (interface{}, string, error) {
 		DescribeRouteOpts := &ec2.DescribeRouteTablesRequest{
 			RouteTableIDs: []string{id},
@@ -3,10 +3,11 @@ package raws
 import (
 	"fmt"
 	"log"
+	"strings"
 
-	"github.com/hashicorp/terraform/helper/schema"
 	codaws "github.com/awslabs/aws-sdk-go/aws"
 	"github.com/awslabs/aws-sdk-go/gen/ec2"
+	"github.com/hashicorp/terraform/helper/schema"
 )
 
 func resourceRawsRouteTableAssociation() *schema.Resource {
@@ -15,6 +16,9 @@ func resourceRawsRouteTableAssociation() *schema.Resource {
 		Read:   resourceRawsRouteTableAssociationRead,
 		Update: resourceRawsRouteTableAssociationUpdate,
 		Delete: resourceRawsRouteTableAssociationDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceRawsRouteTableAssociationImport,
+		},
 
 		Schema: map[string]*schema.Schema{
 			"subnet_id": &schema.Schema{
@@ -55,13 +59,14 @@ func resourceRawsRouteTableAssociationRead(d *schema.ResourceData, meta interfac
 		return err
 	}
 	if rtRaw == nil {
+		log.Printf("[WARN] Route table (%s) not found, removing association (%s) from state", d.Get("route_table_id").(string), d.Id())
+		d.SetId("")
 		return nil
 	}
 	rt := rtRaw.(*ec2.RouteTable)
 	found := false
-	routeId := d.Id()
 	for _, a := range rt.Associations {
-		if a.RouteTableAssociationID == &routeId {
+		if a.RouteTableAssociationID != nil && *a.RouteTableAssociationID == d.Id() {
 			found = true
 			d.Set("subnet_id", a.SubnetID)
 			break
@@ -115,3 +120,34 @@ func resourceRawsRouteTableAssociationDelete(d *schema.ResourceData, meta interf
 	}
 	return nil
 }
+
+// resourceRawsRouteTableAssociationImport takes a "subnet_id/route_table_id"
+// ID and looks up the association between the two.
+func resourceRawsRouteTableAssociationImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	parts := strings.Split(d.Id(), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("Unexpected format of ID (%q), expected subnet_id/route_table_id", d.Id())
+	}
+	subnetId, routeTableId := parts[0], parts[1]
+
+	ec2conn := meta.(*AWSClient).codaConn
+	rtRaw, _, err := resourceAwsRouteTableStateRefreshFunc(ec2conn, routeTableId)()
+	if err != nil {
+		return nil, err
+	}
+	if rtRaw == nil {
+		return nil, fmt.Errorf("Route table %s not found", routeTableId)
+	}
+	rt := rtRaw.(*ec2.RouteTable)
+
+	for _, a := range rt.Associations {
+		if a.SubnetID != nil && *a.SubnetID == subnetId {
+			d.SetId(*a.RouteTableAssociationID)
+			d.Set("subnet_id", subnetId)
+			d.Set("route_table_id", routeTableId)
+			return []*schema.ResourceData{d}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Subnet %s is not associated with route table %s", subnetId, routeTableId)
+}
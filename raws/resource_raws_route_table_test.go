@@ -0,0 +1,448 @@
+package raws
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccRouteTable_basic(t *testing.T) {
+	var v ec2.RouteTable
+
+	testCheck := func(cidr string) resource.TestCheckFunc {
+		return func(*terraform.State) error {
+			routes := make(map[string]ec2.Route)
+			for _, r := range v.Routes {
+				routes[*r.DestinationCIDRBlock] = r
+			}
+			if _, ok := routes["10.1.0.0/16"]; !ok {
+				return fmt.Errorf("bad routes: %#v", v.Routes)
+			}
+			if _, ok := routes[cidr]; !ok {
+				return fmt.Errorf("missing route %s: %#v", cidr, v.Routes)
+			}
+			if len(routes) != 2 {
+				return fmt.Errorf("bad routes: %#v", v.Routes)
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckRouteTableDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccRouteTableConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckRouteTableExists("raws_route_table.foo", &v),
+					testCheck("10.2.0.0/16"),
+				),
+			},
+			resource.TestStep{
+				Config: testAccRouteTableConfigChange,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckRouteTableExists("raws_route_table.foo", &v),
+					testCheck("10.3.0.0/16"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccRouteTable_importBasic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckRouteTableDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccRouteTableConfig,
+			},
+			resource.TestStep{
+				ResourceName:      "raws_route_table.foo",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccRouteTable_importMissing(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckRouteTableDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccRouteTableConfig,
+			},
+			resource.TestStep{
+				ResourceName:  "raws_route_table.foo",
+				ImportState:   true,
+				ImportStateId: "rtb-00000000",
+				ExpectError:   regexp.MustCompile("non-existent"),
+			},
+		},
+	})
+}
+
+func TestAccRouteTableAssociation(t *testing.T) {
+	var v ec2.RouteTable
+
+	testCheck := func(*terraform.State) error {
+		for _, a := range v.Associations {
+			if a.SubnetID != nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("no subnet association: %#v", v.Associations)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckRouteTableDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccRouteTableAssociationConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckRouteTableExists("raws_route_table.foo", &v),
+					testCheck,
+				),
+			},
+			resource.TestStep{
+				Config: testAccRouteTableAssociationConfigChange,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckRouteTableExists("raws_route_table.bar", &v),
+					testCheck,
+				),
+			},
+		},
+	})
+}
+
+func TestAccRouteTableAssociation_import(t *testing.T) {
+	// The association is imported as subnet_id/route_table_id
+	importID := func(subnetID string) resource.ImportStateIdFunc {
+		return func(s *terraform.State) (string, error) {
+			rt, ok := s.RootModule().Resources["raws_route_table.foo"]
+			if !ok {
+				return "", fmt.Errorf("Not found: raws_route_table.foo")
+			}
+			if subnetID == "" {
+				subnet, ok := s.RootModule().Resources["raws_subnet.foo"]
+				if !ok {
+					return "", fmt.Errorf("Not found: raws_subnet.foo")
+				}
+				subnetID = subnet.Primary.ID
+			}
+			return subnetID + "/" + rt.Primary.ID, nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckRouteTableDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccRouteTableAssociationConfig,
+			},
+			resource.TestStep{
+				ResourceName:      "raws_route_table_association.foo",
+				ImportState:       true,
+				ImportStateIdFunc: importID(""),
+				ImportStateVerify: true,
+			},
+			resource.TestStep{
+				ResourceName:  "raws_route_table_association.foo",
+				ImportState:   true,
+				ImportStateId: "rtbassoc-00000000",
+				ExpectError:   regexp.MustCompile("expected subnet_id/route_table_id"),
+			},
+			resource.TestStep{
+				ResourceName:  "raws_route_table_association.foo",
+				ImportState:   true,
+				ImportStateId: "subnet-00000000/rtb-00000000",
+				ExpectError:   regexp.MustCompile("Route table rtb-00000000 not found"),
+			},
+			resource.TestStep{
+				ResourceName:      "raws_route_table_association.foo",
+				ImportState:       true,
+				ImportStateIdFunc: importID("subnet-00000000"),
+				ExpectError:       regexp.MustCompile("Subnet subnet-00000000 is not associated with route table"),
+			},
+		},
+	})
+}
+
+func testAccCheckRouteTableDestroy(s *terraform.State) error {
+	conn := testAccProvider.Meta().(*AWSClient).codaConn
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "raws_route_table" {
+			continue
+		}
+
+		// Try to find the resource
+		resp, err := conn.DescribeRouteTables(&ec2.DescribeRouteTablesRequest{
+			RouteTableIDs: []string{rs.Primary.ID},
+		})
+		if err == nil {
+			if len(resp.RouteTables) > 0 {
+				return fmt.Errorf("still exist.")
+			}
+
+			return nil
+		}
+
+		// Verify the error is what we want
+		ec2err, ok := err.(*codaws.APIError)
+		if !ok {
+			return err
+		}
+		if ec2err.Code != "InvalidRouteTableID.NotFound" {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func testAccCheckRouteTableExists(n string, v *ec2.RouteTable) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+
+		if rs.Primary.ID == "" {
+			return fmt.Errorf("No ID is set")
+		}
+
+		conn := testAccProvider.Meta().(*AWSClient).codaConn
+		resp, err := conn.DescribeRouteTables(&ec2.DescribeRouteTablesRequest{
+			RouteTableIDs: []string{rs.Primary.ID},
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.RouteTables) == 0 {
+			return fmt.Errorf("RouteTable not found")
+		}
+
+		*v = resp.RouteTables[0]
+
+		return nil
+	}
+}
+
+const testAccRouteTableConfig = `
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+}
+
+resource "raws_internet_gateway" "foo" {
+	vpc_id = "${raws_vpc.foo.id}"
+}
+
+resource "raws_route_table" "foo" {
+	vpc_id = "${raws_vpc.foo.id}"
+
+	route {
+		cidr_block = "10.2.0.0/16"
+		gateway_id = "${raws_internet_gateway.foo.id}"
+	}
+}
+`
+
+const testAccRouteTableConfigChange = `
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+}
+
+resource "raws_internet_gateway" "foo" {
+	vpc_id = "${raws_vpc.foo.id}"
+}
+
+resource "raws_route_table" "foo" {
+	vpc_id = "${raws_vpc.foo.id}"
+
+	route {
+		cidr_block = "10.3.0.0/16"
+		gateway_id = "${raws_internet_gateway.foo.id}"
+	}
+}
+`
+
+const testAccRouteTableAssociationConfig = `
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+}
+
+resource "raws_subnet" "foo" {
+	vpc_id = "${raws_vpc.foo.id}"
+	cidr_block = "10.1.1.0/24"
+}
+
+resource "raws_route_table" "foo" {
+	vpc_id = "${raws_vpc.foo.id}"
+}
+
+resource "raws_route_table_association" "foo" {
+	route_table_id = "${raws_route_table.foo.id}"
+	subnet_id = "${raws_subnet.foo.id}"
+}
+`
+
+const testAccRouteTableAssociationConfigChange = `
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+}
+
+resource "raws_subnet" "foo" {
+	vpc_id = "${raws_vpc.foo.id}"
+	cidr_block = "10.1.1.0/24"
+}
+
+resource "raws_route_table" "bar" {
+	vpc_id = "${raws_vpc.foo.id}"
+}
+
+resource "raws_route_table_association" "foo" {
+	route_table_id = "${raws_route_table.bar.id}"
+	subnet_id = "${raws_subnet.foo.id}"
+}
+`
+
+func testRouteSet(routes ...[2]string) *schema.Set {
+	set := &schema.Set{F: resourceAwsRouteTableHash}
+	for _, r := range routes {
+		m := map[string]interface{}{
+			"cidr_block":      r[0],
+			"ipv6_cidr_block": "",
+			"gateway_id":      r[1],
+			"instance_id":     "",
+		}
+		if strings.Contains(r[0], ":") {
+			m["cidr_block"], m["ipv6_cidr_block"] = "", r[0]
+		}
+		set.Add(m)
+	}
+	return set
+}
+
+func TestResourceAwsRouteTableHash(t *testing.T) {
+	// Routes to IPv4 destinations hash as they did before ipv6_cidr_block
+	ipv4 := map[string]interface{}{"cidr_block": "0.0.0.0/0", "gateway_id": "igw-1", "instance_id": ""}
+	if resourceAwsRouteTableHash(ipv4) != hashcode.String("0.0.0.0/0-igw-1--") {
+		t.Fatal("the hash of an IPv4 route changed")
+	}
+	ipv4["ipv6_cidr_block"] = ""
+	if resourceAwsRouteTableHash(ipv4) != hashcode.String("0.0.0.0/0-igw-1--") {
+		t.Fatal("an empty ipv6_cidr_block changed the hash of an IPv4 route")
+	}
+
+	ipv6 := map[string]interface{}{"ipv6_cidr_block": "::/0", "gateway_id": "igw-1", "instance_id": ""}
+	if resourceAwsRouteTableHash(ipv6) == resourceAwsRouteTableHash(ipv4) {
+		t.Fatal("expected IPv4 and IPv6 routes to hash differently")
+	}
+	ipv6["cidr_block"] = ""
+	ipv6Other := map[string]interface{}{"ipv6_cidr_block": "2600:1f14::/56", "cidr_block": "", "gateway_id": "igw-1", "instance_id": ""}
+	if resourceAwsRouteTableHash(ipv6) == resourceAwsRouteTableHash(ipv6Other) {
+		t.Fatal("expected different IPv6 destinations to hash differently")
+	}
+}
+
+func TestUpdateRouteTableRoutes(t *testing.T) {
+	cases := []struct {
+		Old, New *schema.Set
+		Calls    []string
+	}{
+		// New route
+		{
+			testRouteSet(),
+			testRouteSet([2]string{"0.0.0.0/0", "igw-1"}),
+			[]string{"CreateRoute 0.0.0.0/0 igw-1"},
+		},
+		// A route moving to another gateway is deleted first, or the
+		// create would fail with RouteAlreadyExists
+		{
+			testRouteSet([2]string{"0.0.0.0/0", "igw-1"}, [2]string{"10.2.0.0/16", "igw-1"}),
+			testRouteSet([2]string{"0.0.0.0/0", "igw-2"}, [2]string{"10.2.0.0/16", "igw-1"}),
+			[]string{"DeleteRoute 0.0.0.0/0", "CreateRoute 0.0.0.0/0 igw-2"},
+		},
+		// Removed route
+		{
+			testRouteSet([2]string{"0.0.0.0/0", "igw-1"}),
+			testRouteSet(),
+			[]string{"DeleteRoute 0.0.0.0/0"},
+		},
+		// Default routes for both address families
+		{
+			testRouteSet([2]string{"0.0.0.0/0", "igw-1"}),
+			testRouteSet([2]string{"0.0.0.0/0", "igw-1"}, [2]string{"::/0", "igw-1"}),
+			[]string{"CreateRoute ::/0 igw-1"},
+		},
+		{
+			testRouteSet([2]string{"0.0.0.0/0", "igw-1"}, [2]string{"::/0", "igw-1"}),
+			testRouteSet([2]string{"0.0.0.0/0", "igw-1"}),
+			[]string{"DeleteRoute ::/0"},
+		},
+	}
+
+	for i, tc := range cases {
+		conn := &mockEC2{}
+		var routes *schema.Set
+		err := updateRouteTableRoutes(conn, "rtb-1", tc.Old, tc.New, func(s *schema.Set) { routes = s })
+		if err != nil {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+		if !reflect.DeepEqual(conn.calls, tc.Calls) {
+			t.Fatalf("%d: bad calls:\n%#v\nexpected:\n%#v", i, conn.calls, tc.Calls)
+		}
+		if routes.Len() != tc.New.Len() {
+			t.Fatalf("%d: expected %d routes in state, got %d", i, tc.New.Len(), routes.Len())
+		}
+	}
+}
+
+func TestUpdateRouteTableRoutes_destination(t *testing.T) {
+	routes := &schema.Set{F: resourceAwsRouteTableHash}
+	routes.Add(map[string]interface{}{"cidr_block": "0.0.0.0/0", "ipv6_cidr_block": "::/0", "gateway_id": "igw-1", "instance_id": ""})
+	err := updateRouteTableRoutes(&mockEC2{}, "rtb-1", testRouteSet(), routes, func(*schema.Set) {})
+	if err == nil || !strings.Contains(err.Error(), "exactly one of cidr_block and ipv6_cidr_block") {
+		t.Fatalf("expected a destination error, got: %v", err)
+	}
+}
+
+func TestUpdateRouteTableRoutes_partial(t *testing.T) {
+	conn := &mockEC2{errs: map[string]error{
+		"CreateRoute 0.0.0.0/0": &codaws.APIError{Code: "InvalidGatewayID.NotFound"},
+	}}
+	var routes *schema.Set
+	err := updateRouteTableRoutes(conn, "rtb-1",
+		testRouteSet([2]string{"0.0.0.0/0", "igw-1"}, [2]string{"10.2.0.0/16", "igw-1"}),
+		testRouteSet([2]string{"0.0.0.0/0", "igw-2"}, [2]string{"10.2.0.0/16", "igw-1"}),
+		func(s *schema.Set) { routes = s })
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	// The old route is gone and the new one failed: only the untouched
+	// route is left in state.
+	if routes.Len() != 1 || routes.List()[0].(map[string]interface{})["cidr_block"] != "10.2.0.0/16" {
+		t.Fatalf("bad routes: %#v", routes.List())
+	}
+}
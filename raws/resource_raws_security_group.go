@@ -20,6 +20,10 @@ func resourceRawsSecurityGroup() *schema.Resource {
 		Read:   resourceRawsSecurityGroupRead,
 		Update: resourceRawsSecurityGroupUpdate,
 		Delete: resourceRawsSecurityGroupDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+		CustomizeDiff: customizeDiffTags,
 
 		Schema: map[string]*schema.Schema{
 			"name": &schema.Schema{
@@ -87,6 +91,9 @@ func resourceRawsSecurityGroup() *schema.Resource {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+
+			"tags":     tagsSchema(),
+			"tags_all": tagsAllSchema(),
 		},
 	}
 }
@@ -106,7 +113,7 @@ func resourceRawsSecurityGroupCreate(d *schema.ResourceData, meta interface{}) e
 		VPCID:       &VpcId,
 		Description: &SgDescription,
 	}
-	log.Printf("[DEBUG] Security Group create configuration: %#v", CreateSgOpts)
+	log.Printf("[DEBUG] Creating security group %q in VPC %s", SgName, VpcId)
 	resp, err := ec2conn.CreateSecurityGroup(CreateSgOpts)
 	if err != nil {
 		return fmt.Errorf("Error creating Security Group: %s", err)
@@ -116,7 +123,7 @@ func resourceRawsSecurityGroupCreate(d *schema.ResourceData, meta interface{}) e
 	log.Printf("[DEBUG] Waiting for Security Group (%s) to exist", d.Id())
 	stateConf := &resource.StateChangeConf{
 		Pending: []string{""},
-		Target:  "exists",
+		Target:  []string{"exists"},
 		Refresh: SGStateRefreshFunc(ec2conn, d.Id()),
 		Timeout: 1 * time.Minute,
 	}
@@ -139,58 +146,12 @@ func resourceRawsSecurityGroupRead(d *schema.ResourceData, meta interface{}) err
 		return nil
 	}
 	sg := sgRaw.(*ec2.SecurityGroup)
-	ingressMap := make(map[string]map[string]interface{})
-	for _, perm := range sg.IPPermissions {
-		k := fmt.Sprintf("%s-%d-%d", perm.IPProtocol, perm.FromPort, perm.ToPort)
-		m, ok := ingressMap[k]
-		if !ok {
-			m = make(map[string]interface{})
-			ingressMap[k] = m
-		}
-		m["from_port"] = perm.FromPort
-		m["to_port"] = perm.ToPort
-		m["protocol"] = perm.IPProtocol
-		if len(perm.IPRanges) > 0 {
-			raw, ok := m["cidr_blocks"]
-			if !ok {
-				raw = make([]string, 0, len(perm.IPRanges))
-			}
-			list := raw.([]ec2.IPRange)
-			list = append(list, perm.IPRanges...)
-			m["cidr_blocks"] = list
-		}
-
-		var groups []string
-		if len(perm.UserIDGroupPairs) > 0 {
-			groups = flattenSecurityGroups(perm.UserIDGroupPairs)
-		}
-		for i, id := range groups {
-			if id == d.Id() {
-				groups[i], groups = groups[len(groups)-1], groups[:len(groups)-1]
-				m["self"] = true
-			}
-		}
-		if len(groups) > 0 {
-			raw, ok := m["security_groups"]
-			if !ok {
-				raw = make([]string, 0, len(groups))
-			}
-			list := raw.([]string)
-
-			list = append(list, groups...)
-			m["security_groups"] = list
-		}
-	}
-	ingressRules := make([]map[string]interface{}, 0, len(ingressMap))
-	for _, m := range ingressMap {
-		ingressRules = append(ingressRules, m)
-	}
-
 	d.Set("description", sg.Description)
 	d.Set("name", sg.GroupName)
 	d.Set("vpc_id", sg.VPCID)
 	d.Set("owner_id", sg.OwnerID)
-	d.Set("ingress", ingressRules)
+	d.Set("ingress", flattenIPPerms(d.Id(), sg.IPPermissions))
+	readTags(d, meta, sg.Tags)
 
 	return nil
 }
@@ -215,34 +176,51 @@ func resourceRawsSecurityGroupUpdate(d *schema.ResourceData, meta interface{}) e
 			n = new(schema.Set)
 		}
 
-		os := o.(*schema.Set)
-		ns := n.(*schema.Set)
+		err := updateSecurityGroupIngress(ec2conn, d.Id(), o.(*schema.Set), n.(*schema.Set))
+		if err != nil {
+			return err
+		}
+	}
+	if err := setTags(ec2conn, d, meta); err != nil {
+		return err
+	}
+	return resourceRawsSecurityGroupRead(d, meta)
+}
 
-		remove := expandIPPerms(d.Id(), os.Difference(ns).List())
-		add := expandIPPerms(d.Id(), ns.Difference(os).List())
+// updateSecurityGroupIngress revokes the rules in o that are not in n, then
+// authorizes the rules in n that are not in o.
+func updateSecurityGroupIngress(conn EC2API, id string, o, n *schema.Set) error {
+	remove := expandIPPerms(id, o.Difference(n).List())
+	add := expandIPPerms(id, n.Difference(o).List())
 
-		if len(remove) > 0 {
-			RevokeSgOpts := &ec2.RevokeSecurityGroupIngressRequest{}
-			err := ec2conn.RevokeSecurityGroupIngress(RevokeSgOpts)
-			if err != nil {
-				return fmt.Errorf("Error authorizing security group ingress rules: %s", err)
-			}
+	if len(remove) > 0 {
+		RevokeSgOpts := &ec2.RevokeSecurityGroupIngressRequest{
+			GroupID:       &id,
+			IPPermissions: remove,
 		}
-		if len(add) > 0 {
-			AddSgOpts := &ec2.AuthorizeSecurityGroupIngressRequest{}
-			err := ec2conn.AuthorizeSecurityGroupIngress(AddSgOpts)
-			if err != nil {
-				return fmt.Errorf("Error authorizing security group ingress rules: %s", err)
-			}
+		log.Printf("[DEBUG] Revoking %d ingress rules from security group %s", len(remove), id)
+		if err := conn.RevokeSecurityGroupIngress(RevokeSgOpts); err != nil {
+			return fmt.Errorf("Error revoking security group ingress rules: %s", err)
 		}
 	}
-	return resourceRawsSecurityGroupRead(d, meta)
+	if len(add) > 0 {
+		AddSgOpts := &ec2.AuthorizeSecurityGroupIngressRequest{
+			GroupID:       &id,
+			IPPermissions: add,
+		}
+		log.Printf("[DEBUG] Authorizing %d ingress rules on security group %s", len(add), id)
+		if err := conn.AuthorizeSecurityGroupIngress(AddSgOpts); err != nil {
+			return fmt.Errorf("Error authorizing security group ingress rules: %s", err)
+		}
+	}
+
+	return nil
 }
 
 func resourceRawsSecurityGroupDelete(d *schema.ResourceData, meta interface{}) error {
 	ec2conn := meta.(*AWSClient).codaConn
-	log.Printf("[DEBUG] Security Group destroy: %v", d.Id())
-	return resource.Retry(5*time.Minute, func() error {
+	log.Printf("[DEBUG] Security Group destroy: %s", d.Id())
+	return resource.Retry(5*time.Minute, func() *resource.RetryError {
 		SgId := d.Id()
 		DelSgOpts := &ec2.DeleteSecurityGroupRequest{
 			GroupID: &SgId,
@@ -251,15 +229,15 @@ func resourceRawsSecurityGroupDelete(d *schema.ResourceData, meta interface{}) e
 		if err != nil {
 			ec2err, ok := err.(*codaws.APIError)
 			if !ok {
-				return err
+				return resource.RetryableError(err)
 			}
 			switch ec2err.Code {
 			case "InvalidGroup.NotFound":
 				return nil
 			case "DependencyViolation":
-				return err
+				return resource.RetryableError(err)
 			default:
-				return resource.RetryError{err}
+				return resource.NonRetryableError(err)
 			}
 		}
 		return nil
@@ -300,7 +278,7 @@ func resourceAwsSecurityGroupIngressHash(v interface{}) int {
 	return hashcode.String(buf.String())
 }
 
-func SGStateRefreshFunc(conn *ec2.EC2, id string) resource.StateRefreshFunc {
+func SGStateRefreshFunc(conn EC2API, id string) resource.StateRefreshFunc {
 	return func() (interface{}, string, error) {
 		DescribeSgOpts := &ec2.DescribeSecurityGroupsRequest{
 			GroupIDs: []string{id},
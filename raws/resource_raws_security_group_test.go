@@ -0,0 +1,287 @@
+package raws
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccSecurityGroup_basic(t *testing.T) {
+	var group ec2.SecurityGroup
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSecurityGroupDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccSecurityGroupConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSecurityGroupExists("raws_security_group.web", &group),
+					testAccCheckSecurityGroupIngress(&group, 80),
+					resource.TestCheckResourceAttr(
+						"raws_security_group.web", "name", "terraform_acceptance_test_example"),
+				),
+			},
+			resource.TestStep{
+				Config: testAccSecurityGroupConfigChange,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSecurityGroupExists("raws_security_group.web", &group),
+					testAccCheckSecurityGroupIngress(&group, 8000),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckSecurityGroupDestroy(s *terraform.State) error {
+	conn := testAccProvider.Meta().(*AWSClient).codaConn
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "raws_security_group" {
+			continue
+		}
+
+		// Try to find the resource
+		resp, err := conn.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsRequest{
+			GroupIDs: []string{rs.Primary.ID},
+		})
+		if err == nil {
+			if len(resp.SecurityGroups) > 0 {
+				return fmt.Errorf("Security Group (%s) still exists.", rs.Primary.ID)
+			}
+
+			return nil
+		}
+
+		// Verify the error is what we want
+		ec2err, ok := err.(*codaws.APIError)
+		if !ok {
+			return err
+		}
+		if ec2err.Code != "InvalidGroup.NotFound" {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func testAccCheckSecurityGroupExists(n string, group *ec2.SecurityGroup) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+
+		if rs.Primary.ID == "" {
+			return fmt.Errorf("No Security Group is set")
+		}
+
+		conn := testAccProvider.Meta().(*AWSClient).codaConn
+		resp, err := conn.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsRequest{
+			GroupIDs: []string{rs.Primary.ID},
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.SecurityGroups) == 0 {
+			return fmt.Errorf("Security Group not found")
+		}
+
+		*group = resp.SecurityGroups[0]
+
+		return nil
+	}
+}
+
+// testAccCheckSecurityGroupIngress checks that the group has exactly one
+// ingress rule, for TCP on port from 10.0.0.0/8.
+func testAccCheckSecurityGroupIngress(group *ec2.SecurityGroup, port int) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		if len(group.IPPermissions) != 1 {
+			return fmt.Errorf("Bad ingress: %d rules", len(group.IPPermissions))
+		}
+		p := group.IPPermissions[0]
+		if *p.IPProtocol != "tcp" || *p.FromPort != port || *p.ToPort != port {
+			return fmt.Errorf("Bad ingress: %s %d-%d", *p.IPProtocol, *p.FromPort, *p.ToPort)
+		}
+		if len(p.IPRanges) != 1 || *p.IPRanges[0].CIDRIP != "10.0.0.0/8" {
+			return fmt.Errorf("Bad ingress ranges: %d", len(p.IPRanges))
+		}
+
+		return nil
+	}
+}
+
+func TestAccSecurityGroup_importBasic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSecurityGroupDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccSecurityGroupConfig,
+			},
+			resource.TestStep{
+				ResourceName:      "raws_security_group.web",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+const testAccSecurityGroupConfig = `
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+}
+
+resource "raws_security_group" "web" {
+	name = "terraform_acceptance_test_example"
+	description = "Used in the terraform acceptance tests"
+	vpc_id = "${raws_vpc.foo.id}"
+
+	ingress {
+		protocol = "tcp"
+		from_port = 80
+		to_port = 80
+		cidr_blocks = ["10.0.0.0/8"]
+	}
+}
+`
+
+const testAccSecurityGroupConfigChange = `
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+}
+
+resource "raws_security_group" "web" {
+	name = "terraform_acceptance_test_example"
+	description = "Used in the terraform acceptance tests"
+	vpc_id = "${raws_vpc.foo.id}"
+
+	ingress {
+		protocol = "tcp"
+		from_port = 8000
+		to_port = 8000
+		cidr_blocks = ["10.0.0.0/8"]
+	}
+}
+`
+
+func testSecurityGroupIngressSet(rules ...map[string]interface{}) *schema.Set {
+	set := &schema.Set{F: resourceAwsSecurityGroupIngressHash}
+	for _, r := range rules {
+		m := map[string]interface{}{
+			"cidr_blocks": []interface{}{},
+			"security_groups": &schema.Set{F: func(v interface{}) int {
+				return hashcode.String(v.(string))
+			}},
+			"self": false,
+		}
+		for k, v := range r {
+			m[k] = v
+		}
+		set.Add(m)
+	}
+	return set
+}
+
+func TestUpdateSecurityGroupIngress(t *testing.T) {
+	http := map[string]interface{}{
+		"protocol": "tcp", "from_port": 80, "to_port": 80,
+		"cidr_blocks": []interface{}{"10.0.0.0/8"},
+	}
+	https := map[string]interface{}{
+		"protocol": "tcp", "from_port": 443, "to_port": 443,
+		"cidr_blocks": []interface{}{"10.0.0.0/8", "192.168.0.0/16"},
+	}
+	self := map[string]interface{}{
+		"protocol": "-1", "from_port": 0, "to_port": 0, "self": true,
+	}
+
+	cases := []struct {
+		Old, New *schema.Set
+		Calls    []string
+	}{
+		// Nothing changed
+		{
+			testSecurityGroupIngressSet(http),
+			testSecurityGroupIngressSet(http),
+			nil,
+		},
+		// Added rules are authorized, unchanged rules are left alone
+		{
+			testSecurityGroupIngressSet(http),
+			testSecurityGroupIngressSet(http, https, self),
+			[]string{
+				"AuthorizeSecurityGroupIngress sg-1 -1:0-0:sg-1,tcp:443-443:10.0.0.0/8,tcp:443-443:192.168.0.0/16",
+			},
+		},
+		// Changed rules are revoked before the new ones are authorized
+		{
+			testSecurityGroupIngressSet(http, https),
+			testSecurityGroupIngressSet(https, self),
+			[]string{
+				"RevokeSecurityGroupIngress sg-1 tcp:80-80:10.0.0.0/8",
+				"AuthorizeSecurityGroupIngress sg-1 -1:0-0:sg-1",
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		conn := &mockEC2{}
+		if err := updateSecurityGroupIngress(conn, "sg-1", tc.Old, tc.New); err != nil {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+		if !reflect.DeepEqual(conn.calls, tc.Calls) {
+			t.Fatalf("%d: bad calls:\n%#v\nexpected:\n%#v", i, conn.calls, tc.Calls)
+		}
+	}
+}
+
+func TestUpdateSecurityGroupIngress_authorizeError(t *testing.T) {
+	rule := map[string]interface{}{
+		"protocol": "tcp", "from_port": 22, "to_port": 22,
+		"cidr_blocks": []interface{}{"0.0.0.0/0"},
+	}
+	conn := &mockEC2{errs: map[string]error{
+		"AuthorizeSecurityGroupIngress": &codaws.APIError{Code: "InvalidPermission.Duplicate"},
+	}}
+
+	err := updateSecurityGroupIngress(conn, "sg-1", testSecurityGroupIngressSet(), testSecurityGroupIngressSet(rule))
+	if err == nil || !strings.Contains(err.Error(), "authorizing") {
+		t.Fatalf("expected an authorize error, got %v", err)
+	}
+	expected := []string{"AuthorizeSecurityGroupIngress sg-1 tcp:22-22:0.0.0.0/0"}
+	if !reflect.DeepEqual(conn.calls, expected) {
+		t.Fatalf("bad calls: %#v", conn.calls)
+	}
+}
+
+func TestUpdateSecurityGroupIngress_revokeError(t *testing.T) {
+	rule := map[string]interface{}{
+		"protocol": "tcp", "from_port": 22, "to_port": 22,
+		"cidr_blocks": []interface{}{"0.0.0.0/0"},
+	}
+	conn := &mockEC2{errs: map[string]error{
+		"RevokeSecurityGroupIngress": &codaws.APIError{Code: "InvalidPermission.NotFound"},
+	}}
+
+	err := updateSecurityGroupIngress(conn, "sg-1", testSecurityGroupIngressSet(rule), testSecurityGroupIngressSet())
+	if err == nil || !strings.Contains(err.Error(), "revoking") {
+		t.Fatalf("expected a revoke error, got %v", err)
+	}
+	if len(conn.calls) != 1 {
+		t.Fatalf("expected nothing to be authorized after a failed revoke: %#v", conn.calls)
+	}
+}
@@ -16,6 +16,10 @@ func resourceRawsSubnet() *schema.Resource {
 		Read:   resourceRawsSubnetRead,
 		Update: resourceRawsSubnetUpdate,
 		Delete: resourceRawsSubnetDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+		CustomizeDiff: customizeDiffTags,
 
 		Schema: map[string]*schema.Schema{
 			"vpc_id": &schema.Schema{
@@ -42,6 +46,25 @@ func resourceRawsSubnet() *schema.Resource {
 				Type:     schema.TypeBool,
 				Optional: true,
 			},
+
+			"ipv6_cidr_block": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"ipv6_cidr_block_association_id": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"assign_ipv6_address_on_creation": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"tags":     tagsSchema(),
+			"tags_all": tagsAllSchema(),
 		},
 	}
 }
@@ -66,7 +89,7 @@ func resourceRawsSubnetCreate(d *schema.ResourceData, meta interface{}) error {
 	log.Printf("[DEBUG] Waiting for subnet (%s) to become available", d.Id())
 	stateConf := &resource.StateChangeConf{
 		Pending: []string{"pending"},
-		Target:  "available",
+		Target:  []string{"available"},
 		Refresh: SubnetStateRefreshFunc(ec2conn, d.Id()),
 		Timeout: 10 * time.Minute,
 	}
@@ -84,41 +107,170 @@ func resourceRawsSubnetRead(d *schema.ResourceData, meta interface{}) error {
 		return err
 	}
 	if subnetRaw == nil {
+		log.Printf("[WARN] Subnet (%s) not found, removing from state", d.Id())
+		d.SetId("")
 		return nil
 	}
 	subnet := subnetRaw.(*ec2.Subnet)
 	d.Set("availability_zone", subnet.AvailabilityZone)
 	d.Set("vpc_id", subnet.VPCID)
 	d.Set("cidr_block", subnet.CIDRBlock)
+	d.Set("map_public_ip_on_launch", subnet.MapPublicIPOnLaunch)
+	readTags(d, meta, subnet.Tags)
+
+	// The generated client predates IPv6
+	subnetId := d.Id()
+	resp, err := ec2conn.DescribeSubnetIPv6(&describeSubnetIPv6Request{
+		SubnetIDs: []string{subnetId},
+	})
+	if err != nil {
+		return fmt.Errorf("Error reading the IPv6 configuration of subnet %s: %s", d.Id(), err)
+	}
+	d.Set("ipv6_cidr_block", "")
+	d.Set("ipv6_cidr_block_association_id", "")
+	d.Set("assign_ipv6_address_on_creation", false)
+	if len(resp.Subnets) > 0 {
+		ipv6 := resp.Subnets[0]
+		for _, a := range ipv6.IPv6CIDRBlockAssociations {
+			if a.State != nil && *a.State == "associated" {
+				d.Set("ipv6_cidr_block", a.IPv6CIDRBlock)
+				d.Set("ipv6_cidr_block_association_id", a.AssociationID)
+			}
+		}
+		if ipv6.AssignIPv6AddressOnCreation != nil {
+			d.Set("assign_ipv6_address_on_creation", *ipv6.AssignIPv6AddressOnCreation)
+		}
+	}
 	return nil
 }
 
 func resourceRawsSubnetUpdate(d *schema.ResourceData, meta interface{}) error {
+	return updateSubnet(d, meta, d.HasChange)
+}
+
+// updateSubnet sets the attributes changed reports to their configured
+// values, then the tags.
+func updateSubnet(d *schema.ResourceData, meta interface{}, changed func(string) bool) error {
 	ec2conn := meta.(*AWSClient).codaConn
 	d.Partial(true)
-	modify := false
 	subnetId := d.Id()
-	if d.HasChange("map_public_ip_on_launch") {
-		ModSubnetAttrOpts := &ec2.ModifySubnetAttributeRequest{
-			SubnetID: &subnetId,
+	if changed("map_public_ip_on_launch") {
+		val := d.Get("map_public_ip_on_launch").(bool)
+		if err := modifySubnetMapPublicIP(ec2conn, subnetId, val); err != nil {
+			return err
 		}
-		if v, ok := d.GetOk("source_dest_check"); ok {
-			val := v.(bool)
-			ModSubnetAttrOpts.MapPublicIPOnLaunch = &ec2.AttributeBooleanValue{
-				Value: &val,
-			}
-			modify = true
+		d.SetPartial("map_public_ip_on_launch")
+	}
+
+	// Addresses can only be assigned from an IPv6 block the subnet has, so
+	// assigning is turned off before the block is removed, and on after
+	// it's added.
+	assignIPv6 := d.Get("assign_ipv6_address_on_creation").(bool)
+	if changed("assign_ipv6_address_on_creation") && !assignIPv6 {
+		if err := modifySubnetAssignIPv6(ec2conn, subnetId, false); err != nil {
+			return err
 		}
-		if modify {
-			log.Printf("[INFO] Modifing instance %s: %#v", d.Id(), ModSubnetAttrOpts)
-			if err := ec2conn.ModifySubnetAttribute(ModSubnetAttrOpts); err != nil {
+	}
+	if changed("ipv6_cidr_block") {
+		if err := updateSubnetIPv6CIDRBlock(ec2conn, d); err != nil {
+			return err
+		}
+		d.SetPartial("ipv6_cidr_block")
+	}
+	if changed("assign_ipv6_address_on_creation") {
+		if assignIPv6 {
+			if err := modifySubnetAssignIPv6(ec2conn, subnetId, true); err != nil {
 				return err
 			}
 		}
+		d.SetPartial("assign_ipv6_address_on_creation")
+	}
+
+	if err := setTags(ec2conn, d, meta); err != nil {
+		return err
+	}
+	d.SetPartial("tags")
+	d.Partial(false)
+	return resourceRawsSubnetRead(d, meta)
+}
+
+func modifySubnetMapPublicIP(conn EC2API, subnetId string, val bool) error {
+	log.Printf("[INFO] Modifying map_public_ip_on_launch for subnet %s: %t", subnetId, val)
+	err := conn.ModifySubnetAttribute(&ec2.ModifySubnetAttributeRequest{
+		SubnetID: &subnetId,
+		MapPublicIPOnLaunch: &ec2.AttributeBooleanValue{
+			Value: &val,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Error modifying map_public_ip_on_launch for subnet %s: %s", subnetId, err)
 	}
 	return nil
 }
 
+func modifySubnetAssignIPv6(conn EC2API, subnetId string, val bool) error {
+	log.Printf("[INFO] Modifying assign_ipv6_address_on_creation for subnet %s: %t", subnetId, val)
+	err := conn.ModifySubnetIPv6Attribute(&modifySubnetIPv6AttributeRequest{
+		SubnetID: &subnetId,
+		AssignIPv6AddressOnCreation: &ec2.AttributeBooleanValue{
+			Value: &val,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Error modifying assign_ipv6_address_on_creation for subnet %s: %s", subnetId, err)
+	}
+	return nil
+}
+
+// updateSubnetIPv6CIDRBlock removes the subnet's old IPv6 CIDR block and
+// associates the new one, waiting for each to finish.
+func updateSubnetIPv6CIDRBlock(conn EC2API, d *schema.ResourceData) error {
+	subnetId := d.Id()
+	if associationId := d.Get("ipv6_cidr_block_association_id").(string); associationId != "" {
+		log.Printf("[INFO] Removing IPv6 CIDR block association %s from subnet %s", associationId, subnetId)
+		if _, err := conn.DisassociateSubnetCIDRBlock(&disassociateSubnetCIDRBlockRequest{
+			AssociationID: &associationId,
+		}); err != nil {
+			return fmt.Errorf("Error removing the IPv6 CIDR block of subnet %s: %s", subnetId, err)
+		}
+		stateConf := &resource.StateChangeConf{
+			Pending: []string{"associated", "disassociating"},
+			Target:  []string{"disassociated"},
+			Refresh: goneIsDisassociated(SubnetIPv6CIDRBlockAssociationStateRefreshFunc(conn, subnetId, associationId), associationId),
+			Timeout: 3 * time.Minute,
+		}
+		if _, err := stateConf.WaitForState(); err != nil {
+			return fmt.Errorf("Error waiting for IPv6 CIDR block association (%s) to become disassociated: %s", associationId, err)
+		}
+		d.Set("ipv6_cidr_block_association_id", "")
+	}
+
+	cidr := d.Get("ipv6_cidr_block").(string)
+	if cidr == "" {
+		return nil
+	}
+	log.Printf("[INFO] Associating IPv6 CIDR block %s with subnet %s", cidr, subnetId)
+	resp, err := conn.AssociateSubnetCIDRBlock(&associateSubnetCIDRBlockRequest{
+		IPv6CIDRBlock: &cidr,
+		SubnetID:      &subnetId,
+	})
+	if err != nil {
+		return fmt.Errorf("Error associating IPv6 CIDR block %s with subnet %s: %s", cidr, subnetId, err)
+	}
+	associationId := *resp.IPv6CIDRBlockAssociation.AssociationID
+	stateConf := &resource.StateChangeConf{
+		Pending: []string{"associating"},
+		Target:  []string{"associated"},
+		Refresh: SubnetIPv6CIDRBlockAssociationStateRefreshFunc(conn, subnetId, associationId),
+		Timeout: 3 * time.Minute,
+	}
+	if _, err := stateConf.WaitForState(); err != nil {
+		return fmt.Errorf("Error waiting for IPv6 CIDR block association (%s) to become associated: %s", associationId, err)
+	}
+	d.Set("ipv6_cidr_block_association_id", associationId)
+	return nil
+}
+
 func resourceRawsSubnetDelete(d *schema.ResourceData, meta interface{}) error {
 	ec2conn := meta.(*AWSClient).codaConn
 	log.Printf("[INFO] Deleting subnet: %s", d.Id())
@@ -136,7 +288,7 @@ func resourceRawsSubnetDelete(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
-func SubnetStateRefreshFunc(conn *ec2.EC2, id string) resource.StateRefreshFunc {
+func SubnetStateRefreshFunc(conn EC2API, id string) resource.StateRefreshFunc {
 	return func() (interface{}, string, error) {
 		DescribeSubnetsOpts := &ec2.DescribeSubnetsRequest{
 			SubnetIDs: []string{id},
@@ -157,3 +309,38 @@ func SubnetStateRefreshFunc(conn *ec2.EC2, id string) resource.StateRefreshFunc
 		return subnet, *subnet.State, nil
 	}
 }
+
+// SubnetIPv6CIDRBlockAssociationStateRefreshFunc returns a
+// resource.StateRefreshFunc that is used to watch the IPv6 CIDR block
+// association of a subnet.
+func SubnetIPv6CIDRBlockAssociationStateRefreshFunc(conn EC2API, subnetId, id string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		resp, err := conn.DescribeSubnetIPv6(&describeSubnetIPv6Request{
+			SubnetIDs: []string{subnetId},
+		})
+		if err != nil {
+			if ec2err, ok := err.(*codaws.APIError); ok && ec2err.Code == "InvalidSubnetID.NotFound" {
+				return nil, "", nil
+			}
+			log.Printf("[ERROR] Error on SubnetIPv6CIDRBlockAssociationStateRefresh: %s", err)
+			return nil, "", err
+		}
+
+		for _, subnet := range resp.Subnets {
+			for i, a := range subnet.IPv6CIDRBlockAssociations {
+				if a.AssociationID == nil || *a.AssociationID != id || a.State == nil {
+					continue
+				}
+				if *a.State == "failing" || *a.State == "failed" {
+					message := ""
+					if a.StatusMessage != nil {
+						message = *a.StatusMessage
+					}
+					return nil, *a.State, fmt.Errorf("IPv6 CIDR block association (%s) %s: %s", id, *a.State, message)
+				}
+				return &subnet.IPv6CIDRBlockAssociations[i], *a.State, nil
+			}
+		}
+		return nil, "", nil
+	}
+}
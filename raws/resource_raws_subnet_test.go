@@ -2,6 +2,8 @@ package raws
 
 import (
 	"fmt"
+	"reflect"
+	"regexp"
 	"testing"
 
 	codaws "github.com/awslabs/aws-sdk-go/aws"
@@ -35,7 +37,7 @@ func TestAccAWSSubnet(t *testing.T) {
 				Config: testAccSubnetConfig,
 				Check: resource.ComposeTestCheckFunc(
 					testAccCheckSubnetExists(
-						"aws_subnet.foo", &v),
+						"raws_subnet.foo", &v),
 					testCheck,
 				),
 			},
@@ -43,11 +45,84 @@ func TestAccAWSSubnet(t *testing.T) {
 	})
 }
 
+func TestAccAWSSubnet_importBasic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSubnetDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccSubnetConfig,
+			},
+			resource.TestStep{
+				ResourceName:      "raws_subnet.foo",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccAWSSubnet_importMissing(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSubnetDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccSubnetConfig,
+			},
+			resource.TestStep{
+				ResourceName:  "raws_subnet.foo",
+				ImportState:   true,
+				ImportStateId: "subnet-00000000",
+				ExpectError:   regexp.MustCompile("non-existent"),
+			},
+		},
+	})
+}
+
+func TestAccAWSSubnet_ipv6(t *testing.T) {
+	var v ec2.Subnet
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSubnetDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccSubnetConfigIpv6,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSubnetExists("raws_subnet.foo", &v),
+					testAccCheckSubnetIpv6("raws_subnet.foo", true),
+					resource.TestCheckResourceAttr(
+						"raws_vpc.foo", "assign_generated_ipv6_cidr_block", "true"),
+					resource.TestCheckResourceAttr(
+						"raws_subnet.foo", "assign_ipv6_address_on_creation", "true"),
+					resource.TestCheckResourceAttr(
+						"raws_route_table.foo", "route.#", "2"),
+				),
+			},
+			resource.TestStep{
+				// The VPC keeps its block: it can't be disassociated
+				// while a subnet still has one, and the VPC is updated
+				// before its subnets.
+				Config: testAccSubnetConfigIpv6Removed,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSubnetIpv6("raws_subnet.foo", false),
+					resource.TestCheckResourceAttr(
+						"raws_subnet.foo", "assign_ipv6_address_on_creation", "false"),
+				),
+			},
+		},
+	})
+}
+
 func testAccCheckSubnetDestroy(s *terraform.State) error {
 	conn := testAccProvider.Meta().(*AWSClient).codaConn
 
 	for _, rs := range s.RootModule().Resources {
-		if rs.Type != "aws_subnet" {
+		if rs.Type != "raws_subnet" {
 			continue
 		}
 
@@ -106,14 +181,116 @@ func testAccCheckSubnetExists(n string, v *ec2.Subnet) resource.TestCheckFunc {
 	}
 }
 
+func testAccCheckSubnetIpv6(n string, associated bool) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+
+		conn := testAccProvider.Meta().(*AWSClient).codaConn
+		resp, err := conn.DescribeSubnetIPv6(&describeSubnetIPv6Request{
+			SubnetIDs: []string{rs.Primary.ID},
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.Subnets) == 0 {
+			return fmt.Errorf("Subnet not found")
+		}
+
+		var blocks []string
+		for _, a := range resp.Subnets[0].IPv6CIDRBlockAssociations {
+			if *a.State == "associated" {
+				blocks = append(blocks, *a.IPv6CIDRBlock)
+			}
+		}
+		if associated && (len(blocks) != 1 || blocks[0] != rs.Primary.Attributes["ipv6_cidr_block"]) {
+			return fmt.Errorf("bad IPv6 CIDR blocks: %v", blocks)
+		}
+		if !associated && len(blocks) != 0 {
+			return fmt.Errorf("IPv6 CIDR blocks still associated: %v", blocks)
+		}
+		return nil
+	}
+}
+
+func TestModifySubnetMapPublicIP(t *testing.T) {
+	conn := &mockEC2{}
+	if err := modifySubnetMapPublicIP(conn, "subnet-1", false); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := modifySubnetMapPublicIP(conn, "subnet-1", true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{
+		"ModifySubnetAttribute subnet-1 MapPublicIpOnLaunch=false",
+		"ModifySubnetAttribute subnet-1 MapPublicIpOnLaunch=true",
+	}
+	if !reflect.DeepEqual(conn.calls, expected) {
+		t.Fatalf("bad calls: %#v", conn.calls)
+	}
+
+	conn.errs = map[string]error{"ModifySubnetAttribute": &codaws.APIError{Code: "UnauthorizedOperation"}}
+	if err := modifySubnetMapPublicIP(conn, "subnet-1", true); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
 const testAccSubnetConfig = `
-resource "aws_vpc" "foo" {
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+}
+
+resource "raws_subnet" "foo" {
+	cidr_block = "10.1.1.0/24"
+	vpc_id = "${raws_vpc.foo.id}"
+	map_public_ip_on_launch = true
+}
+`
+
+const testAccSubnetConfigIpv6 = `
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+	assign_generated_ipv6_cidr_block = true
+}
+
+resource "raws_subnet" "foo" {
+	cidr_block = "10.1.1.0/24"
+	vpc_id = "${raws_vpc.foo.id}"
+	map_public_ip_on_launch = true
+	ipv6_cidr_block = "${cidrsubnet(raws_vpc.foo.ipv6_cidr_block, 8, 1)}"
+	assign_ipv6_address_on_creation = true
+}
+
+resource "raws_internet_gateway" "foo" {
+	vpc_id = "${raws_vpc.foo.id}"
+}
+
+resource "raws_route_table" "foo" {
+	vpc_id = "${raws_vpc.foo.id}"
+
+	route {
+		cidr_block = "0.0.0.0/0"
+		gateway_id = "${raws_internet_gateway.foo.id}"
+	}
+
+	route {
+		ipv6_cidr_block = "::/0"
+		gateway_id = "${raws_internet_gateway.foo.id}"
+	}
+}
+`
+
+const testAccSubnetConfigIpv6Removed = `
+resource "raws_vpc" "foo" {
 	cidr_block = "10.1.0.0/16"
+	assign_generated_ipv6_cidr_block = true
 }
 
-resource "aws_subnet" "foo" {
+resource "raws_subnet" "foo" {
 	cidr_block = "10.1.1.0/24"
-	vpc_id = "${aws_vpc.foo.id}"
+	vpc_id = "${raws_vpc.foo.id}"
 	map_public_ip_on_launch = true
 }
 `
@@ -3,12 +3,15 @@ package raws
 import (
 	"fmt"
 	"log"
+	"reflect"
+	"strings"
 	"time"
 
 	codaws "github.com/awslabs/aws-sdk-go/aws"
 	"github.com/awslabs/aws-sdk-go/gen/ec2"
 	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
 )
 
 func resourceRawsVpc() *schema.Resource {
@@ -17,6 +20,10 @@ func resourceRawsVpc() *schema.Resource {
 		Read:   resourceRawsVpcRead,
 		Update: resourceRawsVpcUpdate,
 		Delete: resourceRawsVpcDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceRawsVpcImportState,
+		},
+		CustomizeDiff: customizeDiffTags,
 
 		Schema: map[string]*schema.Schema{
 			"cidr_block": &schema.Schema{
@@ -40,6 +47,73 @@ func resourceRawsVpc() *schema.Resource {
 				Optional: true,
 				Computed: true,
 			},
+
+			"assign_generated_ipv6_cidr_block": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"ipv6_cidr_block": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"ipv6_association_id": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"main_route_table_id": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"default_security_group_id": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"default_network_acl_id": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"dhcp_options_id": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"owner_id": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"cidr_block_associations": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"association_id": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"cidr_block": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+
+			"force_destroy": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"tags":     tagsSchema(),
+			"tags_all": tagsAllSchema(),
 		},
 	}
 }
@@ -55,7 +129,7 @@ func resourceRawsVpcCreate(d *schema.ResourceData, meta interface{}) error {
 		CIDRBlock:       &cidr,
 		InstanceTenancy: &instance_tenancy,
 	}
-	log.Printf("[DEBUG] VPC create config: %#v", createOpts)
+	log.Printf("[DEBUG] Creating VPC %s with %s instance tenancy", cidr, instance_tenancy)
 	vpcResp, err := ec2conn.CreateVPC(createOpts)
 	if err != nil {
 		return fmt.Errorf("Error creating VPC: %s", err)
@@ -69,7 +143,7 @@ func resourceRawsVpcCreate(d *schema.ResourceData, meta interface{}) error {
 	log.Printf("[DEBUG] Waiting for VPC (%s) to become available", d.Id())
 	stateConf := &resource.StateChangeConf{
 		Pending: []string{"pending"},
-		Target:  "available",
+		Target:  []string{"available"},
 		Refresh: VPCStateRefreshFunc(ec2conn, d.Id()),
 		Timeout: 10 * time.Minute,
 	}
@@ -78,7 +152,11 @@ func resourceRawsVpcCreate(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	// Update our attributes and return
-	return resourceRawsVpcUpdate(d, meta)
+	remote, err := readRemote(resourceRawsVpc(), d.Id(), meta)
+	if err != nil {
+		return err
+	}
+	return updateVpc(d, meta, changedFrom(d, remote))
 }
 
 func resourceRawsVpcRead(d *schema.ResourceData, meta interface{}) error {
@@ -88,75 +166,319 @@ func resourceRawsVpcRead(d *schema.ResourceData, meta interface{}) error {
 		return err
 	}
 	if vpcRaw == nil {
+		log.Printf("[WARN] VPC (%s) not found, removing from state", d.Id())
+		d.SetId("")
 		return nil
 	}
 	vpc := vpcRaw.(*ec2.VPC)
 	vpcid := d.Id()
 	d.Set("cidr_block", vpc.CIDRBlock)
 	d.Set("instance_tenancy", vpc.InstanceTenancy)
-	createOpts := &ec2.DescribeVPCAttributeRequest{
-		VPCID: &vpcid,
+	d.Set("dhcp_options_id", vpc.DHCPOptionsID)
+	readTags(d, meta, vpc.Tags)
+
+	// The VPC attributes can only be described one at a time
+	attribute := "enableDnsSupport"
+	DescribeAttrOpts := &ec2.DescribeVPCAttributeRequest{
+		Attribute: &attribute,
+		VPCID:     &vpcid,
+	}
+	resp, err := ec2conn.DescribeVPCAttribute(DescribeAttrOpts)
+	if err != nil {
+		return err
+	}
+	if resp.EnableDNSSupport != nil && resp.EnableDNSSupport.Value != nil {
+		d.Set("enable_dns_support", *resp.EnableDNSSupport.Value)
+	}
+
+	attribute = "enableDnsHostnames"
+	resp, err = ec2conn.DescribeVPCAttribute(DescribeAttrOpts)
+	if err != nil {
+		return err
+	}
+	if resp.EnableDNSHostnames != nil && resp.EnableDNSHostnames.Value != nil {
+		d.Set("enable_dns_hostnames", *resp.EnableDNSHostnames.Value)
+	}
+
+	// The resources EC2 creates with the VPC
+	mainRouteTableID, err := vpcMainRouteTableID(ec2conn, vpcid)
+	if err != nil {
+		return err
+	}
+	d.Set("main_route_table_id", mainRouteTableID)
+
+	sg, err := vpcDefaultSecurityGroup(ec2conn, vpcid)
+	if err != nil {
+		return err
+	}
+	if sg != nil {
+		d.Set("default_security_group_id", sg.GroupID)
+		// The VPC itself doesn't say who owns it; its default group does.
+		d.Set("owner_id", sg.OwnerID)
+	}
+
+	networkACLID, err := vpcDefaultNetworkACLID(ec2conn, vpcid)
+	if err != nil {
+		return err
+	}
+	d.Set("default_network_acl_id", networkACLID)
+
+	blocks, err := describeVPCCIDRBlocks(ec2conn, vpcid)
+	if err != nil {
+		return err
+	}
+	d.Set("cidr_block_associations", flattenVPCCIDRBlockAssociations(blocks.CIDRBlockAssociations))
+	ipv6 := vpcIPv6CIDRBlockAssociation(blocks)
+	d.Set("assign_generated_ipv6_cidr_block", ipv6 != nil)
+	if ipv6 != nil {
+		d.Set("ipv6_cidr_block", ipv6.IPv6CIDRBlock)
+		d.Set("ipv6_association_id", ipv6.AssociationID)
+	} else {
+		d.Set("ipv6_cidr_block", "")
+		d.Set("ipv6_association_id", "")
+	}
+	return nil
+}
+
+// resourceRawsVpcImportState starts an imported VPC without force_destroy,
+// which is only used on delete.
+func resourceRawsVpcImportState(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	d.Set("force_destroy", false)
+	return []*schema.ResourceData{d}, nil
+}
+
+// describeVPCCIDRBlocks returns the CIDR blocks associated with a VPC.
+func describeVPCCIDRBlocks(conn EC2API, vpcID string) (*vpcCIDRBlocks, error) {
+	resp, err := conn.DescribeVPCCIDRBlocks(&describeVPCCIDRBlocksRequest{
+		VPCIDs: []string{vpcID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the CIDR blocks of VPC %s: %s", vpcID, err)
+	}
+	if len(resp.VPCs) == 0 {
+		return &vpcCIDRBlocks{}, nil
+	}
+	return &resp.VPCs[0], nil
+}
+
+// flattenVPCCIDRBlockAssociations returns the IPv4 CIDR blocks that are
+// associated, the one the VPC was created with first.
+func flattenVPCCIDRBlockAssociations(list []vpcCIDRBlockAssociation) []map[string]interface{} {
+	associations := make([]map[string]interface{}, 0, len(list))
+	for _, a := range list {
+		if a.State == nil || *a.State != "associated" {
+			continue
+		}
+		associations = append(associations, map[string]interface{}{
+			"association_id": *a.AssociationID,
+			"cidr_block":     *a.CIDRBlock,
+		})
+	}
+	return associations
+}
+
+// vpcIPv6CIDRBlockAssociation returns the IPv6 CIDR block associated with
+// a VPC, or nil if it has none.
+func vpcIPv6CIDRBlockAssociation(blocks *vpcCIDRBlocks) *ipv6CIDRBlockAssociation {
+	for i, a := range blocks.IPv6CIDRBlockAssociations {
+		if a.State != nil && *a.State == "associated" {
+			return &blocks.IPv6CIDRBlockAssociations[i]
+		}
 	}
-	resp, err := ec2conn.DescribeVPCAttribute(createOpts)
-	d.Set("enable_dns_support", resp.EnableDNSHostnames)
-	d.Set("enable_dns_hostnames", resp.EnableDNSSupport)
 	return nil
 }
 
+// vpcMainRouteTableID returns the ID of the main route table of a VPC, or
+// an empty string if it has none.
+func vpcMainRouteTableID(conn EC2API, vpcID string) (string, error) {
+	resp, err := conn.DescribeRouteTables(&ec2.DescribeRouteTablesRequest{
+		Filters: buildEC2Filters(map[string]string{
+			"vpc-id":           vpcID,
+			"association.main": "true",
+		}),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error finding the main route table of VPC %s: %s", vpcID, err)
+	}
+	if len(resp.RouteTables) == 0 {
+		return "", nil
+	}
+	return *resp.RouteTables[0].RouteTableID, nil
+}
+
+// vpcDefaultSecurityGroup returns the default security group of a VPC, or
+// nil if it has none.
+func vpcDefaultSecurityGroup(conn EC2API, vpcID string) (*ec2.SecurityGroup, error) {
+	resp, err := conn.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsRequest{
+		Filters: buildEC2Filters(map[string]string{
+			"vpc-id":     vpcID,
+			"group-name": "default",
+		}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error finding the default security group of VPC %s: %s", vpcID, err)
+	}
+	if len(resp.SecurityGroups) == 0 {
+		return nil, nil
+	}
+	return &resp.SecurityGroups[0], nil
+}
+
+// vpcDefaultNetworkACLID returns the ID of the default network ACL of a
+// VPC, or an empty string if it has none.
+func vpcDefaultNetworkACLID(conn EC2API, vpcID string) (string, error) {
+	resp, err := conn.DescribeNetworkACLs(&ec2.DescribeNetworkACLsRequest{
+		Filters: buildEC2Filters(map[string]string{
+			"vpc-id":  vpcID,
+			"default": "true",
+		}),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error finding the default network ACL of VPC %s: %s", vpcID, err)
+	}
+	if len(resp.NetworkACLs) == 0 {
+		return "", nil
+	}
+	return *resp.NetworkACLs[0].NetworkACLID, nil
+}
+
 func resourceRawsVpcUpdate(d *schema.ResourceData, meta interface{}) error {
+	return updateVpc(d, meta, d.HasChange)
+}
+
+// readRemote reads the resource with the given ID into new data for r,
+// leaving the configuration in the caller's data alone.
+func readRemote(r *schema.Resource, id string, meta interface{}) (*schema.ResourceData, error) {
+	remote := r.Data(&terraform.InstanceState{ID: id})
+	if err := r.Read(remote, meta); err != nil {
+		return nil, err
+	}
+	if remote.Id() == "" {
+		return nil, fmt.Errorf("Error reading %s: not found", id)
+	}
+	return remote, nil
+}
+
+// changedFrom reports the attributes the configuration sets to something
+// other than they are in remote. Creates, and resources found rather than
+// created, update against an empty prior state, in which setting false or
+// an empty string is no change.
+func changedFrom(d, remote *schema.ResourceData) func(string) bool {
+	return func(key string) bool {
+		v, ok := d.GetOkExists(key)
+		return ok && !reflect.DeepEqual(v, remote.Get(key))
+	}
+}
+
+// updateVpc sets the attributes changed reports to their configured
+// values, then the tags.
+func updateVpc(d *schema.ResourceData, meta interface{}, changed func(string) bool) error {
 	ec2conn := meta.(*AWSClient).codaConn
 	d.Partial(true)
-	modify := false
 	vpcid := d.Id()
-	if d.HasChange("enable_dns_hostnames") {
+	if changed("enable_dns_hostnames") {
+		val := d.Get("enable_dns_hostnames").(bool)
 		createOpts := &ec2.ModifyVPCAttributeRequest{
 			VPCID: &vpcid,
-		}
-		if v, ok := d.GetOk("enable_dns_hostnames"); ok {
-			val := v.(bool)
-			createOpts.EnableDNSHostnames = &ec2.AttributeBooleanValue{
+			EnableDNSHostnames: &ec2.AttributeBooleanValue{
 				Value: &val,
-			}
-			modify = true
+			},
 		}
-		if modify {
-			modify = false
-			log.Printf("[INFO] Modifying enable_dns_hostnames vpc attribute for %s: %#v", d.Id(), createOpts)
-			if err := ec2conn.ModifyVPCAttribute(createOpts); err != nil {
-				return err
-			} else {
-				d.SetPartial("enable_dns_hostnames")
-			}
+		log.Printf("[INFO] Modifying enable_dns_hostnames vpc attribute for %s: %t", d.Id(), val)
+		if err := ec2conn.ModifyVPCAttribute(createOpts); err != nil {
+			return err
 		}
+		d.SetPartial("enable_dns_hostnames")
 	}
-	if d.HasChange("enable_dns_support") {
+	if changed("enable_dns_support") {
+		val := d.Get("enable_dns_support").(bool)
 		createOpts := &ec2.ModifyVPCAttributeRequest{
 			VPCID: &vpcid,
-		}
-		if v, ok := d.GetOk("enable_dns_support"); ok {
-			val := v.(bool)
-			createOpts.EnableDNSSupport = &ec2.AttributeBooleanValue{
+			EnableDNSSupport: &ec2.AttributeBooleanValue{
 				Value: &val,
-			}
-			modify = true
+			},
 		}
-		if modify {
-			modify = false
-			log.Printf("[INFO] Modifying enable_dns_hostnames vpc attribute for %s: %#v", d.Id(), createOpts)
-			if err := ec2conn.ModifyVPCAttribute(createOpts); err != nil {
-				return err
-			} else {
-				d.SetPartial("enable_dns_support")
-			}
+		log.Printf("[INFO] Modifying enable_dns_support vpc attribute for %s: %t", d.Id(), val)
+		if err := ec2conn.ModifyVPCAttribute(createOpts); err != nil {
+			return err
+		}
+		d.SetPartial("enable_dns_support")
+	}
+	if changed("assign_generated_ipv6_cidr_block") {
+		if err := updateVpcIPv6CIDRBlock(ec2conn, d); err != nil {
+			return err
 		}
+		d.SetPartial("assign_generated_ipv6_cidr_block")
 	}
+	if err := setTags(ec2conn, d, meta); err != nil {
+		return err
+	}
+	d.SetPartial("tags")
 	d.Partial(false)
 	return resourceRawsVpcRead(d, meta)
 }
 
+// updateVpcIPv6CIDRBlock asks for an IPv6 CIDR block picked by Amazon, or
+// gives it back, and waits until that is done.
+func updateVpcIPv6CIDRBlock(conn EC2API, d *schema.ResourceData) error {
+	vpcID := d.Id()
+	if d.Get("assign_generated_ipv6_cidr_block").(bool) {
+		log.Printf("[INFO] Assigning an IPv6 CIDR block to VPC %s", vpcID)
+		assign := true
+		resp, err := conn.AssociateVPCCIDRBlock(&associateVPCCIDRBlockRequest{
+			AmazonProvidedIPv6CIDRBlock: &assign,
+			VPCID:                       &vpcID,
+		})
+		if err != nil {
+			return fmt.Errorf("Error assigning an IPv6 CIDR block to VPC %s: %s", vpcID, err)
+		}
+		associationID := *resp.IPv6CIDRBlockAssociation.AssociationID
+
+		log.Printf("[DEBUG] Waiting for IPv6 CIDR block association (%s) to become associated", associationID)
+		stateConf := &resource.StateChangeConf{
+			Pending: []string{"associating"},
+			Target:  []string{"associated"},
+			Refresh: VPCIPv6CIDRBlockAssociationStateRefreshFunc(conn, vpcID, associationID),
+			Timeout: 10 * time.Minute,
+		}
+		if _, err := stateConf.WaitForState(); err != nil {
+			return fmt.Errorf("Error waiting for IPv6 CIDR block association (%s) to become associated: %s", associationID, err)
+		}
+		return nil
+	}
+
+	associationID := d.Get("ipv6_association_id").(string)
+	if associationID == "" {
+		return nil
+	}
+	log.Printf("[INFO] Removing IPv6 CIDR block association %s from VPC %s", associationID, vpcID)
+	if _, err := conn.DisassociateVPCCIDRBlock(&disassociateVPCCIDRBlockRequest{
+		AssociationID: &associationID,
+	}); err != nil {
+		return fmt.Errorf("Error removing the IPv6 CIDR block of VPC %s: %s", vpcID, err)
+	}
+
+	log.Printf("[DEBUG] Waiting for IPv6 CIDR block association (%s) to become disassociated", associationID)
+	stateConf := &resource.StateChangeConf{
+		Pending: []string{"associated", "disassociating"},
+		Target:  []string{"disassociated"},
+		Refresh: goneIsDisassociated(VPCIPv6CIDRBlockAssociationStateRefreshFunc(conn, vpcID, associationID), associationID),
+		Timeout: 10 * time.Minute,
+	}
+	if _, err := stateConf.WaitForState(); err != nil {
+		return fmt.Errorf("Error waiting for IPv6 CIDR block association (%s) to become disassociated: %s", associationID, err)
+	}
+	return nil
+}
+
 func resourceRawsVpcDelete(d *schema.ResourceData, meta interface{}) error {
 	ec2conn := meta.(*AWSClient).codaConn
 	vpcID := d.Id()
+	if d.Get("force_destroy").(bool) {
+		if err := deleteVpcDependencies(ec2conn, vpcID); err != nil {
+			return err
+		}
+	}
 	DeleteVpcOpts := &ec2.DeleteVPCRequest{
 		VPCID: &vpcID,
 	}
@@ -173,9 +495,329 @@ func resourceRawsVpcDelete(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
+// deleteVpcDependencies deletes what would stop a VPC from being deleted
+// (force_destroy), in the order EC2 allows: internet gateways and route
+// tables, then network interfaces, which hold on to security groups and
+// subnets, then the rules security groups have on each other, the groups
+// and the subnets. The main route table, default security group and
+// default network ACL are deleted with the VPC. Instances are not
+// terminated: a VPC that still has any is left untouched.
+func deleteVpcDependencies(conn EC2API, vpcID string) error {
+	log.Printf("[INFO] force_destroy: deleting the dependencies of VPC %s", vpcID)
+	steps := []func(EC2API, string) error{
+		checkVpcInstances,
+		deleteVpcInternetGateways,
+		deleteVpcRouteTables,
+		deleteVpcNetworkInterfaces,
+		deleteVpcSecurityGroups,
+		deleteVpcSubnets,
+	}
+	for _, step := range steps {
+		if err := step(conn, vpcID); err != nil {
+			return err
+		}
+	}
+	log.Printf("[INFO] force_destroy: VPC %s has no dependencies left", vpcID)
+	return nil
+}
+
+// checkVpcInstances fails if instances are running in the VPC. Their
+// primary network interfaces can't be detached, so the VPC can't be
+// deleted until they are terminated.
+func checkVpcInstances(conn EC2API, vpcID string) error {
+	resp, err := conn.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesRequest{
+		Filters: buildEC2Filters(map[string]string{
+			"vpc-id": vpcID,
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("Error finding the network interfaces of VPC %s: %s", vpcID, err)
+	}
+	var instances []string
+	for _, eni := range resp.NetworkInterfaces {
+		if isPrimaryNetworkInterface(eni) {
+			instances = append(instances, fmt.Sprintf("%s (%s)", *eni.Attachment.InstanceID, *eni.NetworkInterfaceID))
+		}
+	}
+	if len(instances) > 0 {
+		return fmt.Errorf("VPC %s still has instances, which force_destroy does not terminate: %s. "+
+			"Terminate them and try again.", vpcID, strings.Join(instances, ", "))
+	}
+	return nil
+}
+
+// isPrimaryNetworkInterface reports whether eni is the primary network
+// interface of an instance, which stays attached until it is terminated.
+func isPrimaryNetworkInterface(eni ec2.NetworkInterface) bool {
+	return eni.Attachment != nil && eni.Attachment.InstanceID != nil &&
+		eni.Attachment.DeviceIndex != nil && *eni.Attachment.DeviceIndex == 0
+}
+
+func deleteVpcInternetGateways(conn EC2API, vpcID string) error {
+	resp, err := conn.DescribeInternetGateways(&ec2.DescribeInternetGatewaysRequest{
+		Filters: buildEC2Filters(map[string]string{
+			"attachment.vpc-id": vpcID,
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("Error finding the internet gateways of VPC %s: %s", vpcID, err)
+	}
+	for _, igw := range resp.InternetGateways {
+		igwID := *igw.InternetGatewayID
+		log.Printf("[INFO] force_destroy: detaching and deleting internet gateway %s", igwID)
+		err := conn.DetachInternetGateway(&ec2.DetachInternetGatewayRequest{
+			InternetGatewayID: &igwID,
+			VPCID:             &vpcID,
+		})
+		if err != nil && !isEC2ErrorCode(err, "Gateway.NotAttached", "InvalidInternetGatewayID.NotFound") {
+			return fmt.Errorf("Error detaching internet gateway %s: %s", igwID, err)
+		}
+		err = conn.DeleteInternetGateway(&ec2.DeleteInternetGatewayRequest{
+			InternetGatewayID: &igwID,
+		})
+		if err != nil && !isEC2ErrorCode(err, "InvalidInternetGatewayID.NotFound") {
+			return fmt.Errorf("Error deleting internet gateway %s: %s", igwID, err)
+		}
+	}
+	return nil
+}
+
+func deleteVpcRouteTables(conn EC2API, vpcID string) error {
+	resp, err := conn.DescribeRouteTables(&ec2.DescribeRouteTablesRequest{
+		Filters: buildEC2Filters(map[string]string{
+			"vpc-id": vpcID,
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("Error finding the route tables of VPC %s: %s", vpcID, err)
+	}
+	for _, rt := range resp.RouteTables {
+		rtID := *rt.RouteTableID
+		main := false
+		for _, a := range rt.Associations {
+			if a.Main != nil && *a.Main {
+				main = true
+			}
+		}
+		if main {
+			continue
+		}
+
+		log.Printf("[INFO] force_destroy: disassociating and deleting route table %s", rtID)
+		for _, a := range rt.Associations {
+			err := conn.DisassociateRouteTable(&ec2.DisassociateRouteTableRequest{
+				AssociationID: a.RouteTableAssociationID,
+			})
+			if err != nil && !isEC2ErrorCode(err, "InvalidAssociationID.NotFound") {
+				return fmt.Errorf("Error disassociating route table %s: %s", rtID, err)
+			}
+		}
+		err := conn.DeleteRouteTable(&ec2.DeleteRouteTableRequest{
+			RouteTableID: &rtID,
+		})
+		if err != nil && !isEC2ErrorCode(err, "InvalidRouteTableID.NotFound") {
+			return fmt.Errorf("Error deleting route table %s: %s", rtID, err)
+		}
+	}
+	return nil
+}
+
+func deleteVpcNetworkInterfaces(conn EC2API, vpcID string) error {
+	resp, err := conn.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesRequest{
+		Filters: buildEC2Filters(map[string]string{
+			"vpc-id": vpcID,
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("Error finding the network interfaces of VPC %s: %s", vpcID, err)
+	}
+	for _, eni := range resp.NetworkInterfaces {
+		eniID := *eni.NetworkInterfaceID
+		if eni.RequesterManaged != nil && *eni.RequesterManaged {
+			// They go when the resource of the service that made them does.
+			log.Printf("[WARN] force_destroy: network interface %s is managed by another service and is left in place", eniID)
+			continue
+		}
+		if isPrimaryNetworkInterface(eni) {
+			// Launched since checkVpcInstances; deleting the VPC will fail.
+			log.Printf("[WARN] force_destroy: network interface %s is the primary interface of instance %s and is left in place",
+				eniID, *eni.Attachment.InstanceID)
+			continue
+		}
+
+		if eni.Attachment != nil && eni.Attachment.AttachmentID != nil {
+			log.Printf("[INFO] force_destroy: detaching network interface %s", eniID)
+			force := true
+			err := conn.DetachNetworkInterface(&ec2.DetachNetworkInterfaceRequest{
+				AttachmentID: eni.Attachment.AttachmentID,
+				Force:        &force,
+			})
+			if err != nil && !isEC2ErrorCode(err, "InvalidAttachmentID.NotFound") {
+				return fmt.Errorf("Error detaching network interface %s: %s", eniID, err)
+			}
+
+			log.Printf("[DEBUG] Waiting for network interface (%s) to become available", eniID)
+			stateConf := &resource.StateChangeConf{
+				Pending: []string{"in-use", "detaching"},
+				Target:  []string{"available"},
+				Refresh: NetworkInterfaceStateRefreshFunc(conn, eniID),
+				Timeout: 10 * time.Minute,
+			}
+			if _, err := stateConf.WaitForState(); err != nil {
+				return fmt.Errorf("Error waiting for network interface (%s) to become available: %s", eniID, err)
+			}
+		}
+
+		log.Printf("[INFO] force_destroy: deleting network interface %s", eniID)
+		err := conn.DeleteNetworkInterface(&ec2.DeleteNetworkInterfaceRequest{
+			NetworkInterfaceID: &eniID,
+		})
+		if err != nil && !isEC2ErrorCode(err, "InvalidNetworkInterfaceID.NotFound") {
+			return fmt.Errorf("Error deleting network interface %s: %s", eniID, err)
+		}
+	}
+	return nil
+}
+
+func deleteVpcSecurityGroups(conn EC2API, vpcID string) error {
+	resp, err := conn.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsRequest{
+		Filters: buildEC2Filters(map[string]string{
+			"vpc-id": vpcID,
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("Error finding the security groups of VPC %s: %s", vpcID, err)
+	}
+
+	// A group can't be deleted while another group's rules refer to it,
+	// so those rules go first, from every group including the default one.
+	for _, sg := range resp.SecurityGroups {
+		sgID := *sg.GroupID
+		if ingress := groupReferences(sgID, sg.IPPermissions); len(ingress) > 0 {
+			log.Printf("[INFO] force_destroy: revoking %d ingress rules that refer to other groups from security group %s", len(ingress), sgID)
+			err := conn.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressRequest{
+				GroupID:       &sgID,
+				IPPermissions: ingress,
+			})
+			if err != nil && !isEC2ErrorCode(err, "InvalidPermission.NotFound") {
+				return fmt.Errorf("Error revoking the ingress rules of security group %s: %s", sgID, err)
+			}
+		}
+		if egress := groupReferences(sgID, sg.IPPermissionsEgress); len(egress) > 0 {
+			log.Printf("[INFO] force_destroy: revoking %d egress rules that refer to other groups from security group %s", len(egress), sgID)
+			err := conn.RevokeSecurityGroupEgress(&ec2.RevokeSecurityGroupEgressRequest{
+				GroupID:       &sgID,
+				IPPermissions: egress,
+			})
+			if err != nil && !isEC2ErrorCode(err, "InvalidPermission.NotFound") {
+				return fmt.Errorf("Error revoking the egress rules of security group %s: %s", sgID, err)
+			}
+		}
+	}
+
+	for _, sg := range resp.SecurityGroups {
+		if *sg.GroupName == "default" {
+			continue
+		}
+		sgID := *sg.GroupID
+		log.Printf("[INFO] force_destroy: deleting security group %s", sgID)
+		err := conn.DeleteSecurityGroup(&ec2.DeleteSecurityGroupRequest{
+			GroupID: &sgID,
+		})
+		if err != nil && !isEC2ErrorCode(err, "InvalidGroup.NotFound") {
+			return fmt.Errorf("Error deleting security group %s: %s", sgID, err)
+		}
+	}
+	return nil
+}
+
+// groupReferences returns the part of perms that refers to security
+// groups other than sgID.
+func groupReferences(sgID string, perms []ec2.IPPermission) []ec2.IPPermission {
+	var result []ec2.IPPermission
+	for _, perm := range perms {
+		var pairs []ec2.UserIDGroupPair
+		for _, pair := range perm.UserIDGroupPairs {
+			if pair.GroupID != nil && *pair.GroupID != sgID {
+				pairs = append(pairs, pair)
+			}
+		}
+		if len(pairs) == 0 {
+			continue
+		}
+		result = append(result, ec2.IPPermission{
+			FromPort:         perm.FromPort,
+			IPProtocol:       perm.IPProtocol,
+			ToPort:           perm.ToPort,
+			UserIDGroupPairs: pairs,
+		})
+	}
+	return result
+}
+
+func deleteVpcSubnets(conn EC2API, vpcID string) error {
+	resp, err := conn.DescribeSubnets(&ec2.DescribeSubnetsRequest{
+		Filters: buildEC2Filters(map[string]string{
+			"vpc-id": vpcID,
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("Error finding the subnets of VPC %s: %s", vpcID, err)
+	}
+	for _, subnet := range resp.Subnets {
+		subnetID := *subnet.SubnetID
+		log.Printf("[INFO] force_destroy: deleting subnet %s", subnetID)
+		err := conn.DeleteSubnet(&ec2.DeleteSubnetRequest{
+			SubnetID: &subnetID,
+		})
+		if err != nil && !isEC2ErrorCode(err, "InvalidSubnetID.NotFound") {
+			return fmt.Errorf("Error deleting subnet %s: %s", subnetID, err)
+		}
+	}
+	return nil
+}
+
+// isEC2ErrorCode reports whether err is an EC2 API error with one of the
+// given codes.
+func isEC2ErrorCode(err error, codes ...string) bool {
+	ec2err, ok := err.(*codaws.APIError)
+	if !ok {
+		return false
+	}
+	for _, code := range codes {
+		if ec2err.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// NetworkInterfaceStateRefreshFunc returns a resource.StateRefreshFunc
+// that is used to watch a network interface.
+func NetworkInterfaceStateRefreshFunc(conn EC2API, id string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		resp, err := conn.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesRequest{
+			NetworkInterfaceIDs: []string{id},
+		})
+		if err != nil {
+			if isEC2ErrorCode(err, "InvalidNetworkInterfaceID.NotFound") {
+				return nil, "", nil
+			}
+			log.Printf("[ERROR] Error on NetworkInterfaceStateRefresh: %s", err)
+			return nil, "", err
+		}
+		if len(resp.NetworkInterfaces) == 0 {
+			return nil, "", nil
+		}
+
+		eni := &resp.NetworkInterfaces[0]
+		return eni, *eni.Status, nil
+	}
+}
+
 // VPCStateRefreshFunc returns a resource.StateRefreshFunc that is used to watch
 // a VPC.
-func VPCStateRefreshFunc(conn *ec2.EC2, id string) resource.StateRefreshFunc {
+func VPCStateRefreshFunc(conn EC2API, id string) resource.StateRefreshFunc {
 	return func() (interface{}, string, error) {
 		DescribeVpcOpts := &ec2.DescribeVPCsRequest{
 			VPCIDs: []string{id},
@@ -200,3 +842,38 @@ func VPCStateRefreshFunc(conn *ec2.EC2, id string) resource.StateRefreshFunc {
 		return vpc, *vpc.State, nil
 	}
 }
+
+// VPCIPv6CIDRBlockAssociationStateRefreshFunc returns a
+// resource.StateRefreshFunc that is used to watch the IPv6 CIDR block
+// association of a VPC.
+func VPCIPv6CIDRBlockAssociationStateRefreshFunc(conn EC2API, vpcID, id string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		resp, err := conn.DescribeVPCCIDRBlocks(&describeVPCCIDRBlocksRequest{
+			VPCIDs: []string{vpcID},
+		})
+		if err != nil {
+			if ec2err, ok := err.(*codaws.APIError); ok && ec2err.Code == "InvalidVpcID.NotFound" {
+				return nil, "", nil
+			}
+			log.Printf("[ERROR] Error on VPCIPv6CIDRBlockAssociationStateRefresh: %s", err)
+			return nil, "", err
+		}
+
+		for _, vpc := range resp.VPCs {
+			for i, a := range vpc.IPv6CIDRBlockAssociations {
+				if a.AssociationID == nil || *a.AssociationID != id || a.State == nil {
+					continue
+				}
+				if *a.State == "failing" || *a.State == "failed" {
+					message := ""
+					if a.StatusMessage != nil {
+						message = *a.StatusMessage
+					}
+					return nil, *a.State, fmt.Errorf("IPv6 CIDR block association (%s) %s: %s", id, *a.State, message)
+				}
+				return &vpc.IPv6CIDRBlockAssociations[i], *a.State, nil
+			}
+		}
+		return nil, "", nil
+	}
+}
@@ -0,0 +1,162 @@
+package raws
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceRawsVpcIpv4CidrBlockAssociation() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRawsVpcIpv4CidrBlockAssociationCreate,
+		Read:   resourceRawsVpcIpv4CidrBlockAssociationRead,
+		Delete: resourceRawsVpcIpv4CidrBlockAssociationDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"vpc_id": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"cidr_block": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+		},
+	}
+}
+
+func resourceRawsVpcIpv4CidrBlockAssociationCreate(d *schema.ResourceData, meta interface{}) error {
+	ec2conn := meta.(*AWSClient).codaConn
+	vpcID := d.Get("vpc_id").(string)
+	cidr := d.Get("cidr_block").(string)
+	log.Printf("[DEBUG] Associating CIDR block %s with VPC %s", cidr, vpcID)
+	resp, err := ec2conn.AssociateVPCCIDRBlock(&associateVPCCIDRBlockRequest{
+		CIDRBlock: &cidr,
+		VPCID:     &vpcID,
+	})
+	if err != nil {
+		return fmt.Errorf("Error associating CIDR block %s with VPC %s: %s", cidr, vpcID, err)
+	}
+	d.SetId(*resp.CIDRBlockAssociation.AssociationID)
+	log.Printf("[INFO] VPC CIDR block association ID: %s", d.Id())
+
+	log.Printf("[DEBUG] Waiting for VPC CIDR block association (%s) to become associated", d.Id())
+	stateConf := &resource.StateChangeConf{
+		Pending: []string{"associating"},
+		Target:  []string{"associated"},
+		Refresh: VPCCIDRBlockAssociationStateRefreshFunc(ec2conn, d.Id()),
+		Timeout: 10 * time.Minute,
+	}
+	if _, err := stateConf.WaitForState(); err != nil {
+		return fmt.Errorf("Error waiting for VPC CIDR block association (%s) to become associated: %s", d.Id(), err)
+	}
+
+	return resourceRawsVpcIpv4CidrBlockAssociationRead(d, meta)
+}
+
+func resourceRawsVpcIpv4CidrBlockAssociationRead(d *schema.ResourceData, meta interface{}) error {
+	ec2conn := meta.(*AWSClient).codaConn
+	blocksRaw, state, err := VPCCIDRBlockAssociationStateRefreshFunc(ec2conn, d.Id())()
+	if err != nil {
+		return err
+	}
+	if blocksRaw == nil || state == "disassociating" || state == "disassociated" {
+		log.Printf("[WARN] VPC CIDR block association (%s) not found, removing from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+	blocks := blocksRaw.(*vpcCIDRBlocks)
+	d.Set("vpc_id", blocks.VPCID)
+	d.Set("cidr_block", blocks.CIDRBlockAssociations[0].CIDRBlock)
+	return nil
+}
+
+func resourceRawsVpcIpv4CidrBlockAssociationDelete(d *schema.ResourceData, meta interface{}) error {
+	ec2conn := meta.(*AWSClient).codaConn
+	associationID := d.Id()
+	log.Printf("[INFO] Disassociating VPC CIDR block association: %s", d.Id())
+	if _, err := ec2conn.DisassociateVPCCIDRBlock(&disassociateVPCCIDRBlockRequest{
+		AssociationID: &associationID,
+	}); err != nil {
+		ec2err, ok := err.(*codaws.APIError)
+		if ok && ec2err.Code == "InvalidVpcCidrBlockAssociationID.NotFound" {
+			return nil
+		}
+		return fmt.Errorf("Error disassociating VPC CIDR block association (%s): %s", d.Id(), err)
+	}
+
+	log.Printf("[DEBUG] Waiting for VPC CIDR block association (%s) to become disassociated", d.Id())
+	stateConf := &resource.StateChangeConf{
+		Pending: []string{"associated", "disassociating"},
+		Target:  []string{"disassociated"},
+		Refresh: goneIsDisassociated(VPCCIDRBlockAssociationStateRefreshFunc(ec2conn, d.Id()), d.Id()),
+		Timeout: 10 * time.Minute,
+	}
+	if _, err := stateConf.WaitForState(); err != nil {
+		return fmt.Errorf("Error waiting for VPC CIDR block association (%s) to become disassociated: %s", d.Id(), err)
+	}
+	return nil
+}
+
+// VPCCIDRBlockAssociationStateRefreshFunc returns a resource.StateRefreshFunc
+// that is used to watch a VPC CIDR block association. The result is the
+// VPC's blocks, narrowed down to the association.
+func VPCCIDRBlockAssociationStateRefreshFunc(conn EC2API, id string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		resp, err := conn.DescribeVPCCIDRBlocks(&describeVPCCIDRBlocksRequest{
+			Filters: buildEC2Filters(map[string]string{
+				"cidr-block-association.association-id": id,
+			}),
+		})
+		if err != nil {
+			log.Printf("[ERROR] Error on VPCCIDRBlockAssociationStateRefresh: %s", err)
+			return nil, "", err
+		}
+
+		for _, vpc := range resp.VPCs {
+			for _, a := range vpc.CIDRBlockAssociations {
+				if a.AssociationID == nil || *a.AssociationID != id || a.State == nil {
+					continue
+				}
+				if *a.State == "failing" || *a.State == "failed" {
+					message := ""
+					if a.StatusMessage != nil {
+						message = *a.StatusMessage
+					}
+					return nil, *a.State, fmt.Errorf("VPC CIDR block association (%s) %s: %s", id, *a.State, message)
+				}
+				return &vpcCIDRBlocks{
+					VPCID:                 vpc.VPCID,
+					CIDRBlockAssociations: []vpcCIDRBlockAssociation{a},
+				}, *a.State, nil
+			}
+		}
+
+		// Sometimes AWS just has consistency issues and doesn't see
+		// our association yet. Return an empty state.
+		return nil, "", nil
+	}
+}
+
+// goneIsDisassociated wraps the refresh function of a CIDR block
+// association so that it reports the association as disassociated once
+// EC2 stops listing it, which happens soon after it's disassociated.
+func goneIsDisassociated(refresh resource.StateRefreshFunc, id string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		result, state, err := refresh()
+		if err == nil && result == nil {
+			return id, "disassociated", nil
+		}
+		return result, state, err
+	}
+}
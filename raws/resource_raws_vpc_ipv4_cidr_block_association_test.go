@@ -0,0 +1,93 @@
+package raws
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccVpcIpv4CidrBlockAssociation_basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVpcIpv4CidrBlockAssociationDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccVpcIpv4CidrBlockAssociationConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVpcIpv4CidrBlockAssociationExists("raws_vpc_ipv4_cidr_block_association.secondary"),
+					resource.TestCheckResourceAttr(
+						"raws_vpc_ipv4_cidr_block_association.secondary", "cidr_block", "172.2.0.0/16"),
+				),
+			},
+			resource.TestStep{
+				// The VPC is read again once the block is associated.
+				Config: testAccVpcIpv4CidrBlockAssociationConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("raws_vpc.foo", "cidr_block_associations.#", "2"),
+					resource.TestCheckResourceAttr("raws_vpc.foo", "cidr_block_associations.0.cidr_block", "10.1.0.0/16"),
+					resource.TestCheckResourceAttr("raws_vpc.foo", "cidr_block_associations.1.cidr_block", "172.2.0.0/16"),
+				),
+			},
+			resource.TestStep{
+				ResourceName:      "raws_vpc_ipv4_cidr_block_association.secondary",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func testAccCheckVpcIpv4CidrBlockAssociationExists(n string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+		if rs.Primary.ID == "" {
+			return fmt.Errorf("No VPC CIDR block association ID is set")
+		}
+
+		conn := testAccProvider.Meta().(*AWSClient).codaConn
+		_, state, err := VPCCIDRBlockAssociationStateRefreshFunc(conn, rs.Primary.ID)()
+		if err != nil {
+			return err
+		}
+		if state != "associated" {
+			return fmt.Errorf("VPC CIDR block association %s is %q", rs.Primary.ID, state)
+		}
+		return nil
+	}
+}
+
+func testAccCheckVpcIpv4CidrBlockAssociationDestroy(s *terraform.State) error {
+	conn := testAccProvider.Meta().(*AWSClient).codaConn
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "raws_vpc_ipv4_cidr_block_association" {
+			continue
+		}
+		_, state, err := VPCCIDRBlockAssociationStateRefreshFunc(conn, rs.Primary.ID)()
+		if err != nil {
+			return err
+		}
+		if state != "" && state != "disassociated" {
+			return fmt.Errorf("VPC CIDR block association %s still exists (%s)", rs.Primary.ID, state)
+		}
+	}
+
+	return nil
+}
+
+const testAccVpcIpv4CidrBlockAssociationConfig = `
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+}
+
+resource "raws_vpc_ipv4_cidr_block_association" "secondary" {
+	vpc_id = "${raws_vpc.foo.id}"
+	cidr_block = "172.2.0.0/16"
+}
+`
@@ -2,6 +2,9 @@ package raws
 
 import (
 	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 
 	codaws "github.com/awslabs/aws-sdk-go/aws"
@@ -21,16 +24,58 @@ func TestAccVpc_basic(t *testing.T) {
 			resource.TestStep{
 				Config: testAccVpcConfig,
 				Check: resource.ComposeTestCheckFunc(
-					testAccCheckVpcExists("aws_vpc.foo", &vpc),
+					testAccCheckVpcExists("raws_vpc.foo", &vpc),
 					testAccCheckVpcCidr(&vpc, "10.1.0.0/16"),
 					resource.TestCheckResourceAttr(
-						"aws_vpc.foo", "cidr_block", "10.1.0.0/16"),
+						"raws_vpc.foo", "cidr_block", "10.1.0.0/16"),
+					testAccCheckVpcDefaults("raws_vpc.foo", &vpc),
+					resource.TestCheckResourceAttr(
+						"raws_vpc.foo", "cidr_block_associations.#", "1"),
+					resource.TestCheckResourceAttr(
+						"raws_vpc.foo", "cidr_block_associations.0.cidr_block", "10.1.0.0/16"),
 				),
 			},
 		},
 	})
 }
 
+func TestAccVpc_importBasic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVpcDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccVpcConfigTags,
+			},
+			resource.TestStep{
+				ResourceName:      "raws_vpc.foo",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccVpc_importMissing(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVpcDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccVpcConfig,
+			},
+			resource.TestStep{
+				ResourceName:  "raws_vpc.foo",
+				ImportState:   true,
+				ImportStateId: "vpc-00000000",
+				ExpectError:   regexp.MustCompile("non-existent"),
+			},
+		},
+	})
+}
+
 func TestAccVpc_dedicatedTenancy(t *testing.T) {
 	var vpc ec2.VPC
 
@@ -42,9 +87,87 @@ func TestAccVpc_dedicatedTenancy(t *testing.T) {
 			resource.TestStep{
 				Config: testAccVpcDedicatedConfig,
 				Check: resource.ComposeTestCheckFunc(
-					testAccCheckVpcExists("aws_vpc.bar", &vpc),
+					testAccCheckVpcExists("raws_vpc.bar", &vpc),
 					resource.TestCheckResourceAttr(
-						"aws_vpc.bar", "instance_tenancy", "dedicated"),
+						"raws_vpc.bar", "instance_tenancy", "dedicated"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccVpc_tags(t *testing.T) {
+	var vpc ec2.VPC
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVpcDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccVpcConfigTags,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVpcExists("raws_vpc.foo", &vpc),
+					testAccCheckTags(&vpc.Tags, "foo", "bar"),
+					resource.TestCheckResourceAttr("raws_vpc.foo", "tags.foo", "bar"),
+				),
+			},
+			resource.TestStep{
+				Config: testAccVpcConfigTagsUpdate,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVpcExists("raws_vpc.foo", &vpc),
+					testAccCheckTags(&vpc.Tags, "foo", ""),
+					testAccCheckTags(&vpc.Tags, "bar", "baz"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccVpc_defaultTags(t *testing.T) {
+	var vpc ec2.VPC
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVpcDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccVpcConfigDefaultTags,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVpcExists("raws_vpc.foo", &vpc),
+					testAccCheckTags(&vpc.Tags, "foo", "bar"),
+					testAccCheckTags(&vpc.Tags, "CostCenter", "1234"),
+					resource.TestCheckResourceAttr("raws_vpc.foo", "tags.%", "1"),
+					resource.TestCheckResourceAttr("raws_vpc.foo", "tags_all.CostCenter", "1234"),
+				),
+			},
+			// Changing default_tags updates the existing VPC
+			resource.TestStep{
+				Config: testAccVpcConfigDefaultTagsUpdate,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVpcExists("raws_vpc.foo", &vpc),
+					testAccCheckTags(&vpc.Tags, "foo", "bar"),
+					testAccCheckTags(&vpc.Tags, "CostCenter", "5678"),
+					testAccCheckTags(&vpc.Tags, "Owner", "platform"),
+				),
+			},
+			// A default tag removed outside Terraform is put back
+			resource.TestStep{
+				PreConfig: func() {
+					conn := testAccProvider.Meta().(*AWSClient).codaConn
+					err := conn.DeleteTags(&ec2.DeleteTagsRequest{
+						Resources: []string{*vpc.VPCID},
+						Tags:      []ec2.Tag{tag("Owner", "platform")},
+					})
+					if err != nil {
+						t.Fatalf("err: %s", err)
+					}
+				},
+				Config: testAccVpcConfigDefaultTagsUpdate,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVpcExists("raws_vpc.foo", &vpc),
+					testAccCheckTags(&vpc.Tags, "Owner", "platform"),
 				),
 			},
 		},
@@ -62,29 +185,306 @@ func TestAccVpcUpdate(t *testing.T) {
 			resource.TestStep{
 				Config: testAccVpcConfig,
 				Check: resource.ComposeTestCheckFunc(
-					testAccCheckVpcExists("aws_vpc.foo", &vpc),
+					testAccCheckVpcExists("raws_vpc.foo", &vpc),
 					testAccCheckVpcCidr(&vpc, "10.1.0.0/16"),
 					resource.TestCheckResourceAttr(
-						"aws_vpc.foo", "cidr_block", "10.1.0.0/16"),
+						"raws_vpc.foo", "cidr_block", "10.1.0.0/16"),
 				),
 			},
 			resource.TestStep{
 				Config: testAccVpcConfigUpdate,
 				Check: resource.ComposeTestCheckFunc(
-					testAccCheckVpcExists("aws_vpc.foo", &vpc),
+					testAccCheckVpcExists("raws_vpc.foo", &vpc),
 					resource.TestCheckResourceAttr(
-						"aws_vpc.foo", "enable_dns_hostnames", "true"),
+						"raws_vpc.foo", "enable_dns_hostnames", "true"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccVpc_disabledDnsSupport(t *testing.T) {
+	var vpc ec2.VPC
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVpcDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccVpcConfigDisabledDnsSupport,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVpcExists("raws_vpc.foo", &vpc),
+					testAccCheckVpcAttribute(&vpc, "enableDnsSupport", false),
+					resource.TestCheckResourceAttr(
+						"raws_vpc.foo", "enable_dns_support", "false"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccVpc_forceDestroy(t *testing.T) {
+	var vpc ec2.VPC
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckVpcDestroy,
+		Steps: []resource.TestStep{
+			resource.TestStep{
+				Config: testAccVpcConfigForceDestroy,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckVpcExists("raws_vpc.foo", &vpc),
+					testAccCreateVpcDependencies(&vpc),
 				),
 			},
 		},
 	})
 }
 
+// testAccCreateVpcDependencies creates what force_destroy has to clean up
+// behind Terraform's back: an attached internet gateway, a subnet with its
+// own route table, and a security group that the default group refers to
+// and that refers back to it.
+func testAccCreateVpcDependencies(vpc *ec2.VPC) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		conn := testAccProvider.Meta().(*AWSClient).codaConn
+
+		igw, err := conn.CreateInternetGateway(&ec2.CreateInternetGatewayRequest{})
+		if err != nil {
+			return err
+		}
+		if err := conn.AttachInternetGateway(&ec2.AttachInternetGatewayRequest{
+			InternetGatewayID: igw.InternetGateway.InternetGatewayID,
+			VPCID:             vpc.VPCID,
+		}); err != nil {
+			return err
+		}
+
+		subnet, err := conn.CreateSubnet(&ec2.CreateSubnetRequest{
+			CIDRBlock: codaws.String("10.1.1.0/24"),
+			VPCID:     vpc.VPCID,
+		})
+		if err != nil {
+			return err
+		}
+		rt, err := conn.CreateRouteTable(&ec2.CreateRouteTableRequest{VPCID: vpc.VPCID})
+		if err != nil {
+			return err
+		}
+		if _, err := conn.AssociateRouteTable(&ec2.AssociateRouteTableRequest{
+			RouteTableID: rt.RouteTable.RouteTableID,
+			SubnetID:     subnet.Subnet.SubnetID,
+		}); err != nil {
+			return err
+		}
+
+		sg, err := conn.CreateSecurityGroup(&ec2.CreateSecurityGroupRequest{
+			Description: codaws.String("left behind"),
+			GroupName:   codaws.String("leftover"),
+			VPCID:       vpc.VPCID,
+		})
+		if err != nil {
+			return err
+		}
+		defaultSG, err := vpcDefaultSecurityGroup(conn, *vpc.VPCID)
+		if err != nil {
+			return err
+		}
+		for _, pair := range [][2]codaws.StringValue{{sg.GroupID, defaultSG.GroupID}, {defaultSG.GroupID, sg.GroupID}} {
+			if err := conn.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressRequest{
+				GroupID: pair[0],
+				IPPermissions: []ec2.IPPermission{{
+					IPProtocol:       codaws.String("tcp"),
+					FromPort:         codaws.Integer(22),
+					ToPort:           codaws.Integer(22),
+					UserIDGroupPairs: []ec2.UserIDGroupPair{{GroupID: pair[1]}},
+				}},
+			}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func testVpcDependencies() *mockEC2 {
+	tcp := func(port int, groupID string) ec2.IPPermission {
+		return ec2.IPPermission{
+			IPProtocol:       codaws.String("tcp"),
+			FromPort:         codaws.Integer(port),
+			ToPort:           codaws.Integer(port),
+			UserIDGroupPairs: []ec2.UserIDGroupPair{{GroupID: codaws.String(groupID)}},
+		}
+	}
+	return &mockEC2{
+		internetGateways: []ec2.InternetGateway{
+			{InternetGatewayID: codaws.String("igw-1")},
+		},
+		routeTables: []ec2.RouteTable{
+			{
+				RouteTableID: codaws.String("rtb-main"),
+				Associations: []ec2.RouteTableAssociation{
+					{RouteTableAssociationID: codaws.String("rtbassoc-main"), Main: codaws.Boolean(true)},
+				},
+			},
+			{
+				RouteTableID: codaws.String("rtb-1"),
+				Associations: []ec2.RouteTableAssociation{
+					{RouteTableAssociationID: codaws.String("rtbassoc-1"), Main: codaws.Boolean(false)},
+				},
+			},
+		},
+		networkInterfaces: []ec2.NetworkInterface{
+			{
+				NetworkInterfaceID: codaws.String("eni-1"),
+				Status:             codaws.String("in-use"),
+				Attachment:         &ec2.NetworkInterfaceAttachment{AttachmentID: codaws.String("eni-attach-1")},
+			},
+			{NetworkInterfaceID: codaws.String("eni-2"), Status: codaws.String("available")},
+			{NetworkInterfaceID: codaws.String("eni-3"), Status: codaws.String("in-use"), RequesterManaged: codaws.Boolean(true)},
+		},
+		securityGroups: []ec2.SecurityGroup{
+			{
+				GroupID:       codaws.String("sg-0"),
+				GroupName:     codaws.String("default"),
+				IPPermissions: []ec2.IPPermission{tcp(22, "sg-0"), tcp(22, "sg-1")},
+			},
+			{
+				GroupID:             codaws.String("sg-1"),
+				GroupName:           codaws.String("web"),
+				IPPermissionsEgress: []ec2.IPPermission{tcp(443, "sg-2")},
+			},
+			{GroupID: codaws.String("sg-2"), GroupName: codaws.String("db")},
+		},
+		subnets: []ec2.Subnet{
+			{SubnetID: codaws.String("subnet-1")},
+		},
+	}
+}
+
+// testChanges returns the calls that aren't Describe calls.
+func testChanges(calls []string) []string {
+	var changes []string
+	for _, c := range calls {
+		if !strings.HasPrefix(c, "Describe") {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+func TestDeleteVpcDependencies(t *testing.T) {
+	m := testVpcDependencies()
+	if err := deleteVpcDependencies(m, "vpc-1"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		"DetachInternetGateway igw-1 vpc-1",
+		"DeleteInternetGateway igw-1",
+		"DisassociateRouteTable rtbassoc-1",
+		"DeleteRouteTable rtb-1",
+		"DetachNetworkInterface eni-attach-1",
+		"DeleteNetworkInterface eni-1",
+		"DeleteNetworkInterface eni-2",
+		"RevokeSecurityGroupIngress sg-0 tcp:22-22:sg-1",
+		"RevokeSecurityGroupEgress sg-1 tcp:443-443:sg-2",
+		"DeleteSecurityGroup sg-1",
+		"DeleteSecurityGroup sg-2",
+		"DeleteSubnet subnet-1",
+	}
+	if changes := testChanges(m.calls); !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("bad calls:\n%#v\nexpected:\n%#v", changes, expected)
+	}
+}
+
+func TestDeleteVpcDependencies_errors(t *testing.T) {
+	// Whatever is already gone is skipped
+	m := testVpcDependencies()
+	m.errs = map[string]error{
+		"DeleteInternetGateway": &codaws.APIError{Code: "InvalidInternetGatewayID.NotFound"},
+		"DeleteSubnet":          &codaws.APIError{Code: "InvalidSubnetID.NotFound"},
+	}
+	if err := deleteVpcDependencies(m, "vpc-1"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Anything else stops the delete before the VPC's subnets are touched
+	m = testVpcDependencies()
+	m.errs = map[string]error{
+		"DeleteSecurityGroup sg-2": &codaws.APIError{Code: "DependencyViolation", Message: "resource sg-2 has a dependent object"},
+	}
+	err := deleteVpcDependencies(m, "vpc-1")
+	if err == nil || !strings.Contains(err.Error(), "Error deleting security group sg-2") {
+		t.Fatalf("expected a security group error, got: %v", err)
+	}
+	for _, c := range m.calls {
+		if strings.HasPrefix(c, "DeleteSubnet") {
+			t.Fatalf("subnets should not be deleted after an error: %#v", m.calls)
+		}
+	}
+}
+
+func TestDeleteVpcDependencies_instances(t *testing.T) {
+	// The primary interface of an instance can't be detached, so nothing is
+	// touched until the instance is terminated
+	m := testVpcDependencies()
+	m.networkInterfaces = append(m.networkInterfaces, ec2.NetworkInterface{
+		NetworkInterfaceID: codaws.String("eni-4"),
+		Status:             codaws.String("in-use"),
+		Attachment: &ec2.NetworkInterfaceAttachment{
+			AttachmentID: codaws.String("eni-attach-4"),
+			InstanceID:   codaws.String("i-1"),
+			DeviceIndex:  codaws.Integer(0),
+		},
+	})
+	err := deleteVpcDependencies(m, "vpc-1")
+	if err == nil || !strings.Contains(err.Error(), "still has instances, which force_destroy does not terminate: i-1 (eni-4)") {
+		t.Fatalf("expected an instance error, got: %v", err)
+	}
+	if changes := testChanges(m.calls); len(changes) != 0 {
+		t.Fatalf("nothing should be changed while instances are running: %#v", changes)
+	}
+
+	// One launched after the check is left for the VPC delete to fail on
+	m.calls = nil
+	if err := deleteVpcNetworkInterfaces(m, "vpc-1"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, c := range m.calls {
+		if strings.HasSuffix(c, "eni-attach-4") || strings.HasSuffix(c, "eni-4") {
+			t.Fatalf("the primary interface should be left in place: %#v", m.calls)
+		}
+	}
+
+	// A secondary interface of an instance is detached and deleted
+	m = testVpcDependencies()
+	m.networkInterfaces = append(m.networkInterfaces, ec2.NetworkInterface{
+		NetworkInterfaceID: codaws.String("eni-4"),
+		Status:             codaws.String("in-use"),
+		Attachment: &ec2.NetworkInterfaceAttachment{
+			AttachmentID: codaws.String("eni-attach-4"),
+			InstanceID:   codaws.String("i-1"),
+			DeviceIndex:  codaws.Integer(1),
+		},
+	})
+	if err := deleteVpcDependencies(m, "vpc-1"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	changes := strings.Join(testChanges(m.calls), "\n")
+	if !strings.Contains(changes, "DetachNetworkInterface eni-attach-4\nDeleteNetworkInterface eni-4") {
+		t.Fatalf("expected eni-4 to be detached and deleted:\n%s", changes)
+	}
+}
+
 func testAccCheckVpcDestroy(s *terraform.State) error {
 	conn := testAccProvider.Meta().(*AWSClient).codaConn
 
 	for _, rs := range s.RootModule().Resources {
-		if rs.Type != "aws_vpc" {
+		if rs.Type != "raws_vpc" {
 			continue
 		}
 		DescribeVpcOpts := &ec2.DescribeVPCsRequest{
@@ -117,13 +517,76 @@ func testAccCheckVpcCidr(vpc *ec2.VPC, expected string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		CIDRBlock := vpc.CIDRBlock
 		if *CIDRBlock != expected {
-			return fmt.Errorf("Bad cidr: %s", vpc.CIDRBlock)
+			return fmt.Errorf("Bad cidr: %s", *vpc.CIDRBlock)
 		}
 
 		return nil
 	}
 }
 
+// testAccCheckVpcDefaults checks the IDs of the resources EC2 created
+// along with the VPC.
+func testAccCheckVpcDefaults(n string, vpc *ec2.VPC) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		attrs := s.RootModule().Resources[n].Primary.Attributes
+		conn := testAccProvider.Meta().(*AWSClient).codaConn
+
+		mainRouteTableID, err := vpcMainRouteTableID(conn, *vpc.VPCID)
+		if err != nil {
+			return err
+		}
+		sg, err := vpcDefaultSecurityGroup(conn, *vpc.VPCID)
+		if err != nil {
+			return err
+		}
+		if sg == nil {
+			return fmt.Errorf("VPC %s has no default security group", *vpc.VPCID)
+		}
+		networkACLID, err := vpcDefaultNetworkACLID(conn, *vpc.VPCID)
+		if err != nil {
+			return err
+		}
+
+		expected := map[string]string{
+			"main_route_table_id":       mainRouteTableID,
+			"default_security_group_id": *sg.GroupID,
+			"owner_id":                  *sg.OwnerID,
+			"default_network_acl_id":    networkACLID,
+			"dhcp_options_id":           *vpc.DHCPOptionsID,
+		}
+		for k, v := range expected {
+			if v == "" || attrs[k] != v {
+				return fmt.Errorf("Bad %s: %q, expected %q", k, attrs[k], v)
+			}
+		}
+
+		return nil
+	}
+}
+
+// testAccCheckVpcAttribute checks a boolean attribute of the VPC, such as
+// enableDnsSupport, in EC2.
+func testAccCheckVpcAttribute(vpc *ec2.VPC, attribute string, expected bool) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		conn := testAccProvider.Meta().(*AWSClient).codaConn
+		resp, err := conn.DescribeVPCAttribute(&ec2.DescribeVPCAttributeRequest{
+			Attribute: codaws.String(attribute),
+			VPCID:     vpc.VPCID,
+		})
+		if err != nil {
+			return err
+		}
+		value := resp.EnableDNSSupport
+		if attribute == "enableDnsHostnames" {
+			value = resp.EnableDNSHostnames
+		}
+		if value == nil || value.Value == nil || *value.Value != expected {
+			return fmt.Errorf("VPC %s: expected %s to be %t", *vpc.VPCID, attribute, expected)
+		}
+		return nil
+	}
+}
+
 func testAccCheckVpcExists(n string, vpc *ec2.VPC) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[n]
@@ -154,20 +617,27 @@ func testAccCheckVpcExists(n string, vpc *ec2.VPC) resource.TestCheckFunc {
 }
 
 const testAccVpcConfig = `
-resource "aws_vpc" "foo" {
+resource "raws_vpc" "foo" {
 	cidr_block = "10.1.0.0/16"
 }
 `
 
 const testAccVpcConfigUpdate = `
-resource "aws_vpc" "foo" {
+resource "raws_vpc" "foo" {
 	cidr_block = "10.1.0.0/16"
 	enable_dns_hostnames = true
 }
 `
 
+const testAccVpcConfigDisabledDnsSupport = `
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+	enable_dns_support = false
+}
+`
+
 const testAccVpcConfigTags = `
-resource "aws_vpc" "foo" {
+resource "raws_vpc" "foo" {
 	cidr_block = "10.1.0.0/16"
 
 	tags {
@@ -177,7 +647,7 @@ resource "aws_vpc" "foo" {
 `
 
 const testAccVpcConfigTagsUpdate = `
-resource "aws_vpc" "foo" {
+resource "raws_vpc" "foo" {
 	cidr_block = "10.1.0.0/16"
 
 	tags {
@@ -185,10 +655,54 @@ resource "aws_vpc" "foo" {
 	}
 }
 `
+const testAccVpcConfigDefaultTags = `
+provider "raws" {
+	default_tags {
+		tags {
+			CostCenter = "1234"
+		}
+	}
+}
+
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+
+	tags {
+		foo = "bar"
+	}
+}
+`
+
+const testAccVpcConfigDefaultTagsUpdate = `
+provider "raws" {
+	default_tags {
+		tags {
+			CostCenter = "5678"
+			Owner      = "platform"
+		}
+	}
+}
+
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+
+	tags {
+		foo = "bar"
+	}
+}
+`
+
 const testAccVpcDedicatedConfig = `
-resource "aws_vpc" "bar" {
+resource "raws_vpc" "bar" {
 	instance_tenancy = "dedicated"
 
 	cidr_block = "10.2.0.0/16"
 }
 `
+
+const testAccVpcConfigForceDestroy = `
+resource "raws_vpc" "foo" {
+	cidr_block = "10.1.0.0/16"
+	force_destroy = true
+}
+`
@@ -0,0 +1,314 @@
+package raws
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 25
+
+	retryBaseDelay = 100 * time.Millisecond
+	retryMaxDelay  = 5 * time.Second
+
+	// notFoundRetryWindow is how long an eventually-consistent NotFound is
+	// retried for. A new ID shows up well within it; a mistyped one fails
+	// once it is over.
+	notFoundRetryWindow = 30 * time.Second
+)
+
+// throttlingCodes are API error codes for requests that were turned away
+// without being carried out, which are always worth retrying.
+var throttlingCodes = map[string]bool{
+	"RequestLimitExceeded": true,
+	"Throttling":           true,
+	"ThrottlingException":  true,
+	"RequestThrottled":     true,
+}
+
+// serverErrorCodes are API error codes for requests that may or may not
+// have been carried out.
+var serverErrorCodes = map[string]bool{
+	"InternalError":      true,
+	"Unavailable":        true,
+	"ServiceUnavailable": true,
+}
+
+// readOnlyActions are the action prefixes that change nothing, so a
+// request whose outcome is unknown can be sent again.
+var readOnlyActions = []string{
+	"Describe",
+	"Get",
+	"List",
+}
+
+// eventualConsistencyActions are the action prefixes that reference IDs
+// of resources which may have just been created. EC2 is eventually
+// consistent, so an *.NotFound from one of them is retried. Describe,
+// Delete, Detach and Disassociate are left out on purpose: callers treat
+// NotFound from those as "already gone".
+var eventualConsistencyActions = []string{
+	"Associate",
+	"Attach",
+	"Authorize",
+	"Create",
+	"Modify",
+}
+
+// retryTransport retries throttled, failed and eventually-consistent API
+// requests with jittered exponential backoff. Requests that may have been
+// carried out before failing, on a network error or a server error, are
+// only retried if sending them twice is safe. It gives up as soon as the
+// request's context is done.
+type retryTransport struct {
+	MaxRetries int
+	Base       http.RoundTripper
+
+	// OnRetry, when set, is called before each retry of an action.
+	OnRetry func(action string)
+
+	// sleep and now are replaced in tests.
+	sleep func(time.Duration)
+	now   func() time.Time
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	params := requestParams(req, body)
+	action := params.Get("Action")
+	idempotent := isIdempotentRequest(params)
+
+	ctx := req.Context()
+	start := t.clock()
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		r := cloneRequest(req)
+		if body != nil {
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, err := t.base().RoundTrip(r)
+		reason, notFound := retryReason(action, idempotent, resp, err)
+		if reason == "" || attempt >= t.MaxRetries {
+			return resp, err
+		}
+		if notFound && t.clock().Sub(start) >= notFoundRetryWindow {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		if t.OnRetry != nil {
+			t.OnRetry(action)
+		}
+		delay := retryDelay(attempt)
+		log.Printf("[DEBUG] Retrying %s in %s (attempt %d/%d): %s",
+			action, delay, attempt+1, t.MaxRetries, reason)
+		if err := t.doSleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *retryTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *retryTransport) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// doSleep waits for d, returning the context's error early if it is done
+// first.
+func (t *retryTransport) doSleep(ctx context.Context, d time.Duration) error {
+	if t.sleep != nil {
+		t.sleep(d)
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryReason returns why the request should be retried, or an empty
+// string if the response should be returned to the caller. notFound is
+// set when the reason is an eventually-consistent NotFound, which is only
+// retried for a short while. idempotent tells whether the request is safe
+// to send again if it may already have been carried out.
+func retryReason(action string, idempotent bool, resp *http.Response, err error) (reason string, notFound bool) {
+	if err != nil {
+		if !idempotent {
+			return "", false
+		}
+		return err.Error(), false
+	}
+	if resp.StatusCode < 400 {
+		return "", false
+	}
+
+	apiErr := readAPIError(resp)
+	switch {
+	case throttlingCodes[apiErr.Code]:
+		return apiErr.Code, false
+	case serverErrorCodes[apiErr.Code] || resp.StatusCode >= 500:
+		if !idempotent {
+			return "", false
+		}
+		if apiErr.Code != "" {
+			return apiErr.Code, false
+		}
+		return resp.Status, false
+	case strings.HasSuffix(apiErr.Code, ".NotFound") && isEventualConsistencyAction(action):
+		return apiErr.Code, true
+	}
+	return "", false
+}
+
+// isIdempotentRequest reports whether a request can be sent twice without
+// changing more than sending it once: it only reads, or it carries a
+// ClientToken that EC2 uses to recognise the repeat.
+func isIdempotentRequest(params url.Values) bool {
+	if params.Get("ClientToken") != "" {
+		return true
+	}
+	action := params.Get("Action")
+	for _, prefix := range readOnlyActions {
+		if strings.HasPrefix(action, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isEventualConsistencyAction(action string) bool {
+	for _, prefix := range eventualConsistencyActions {
+		if strings.HasPrefix(action, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	retryRandMu sync.Mutex
+	retryRand   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// retryDelay returns the backoff before the given retry: exponential in
+// the attempt, capped, with the upper half jittered.
+func retryDelay(attempt int) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 16 {
+		if d := retryBaseDelay << uint(attempt); d < retryMaxDelay {
+			delay = d
+		}
+	}
+
+	retryRandMu.Lock()
+	jitter := time.Duration(retryRand.Int63n(int64(delay/2) + 1))
+	retryRandMu.Unlock()
+
+	return delay/2 + jitter
+}
+
+// requestAction returns the Query API action of a request, e.g.
+// "CreateVpc".
+func requestAction(req *http.Request, body []byte) string {
+	return requestParams(req, body).Get("Action")
+}
+
+// requestParams returns the Query API parameters of a request, sent
+// either in a form-encoded body or in the URL.
+func requestParams(req *http.Request, body []byte) url.Values {
+	if v, err := url.ParseQuery(string(body)); err == nil && v.Get("Action") != "" {
+		return v
+	}
+	return req.URL.Query()
+}
+
+// apiError is an error returned by an AWS Query API in either the EC2
+// (<Response><Errors><Error>) or the generic (<ErrorResponse><Error>)
+// format.
+type apiError struct {
+	Code      string
+	Message   string
+	RequestID string
+}
+
+// readAPIError decodes the error in resp, leaving resp.Body readable for
+// the SDK.
+func readAPIError(resp *http.Response) apiError {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return apiError{}
+	}
+
+	return parseAPIError(body)
+}
+
+func parseAPIError(body []byte) apiError {
+	var doc struct {
+		Errors []struct {
+			Code    string
+			Message string
+		} `xml:"Errors>Error"`
+		Error struct {
+			Code    string
+			Message string
+		}
+		RequestID    string `xml:"RequestID"`
+		RequestIDAlt string `xml:"RequestId"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return apiError{}
+	}
+
+	e := apiError{
+		Code:      doc.Error.Code,
+		Message:   doc.Error.Message,
+		RequestID: doc.RequestID,
+	}
+	if len(doc.Errors) > 0 {
+		e.Code = doc.Errors[0].Code
+		e.Message = doc.Errors[0].Message
+	}
+	if e.RequestID == "" {
+		e.RequestID = doc.RequestIDAlt
+	}
+	return e
+}
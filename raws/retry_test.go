@@ -0,0 +1,245 @@
+package raws
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTransport answers each request with the next scripted response and
+// records the request bodies it saw.
+type fakeTransport struct {
+	Responses []fakeResponse
+	Bodies    []string
+}
+
+type fakeResponse struct {
+	Status int
+	Code   string
+	Err    error
+}
+
+func (f *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := ioutil.ReadAll(req.Body)
+	f.Bodies = append(f.Bodies, string(body))
+
+	r := f.Responses[0]
+	if len(f.Responses) > 1 {
+		f.Responses = f.Responses[1:]
+	}
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	payload := "<CreateVpcResponse><requestId>ok</requestId></CreateVpcResponse>"
+	if r.Code != "" {
+		payload = fmt.Sprintf(`<Response><Errors><Error><Code>%s</Code><Message>failed</Message></Error></Errors><RequestID>req-1</RequestID></Response>`, r.Code)
+	}
+	return &http.Response{
+		StatusCode: r.Status,
+		Status:     http.StatusText(r.Status),
+		Body:       ioutil.NopCloser(strings.NewReader(payload)),
+		Header:     make(http.Header),
+		Request:    req,
+	}, nil
+}
+
+func testRetryRequest(action string) *http.Request {
+	form := url.Values{"Action": {action}, "Version": {"2015-03-01"}}
+	req, _ := http.NewRequest("POST", "https://ec2.us-east-1.amazonaws.com/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestRetryTransport(t *testing.T) {
+	cases := []struct {
+		Action    string
+		Responses []fakeResponse
+		Calls     int
+		Status    int
+	}{
+		// Success on the first attempt.
+		{"CreateVpc", []fakeResponse{{200, "", nil}}, 1, 200},
+		// Throttling is retried for every action.
+		{"DescribeVpcs", []fakeResponse{{503, "RequestLimitExceeded", nil}, {503, "RequestLimitExceeded", nil}, {200, "", nil}}, 3, 200},
+		// Server errors are retried for reads...
+		{"DescribeVpcs", []fakeResponse{{500, "InternalError", nil}, {200, "", nil}}, 2, 200},
+		{"DescribeVpcs", []fakeResponse{{503, "", nil}, {200, "", nil}}, 2, 200},
+		// ...but not for changes, which may have been made already.
+		{"CreateVpc", []fakeResponse{{500, "InternalError", nil}, {200, "", nil}}, 1, 500},
+		{"CreateSubnet", []fakeResponse{{503, "Unavailable", nil}, {200, "", nil}}, 1, 503},
+		// Network errors are retried for reads.
+		{"DescribeVpcs", []fakeResponse{{0, "", fmt.Errorf("connection reset")}, {200, "", nil}}, 2, 200},
+		// NotFound right after create is retried for mutating calls...
+		{"CreateRoute", []fakeResponse{{400, "InvalidGatewayID.NotFound", nil}, {200, "", nil}}, 2, 200},
+		{"AttachInternetGateway", []fakeResponse{{400, "InvalidVpcID.NotFound", nil}, {200, "", nil}}, 2, 200},
+		// ...but not for reads and deletes, where callers expect it.
+		{"DescribeVpcs", []fakeResponse{{400, "InvalidVpcID.NotFound", nil}}, 1, 400},
+		{"DeleteSubnet", []fakeResponse{{400, "InvalidSubnetID.NotFound", nil}}, 1, 400},
+		// Other client errors are returned immediately.
+		{"CreateVpc", []fakeResponse{{400, "InvalidParameterValue", nil}}, 1, 400},
+		{"DeleteVpc", []fakeResponse{{400, "DependencyViolation", nil}}, 1, 400},
+		// Retries stop after MaxRetries.
+		{"CreateVpc", []fakeResponse{{503, "Throttling", nil}}, 4, 503},
+	}
+
+	for i, tc := range cases {
+		fake := &fakeTransport{Responses: tc.Responses}
+		var delays []time.Duration
+		rt := &retryTransport{
+			MaxRetries: 3,
+			Base:       fake,
+			sleep:      func(d time.Duration) { delays = append(delays, d) },
+		}
+
+		resp, err := rt.RoundTrip(testRetryRequest(tc.Action))
+		if err != nil {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+		if resp.StatusCode != tc.Status {
+			t.Fatalf("%d: expected status %d, got %d", i, tc.Status, resp.StatusCode)
+		}
+		if len(fake.Bodies) != tc.Calls {
+			t.Fatalf("%d: expected %d calls, got %d", i, tc.Calls, len(fake.Bodies))
+		}
+		if len(delays) != tc.Calls-1 {
+			t.Fatalf("%d: expected %d sleeps, got %d", i, tc.Calls-1, len(delays))
+		}
+		for _, b := range fake.Bodies {
+			if !strings.Contains(b, "Action="+tc.Action) {
+				t.Fatalf("%d: request body not replayed on retry: %q", i, b)
+			}
+		}
+
+		// The caller must still be able to read the error response.
+		body, _ := ioutil.ReadAll(resp.Body)
+		if tc.Status >= 400 && !strings.Contains(string(body), "<Code>") {
+			t.Fatalf("%d: error body not readable: %q", i, body)
+		}
+	}
+}
+
+func TestRetryTransport_mutating(t *testing.T) {
+	// A network error on a change is returned: the change may have been
+	// made, and making it again could leave a duplicate behind.
+	fake := &fakeTransport{Responses: []fakeResponse{{0, "", fmt.Errorf("connection reset")}, {200, "", nil}}}
+	rt := &retryTransport{MaxRetries: 3, Base: fake, sleep: func(time.Duration) {}}
+	if _, err := rt.RoundTrip(testRetryRequest("CreateSecurityGroup")); err == nil {
+		t.Fatal("expected the network error")
+	}
+	if len(fake.Bodies) != 1 {
+		t.Fatalf("expected 1 call, got %d", len(fake.Bodies))
+	}
+
+	// With a ClientToken EC2 recognises the repeat, so it is retried
+	fake = &fakeTransport{Responses: []fakeResponse{{0, "", fmt.Errorf("connection reset")}, {500, "InternalError", nil}, {200, "", nil}}}
+	rt = &retryTransport{MaxRetries: 3, Base: fake, sleep: func(time.Duration) {}}
+	form := url.Values{"Action": {"RunInstances"}, "ClientToken": {"token-1"}, "Version": {"2015-03-01"}}
+	req, _ := http.NewRequest("POST", "https://ec2.us-east-1.amazonaws.com/", strings.NewReader(form.Encode()))
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resp.StatusCode != 200 || len(fake.Bodies) != 3 {
+		t.Fatalf("expected success after 3 calls, got %d after %d", resp.StatusCode, len(fake.Bodies))
+	}
+}
+
+func TestRetryTransport_notFoundWindow(t *testing.T) {
+	// A NotFound that outlasts the window, such as a mistyped ID, is
+	// returned long before the retries run out.
+	clock := time.Unix(0, 0)
+	fake := &fakeTransport{Responses: []fakeResponse{{400, "InvalidVpcID.NotFound", nil}}}
+	rt := &retryTransport{
+		MaxRetries: defaultMaxRetries,
+		Base:       fake,
+		sleep:      func(d time.Duration) { clock = clock.Add(5 * time.Second) },
+		now:        func() time.Time { return clock },
+	}
+	resp, err := rt.RoundTrip(testRetryRequest("CreateSubnet"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected the NotFound, got %d", resp.StatusCode)
+	}
+	if n := len(fake.Bodies); n != 7 {
+		t.Fatalf("expected 7 calls in %s, got %d", notFoundRetryWindow, n)
+	}
+}
+
+func TestRetryTransport_context(t *testing.T) {
+	// A context cancelled while waiting to retry stops the retries.
+	ctx, cancel := context.WithCancel(context.Background())
+	fake := &fakeTransport{Responses: []fakeResponse{{503, "RequestLimitExceeded", nil}}}
+	rt := &retryTransport{
+		MaxRetries: 3,
+		Base:       fake,
+		sleep:      func(time.Duration) { cancel() },
+	}
+	resp, err := rt.RoundTrip(testRetryRequest("DescribeVpcs").WithContext(ctx))
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v (%v)", err, resp)
+	}
+	if len(fake.Bodies) != 1 {
+		t.Fatalf("expected 1 call, got %d", len(fake.Bodies))
+	}
+
+	// A done context interrupts the backoff itself.
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	fake = &fakeTransport{Responses: []fakeResponse{{503, "RequestLimitExceeded", nil}}}
+	rt = &retryTransport{MaxRetries: 3, Base: fake}
+	start := time.Now()
+	_, err = rt.RoundTrip(testRetryRequest("DescribeVpcs").WithContext(ctx))
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("backoff not interrupted, took %s", elapsed)
+	}
+
+	// Nothing is sent once the context is done.
+	fake = &fakeTransport{Responses: []fakeResponse{{200, "", nil}}}
+	rt = &retryTransport{MaxRetries: 3, Base: fake}
+	if _, err := rt.RoundTrip(testRetryRequest("CreateVpc").WithContext(ctx)); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if len(fake.Bodies) != 0 {
+		t.Fatalf("expected no calls, got %d", len(fake.Bodies))
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempt := 0; attempt < 40; attempt++ {
+		d := retryDelay(attempt)
+		if d <= 0 || d > retryMaxDelay {
+			t.Fatalf("attempt %d: delay out of range: %s", attempt, d)
+		}
+	}
+
+	// Later attempts back off further than the first.
+	if retryDelay(0) > retryBaseDelay {
+		t.Fatalf("first delay too long: %s", retryDelay(0))
+	}
+	if retryDelay(10) < retryMaxDelay/2 {
+		t.Fatalf("delay not capped at the max: %s", retryDelay(10))
+	}
+}
+
+func TestParseAPIError(t *testing.T) {
+	e := parseAPIError([]byte(`<Response><Errors><Error><Code>InvalidVpcID.NotFound</Code><Message>The vpc ID 'vpc-1' does not exist</Message></Error></Errors><RequestID>abc</RequestID></Response>`))
+	if e.Code != "InvalidVpcID.NotFound" || e.RequestID != "abc" {
+		t.Fatalf("bad: %#v", e)
+	}
+
+	e = parseAPIError([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>denied</Message></Error><RequestId>def</RequestId></ErrorResponse>`))
+	if e.Code != "AccessDenied" || e.RequestID != "def" {
+		t.Fatalf("bad: %#v", e)
+	}
+}
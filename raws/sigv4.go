@@ -0,0 +1,136 @@
+package raws
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+)
+
+const sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+// resignV4 replaces the Signature Version 4 signature of req with one for
+// the host and path the request now has. The region and service are
+// taken from the credential scope of the existing signature.
+func resignV4(req *http.Request, body []byte, creds *codaws.Credentials, now time.Time) error {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, sigV4Algorithm+" ") {
+		return fmt.Errorf("request is not signed with %s", sigV4Algorithm)
+	}
+
+	var scope []string
+	for _, part := range strings.Split(strings.TrimPrefix(auth, sigV4Algorithm+" "), ",") {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "Credential=") {
+			scope = strings.Split(strings.TrimPrefix(part, "Credential="), "/")
+		}
+	}
+	if len(scope) != 5 {
+		return fmt.Errorf("bad credential scope in Authorization header: %q", auth)
+	}
+
+	signV4(req, body, creds, scope[2], scope[3], now)
+	return nil
+}
+
+// signV4 signs req with AWS Signature Version 4, setting its X-Amz-Date,
+// X-Amz-Security-Token and Authorization headers.
+func signV4(req *http.Request, body []byte, creds *codaws.Credentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SecurityToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SecurityToken)
+	} else {
+		req.Header.Del("X-Amz-Security-Token")
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for _, k := range []string{"Content-Type", "X-Amz-Date", "X-Amz-Security-Token"} {
+		if v := req.Header.Get(k); v != "" {
+			headers[strings.ToLower(k)] = strings.TrimSpace(v)
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders string
+	for _, k := range names {
+		canonicalHeaders += k + ":" + headers[k] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	for _, s := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns the query string sorted and escaped as Signature
+// Version 4 expects.
+func canonicalQuery(v url.Values) string {
+	var params []string
+	for k, vs := range v {
+		for _, value := range vs {
+			params = append(params, sigV4Escape(k)+"="+sigV4Escape(value))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+func sigV4Escape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
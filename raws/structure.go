@@ -1,6 +1,8 @@
 package raws
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/awslabs/aws-sdk-go/gen/ec2"
@@ -38,7 +40,9 @@ func expandIPPerms(id string, configured []interface{}) []ec2.IPPermission {
 
 				perm.UserIDGroupPairs[i] = ec2.UserIDGroupPair{
 					GroupID: &id,
-					UserID:  &ownerId,
+				}
+				if ownerId != "" {
+					perm.UserIDGroupPairs[i].UserID = &ownerId
 				}
 			}
 		}
@@ -47,8 +51,8 @@ func expandIPPerms(id string, configured []interface{}) []ec2.IPPermission {
 			list := raw.([]interface{})
 			perm.IPRanges = make([]ec2.IPRange, len(list))
 			for i, v := range list {
-				Cidr := v.(ec2.IPRange)
-				perm.IPRanges[i] = Cidr
+				Cidr := v.(string)
+				perm.IPRanges[i] = ec2.IPRange{CIDRIP: &Cidr}
 			}
 		}
 
@@ -58,6 +62,66 @@ func expandIPPerms(id string, configured []interface{}) []ec2.IPPermission {
 	return perms
 }
 
+// flattenIPPerms turns the permissions of security group id into ingress
+// rules, one per protocol and port range, the way expandIPPerms takes
+// them.
+func flattenIPPerms(id string, list []ec2.IPPermission) []map[string]interface{} {
+	ruleMap := make(map[string]map[string]interface{})
+	var keys []string
+	for _, perm := range list {
+		var fromPort, toPort int
+		var protocol string
+		if perm.FromPort != nil {
+			fromPort = *perm.FromPort
+		}
+		if perm.ToPort != nil {
+			toPort = *perm.ToPort
+		}
+		if perm.IPProtocol != nil {
+			protocol = *perm.IPProtocol
+		}
+
+		k := fmt.Sprintf("%s-%d-%d", protocol, fromPort, toPort)
+		m, ok := ruleMap[k]
+		if !ok {
+			m = map[string]interface{}{
+				"from_port": fromPort,
+				"to_port":   toPort,
+				"protocol":  protocol,
+			}
+			ruleMap[k] = m
+			keys = append(keys, k)
+		}
+
+		if len(perm.IPRanges) > 0 {
+			cidrs, _ := m["cidr_blocks"].([]string)
+			for _, r := range perm.IPRanges {
+				cidrs = append(cidrs, *r.CIDRIP)
+			}
+			m["cidr_blocks"] = cidrs
+		}
+
+		var groups []string
+		for _, g := range flattenSecurityGroups(perm.UserIDGroupPairs) {
+			if g == id {
+				m["self"] = true
+				continue
+			}
+			groups = append(groups, g)
+		}
+		if len(groups) > 0 {
+			existing, _ := m["security_groups"].([]string)
+			m["security_groups"] = append(existing, groups...)
+		}
+	}
+
+	rules := make([]map[string]interface{}, 0, len(keys))
+	for _, k := range keys {
+		rules = append(rules, ruleMap[k])
+	}
+	return rules
+}
+
 func flattenSecurityGroups(list []ec2.UserIDGroupPair) []string {
 	result := make([]string, 0, len(list))
 	for _, g := range list {
@@ -65,3 +129,40 @@ func flattenSecurityGroups(list []ec2.UserIDGroupPair) []string {
 	}
 	return result
 }
+
+func expandStringSet(set *schema.Set) []string {
+	list := set.List()
+	result := make([]string, 0, len(list))
+	for _, v := range list {
+		result = append(result, v.(string))
+	}
+	return result
+}
+
+func expandStringMap(m map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		result[k] = v.(string)
+	}
+	return result
+}
+
+// buildEC2Filters turns a map of filter names to values into the filters
+// of a Describe request, in a stable order.
+func buildEC2Filters(m map[string]string) []ec2.Filter {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filters := make([]ec2.Filter, 0, len(names))
+	for _, name := range names {
+		name := name
+		filters = append(filters, ec2.Filter{
+			Name:   &name,
+			Values: []string{m[name]},
+		})
+	}
+	return filters
+}
@@ -0,0 +1,78 @@
+package raws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestExpandIPPerms(t *testing.T) {
+	groups := &schema.Set{F: func(v interface{}) int {
+		return hashcode.String(v.(string))
+	}}
+	groups.Add("sg-2")
+	groups.Add("123456789012/sg-3")
+
+	perms := expandIPPerms("sg-1", []interface{}{
+		map[string]interface{}{
+			"protocol":        "tcp",
+			"from_port":       22,
+			"to_port":         22,
+			"cidr_blocks":     []interface{}{"10.0.0.0/8", "192.168.0.0/16"},
+			"security_groups": groups,
+			"self":            true,
+		},
+	})
+	if len(perms) != 1 {
+		t.Fatalf("bad: %#v", perms)
+	}
+	perm := perms[0]
+	if *perm.IPProtocol != "tcp" || *perm.FromPort != 22 || *perm.ToPort != 22 {
+		t.Fatalf("bad: %#v", perm)
+	}
+
+	var cidrs []string
+	for _, r := range perm.IPRanges {
+		cidrs = append(cidrs, *r.CIDRIP)
+	}
+	if !reflect.DeepEqual(cidrs, []string{"10.0.0.0/8", "192.168.0.0/16"}) {
+		t.Fatalf("bad cidr blocks: %#v", cidrs)
+	}
+
+	// Groups in the same account are sent without a user ID
+	pairs := make(map[string]string)
+	for _, p := range perm.UserIDGroupPairs {
+		var owner string
+		if p.UserID != nil {
+			owner = *p.UserID
+		}
+		pairs[*p.GroupID] = owner
+	}
+	expected := map[string]string{
+		"sg-1": "",
+		"sg-2": "",
+		"sg-3": "123456789012",
+	}
+	if !reflect.DeepEqual(pairs, expected) {
+		t.Fatalf("bad groups: %#v", pairs)
+	}
+}
+
+func TestExpandIPPerms_cidrOnly(t *testing.T) {
+	perms := expandIPPerms("sg-1", []interface{}{
+		map[string]interface{}{
+			"protocol":    "-1",
+			"from_port":   0,
+			"to_port":     0,
+			"cidr_blocks": []interface{}{"0.0.0.0/0"},
+		},
+	})
+	if len(perms) != 1 || len(perms[0].UserIDGroupPairs) != 0 {
+		t.Fatalf("bad: %#v", perms)
+	}
+	if len(perms[0].IPRanges) != 1 || *perms[0].IPRanges[0].CIDRIP != "0.0.0.0/0" {
+		t.Fatalf("bad cidr blocks: %#v", perms[0].IPRanges)
+	}
+}
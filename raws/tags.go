@@ -0,0 +1,244 @@
+package raws
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// ignoreTagsConfig holds the provider's ignore_tags block: tags with these
+// keys or key prefixes are left alone and never show up as drift.
+type ignoreTagsConfig struct {
+	Keys        []string
+	KeyPrefixes []string
+}
+
+// ignored reports whether the tag key is managed outside Terraform. Tags
+// reserved by AWS ("aws:" prefix) are always ignored.
+func (c *ignoreTagsConfig) ignored(key string) bool {
+	if strings.HasPrefix(key, "aws:") {
+		return true
+	}
+	if c == nil {
+		return false
+	}
+
+	for _, k := range c.Keys {
+		if key == k {
+			return true
+		}
+	}
+	for _, prefix := range c.KeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeDefaultTags returns the provider's default_tags overlaid with the
+// resource's own tags, which win on conflicting keys.
+func (c *AWSClient) mergeDefaultTags(tags map[string]string) map[string]string {
+	merged := make(map[string]string, len(c.defaultTags)+len(tags))
+	for k, v := range c.defaultTags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return merged
+}
+
+// removeIgnoredTags returns tags without the keys matched by ignore_tags.
+func (c *AWSClient) removeIgnoredTags(tags map[string]string) map[string]string {
+	result := make(map[string]string, len(tags))
+	for k, v := range tags {
+		if !c.ignoreTags.ignored(k) {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// resourceOwnTags strips the provider's default_tags out of the tags read
+// back from AWS, so that only tags set on the resource itself end up in
+// its tags attribute. A key that is also a default tag is kept if the
+// resource sets it itself, in own, whatever its value.
+func (c *AWSClient) resourceOwnTags(remote, own map[string]string) map[string]string {
+	result := make(map[string]string, len(remote))
+	for k, v := range c.removeIgnoredTags(remote) {
+		if _, ok := c.defaultTags[k]; ok {
+			if _, ok := own[k]; !ok {
+				continue
+			}
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// tagsSchema is the tags attribute shared by every taggable resource.
+func tagsSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeMap,
+		Optional: true,
+	}
+}
+
+// tagsAllSchema holds every tag on the resource, including the
+// provider's default_tags. It is planned by customizeDiffTags.
+func tagsAllSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeMap,
+		Computed: true,
+	}
+}
+
+// setTags brings the tags of the resource in line with its tags attribute
+// merged with the provider's default_tags. Tags matched by ignore_tags are
+// never created or removed.
+func setTags(conn EC2API, d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*AWSClient)
+
+	// The planned tags_all is n; the old one is what the resource has.
+	old, _ := d.GetChange("tags_all")
+	o := client.removeIgnoredTags(expandStringMap(old.(map[string]interface{})))
+	n := client.removeIgnoredTags(client.mergeDefaultTags(expandStringMap(d.Get("tags").(map[string]interface{}))))
+	create, remove := diffTags(o, n)
+
+	resourceID := d.Id()
+	if len(remove) > 0 {
+		log.Printf("[DEBUG] Removing tags from %s: %s", resourceID, tagsDebugString(remove))
+		deleteOpts := &ec2.DeleteTagsRequest{
+			Resources: []string{resourceID},
+			Tags:      remove,
+		}
+		if err := conn.DeleteTags(deleteOpts); err != nil {
+			return fmt.Errorf("Error removing tags from %s: %s", resourceID, err)
+		}
+	}
+	if len(create) > 0 {
+		log.Printf("[DEBUG] Creating tags on %s: %s", resourceID, tagsDebugString(create))
+		createOpts := &ec2.CreateTagsRequest{
+			Resources: []string{resourceID},
+			Tags:      create,
+		}
+		if err := conn.CreateTags(createOpts); err != nil {
+			return fmt.Errorf("Error creating tags on %s: %s", resourceID, err)
+		}
+	}
+
+	d.Set("tags_all", n)
+	return nil
+}
+
+// customizeDiffTags plans tags_all as the resource's tags merged with the
+// provider's default_tags. A change to default_tags, or a default tag
+// removed outside Terraform, then shows up in the plan of every resource
+// and is applied by its Update.
+func customizeDiffTags(d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("tags") {
+		return d.SetNewComputed("tags_all")
+	}
+
+	client := meta.(*AWSClient)
+	all := client.removeIgnoredTags(client.mergeDefaultTags(expandStringMap(d.Get("tags").(map[string]interface{}))))
+	if reflect.DeepEqual(all, expandStringMap(d.Get("tags_all").(map[string]interface{}))) {
+		return nil
+	}
+	return d.SetNew("tags_all", all)
+}
+
+// readTags stores the tags read from AWS in the tags and tags_all
+// attributes. The tags attribute still holds the configuration or prior
+// state, which tells a resource's own tags from the defaults.
+func readTags(d *schema.ResourceData, meta interface{}, tags []ec2.Tag) {
+	client := meta.(*AWSClient)
+	remote := tagsToMap(tags)
+	own := expandStringMap(d.Get("tags").(map[string]interface{}))
+
+	d.Set("tags_all", client.removeIgnoredTags(remote))
+	d.Set("tags", client.resourceOwnTags(remote, own))
+}
+
+// adoptTags stores the tags an existing resource already has, such as the
+// default VPC, before its first update, as though Terraform had set them.
+// setTags then adds and changes the configured tags without removing the
+// others; those show up in the next plan instead.
+func adoptTags(d *schema.ResourceData, meta interface{}, tags []ec2.Tag) {
+	client := meta.(*AWSClient)
+	remote := client.removeIgnoredTags(tagsToMap(tags))
+
+	own := client.resourceOwnTags(remote, nil)
+	for k, v := range expandStringMap(d.Get("tags").(map[string]interface{})) {
+		own[k] = v
+	}
+	d.Set("tags_all", remote)
+	d.Set("tags", own)
+}
+
+// diffTags returns the tags to create so that o matches n, and the tags
+// to remove because they are no longer in n.
+func diffTags(o, n map[string]string) (create, remove []ec2.Tag) {
+	for k, v := range n {
+		if old, ok := o[k]; !ok || old != v {
+			create = append(create, tag(k, v))
+		}
+	}
+	for k, v := range o {
+		if _, ok := n[k]; !ok {
+			remove = append(remove, tag(k, v))
+		}
+	}
+
+	sort.Sort(tagsByKey(create))
+	sort.Sort(tagsByKey(remove))
+	return create, remove
+}
+
+func tag(key, value string) ec2.Tag {
+	return ec2.Tag{Key: &key, Value: &value}
+}
+
+func tagsFromMap(m map[string]string) []ec2.Tag {
+	result := make([]ec2.Tag, 0, len(m))
+	for k, v := range m {
+		result = append(result, tag(k, v))
+	}
+	sort.Sort(tagsByKey(result))
+	return result
+}
+
+func tagsToMap(tags []ec2.Tag) map[string]string {
+	result := make(map[string]string, len(tags))
+	for _, t := range tags {
+		if t.Key == nil {
+			continue
+		}
+		value := ""
+		if t.Value != nil {
+			value = *t.Value
+		}
+		result[*t.Key] = value
+	}
+	return result
+}
+
+func tagsDebugString(tags []ec2.Tag) string {
+	parts := make([]string, len(tags))
+	for i, t := range tags {
+		parts[i] = fmt.Sprintf("%s=%s", *t.Key, *t.Value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+type tagsByKey []ec2.Tag
+
+func (t tagsByKey) Len() int           { return len(t) }
+func (t tagsByKey) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t tagsByKey) Less(i, j int) bool { return *t[i].Key < *t[j].Key }
@@ -0,0 +1,183 @@
+package raws
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/awslabs/aws-sdk-go/gen/ec2"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func testTagsClient() *AWSClient {
+	return &AWSClient{
+		defaultTags: map[string]string{
+			"CostCenter": "1234",
+			"Owner":      "platform",
+		},
+		ignoreTags: &ignoreTagsConfig{
+			Keys:        []string{"LastScanned"},
+			KeyPrefixes: []string{"kubernetes.io/"},
+		},
+	}
+}
+
+func TestMergeDefaultTags(t *testing.T) {
+	client := testTagsClient()
+
+	merged := client.mergeDefaultTags(map[string]string{
+		"Name":  "main",
+		"Owner": "network",
+	})
+	expected := map[string]string{
+		"CostCenter": "1234",
+		"Owner":      "network",
+		"Name":       "main",
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Fatalf("bad: %#v", merged)
+	}
+
+	if merged := (&AWSClient{}).mergeDefaultTags(nil); len(merged) != 0 {
+		t.Fatalf("bad: %#v", merged)
+	}
+}
+
+func TestIgnoreTagsConfig(t *testing.T) {
+	c := &ignoreTagsConfig{
+		Keys:        []string{"LastScanned"},
+		KeyPrefixes: []string{"kubernetes.io/"},
+	}
+	cases := map[string]bool{
+		"LastScanned":                 true,
+		"LastScannedBy":               false,
+		"kubernetes.io/cluster/prod":  true,
+		"Name":                        false,
+		"aws:cloudformation:stack-id": true,
+	}
+	for key, expected := range cases {
+		if c.ignored(key) != expected {
+			t.Fatalf("%s: expected %t", key, expected)
+		}
+	}
+
+	var empty *ignoreTagsConfig
+	if empty.ignored("Name") || !empty.ignored("aws:autoscaling:groupName") {
+		t.Fatal("nil ignore_tags should only ignore aws: tags")
+	}
+}
+
+func TestResourceOwnTags(t *testing.T) {
+	client := testTagsClient()
+
+	own := client.resourceOwnTags(map[string]string{
+		"CostCenter":                 "1234",
+		"Owner":                      "network",
+		"Name":                       "main",
+		"LastScanned":                "yesterday",
+		"kubernetes.io/cluster/prod": "owned",
+	}, map[string]string{
+		"Owner": "network",
+	})
+	expected := map[string]string{
+		"Owner": "network",
+		"Name":  "main",
+	}
+	if !reflect.DeepEqual(own, expected) {
+		t.Fatalf("bad: %#v", own)
+	}
+}
+
+func TestResourceOwnTags_repeatsDefault(t *testing.T) {
+	client := testTagsClient()
+	remote := map[string]string{
+		"CostCenter": "1234",
+		"Owner":      "platform",
+	}
+
+	// The resource sets Owner to the same value as default_tags
+	own := client.resourceOwnTags(remote, map[string]string{"Owner": "platform"})
+	expected := map[string]string{"Owner": "platform"}
+	if !reflect.DeepEqual(own, expected) {
+		t.Fatalf("bad: %#v", own)
+	}
+
+	// Without it in the configuration, Owner is only a default tag
+	own = client.resourceOwnTags(remote, nil)
+	if len(own) != 0 {
+		t.Fatalf("bad: %#v", own)
+	}
+}
+
+func TestDiffTags(t *testing.T) {
+	cases := []struct {
+		Old, New       map[string]string
+		Create, Remove map[string]string
+	}{
+		// Basic add/remove
+		{
+			Old:    map[string]string{"foo": "bar"},
+			New:    map[string]string{"bar": "baz"},
+			Create: map[string]string{"bar": "baz"},
+			Remove: map[string]string{"foo": "bar"},
+		},
+		// Modify
+		{
+			Old:    map[string]string{"foo": "bar"},
+			New:    map[string]string{"foo": "baz"},
+			Create: map[string]string{"foo": "baz"},
+			Remove: map[string]string{},
+		},
+		// No change
+		{
+			Old:    map[string]string{"foo": "bar"},
+			New:    map[string]string{"foo": "bar"},
+			Create: map[string]string{},
+			Remove: map[string]string{},
+		},
+	}
+
+	for i, tc := range cases {
+		c, r := diffTags(tc.Old, tc.New)
+		if cm := tagsToMap(c); !reflect.DeepEqual(cm, tc.Create) {
+			t.Fatalf("%d: bad create: %#v", i, cm)
+		}
+		if rm := tagsToMap(r); !reflect.DeepEqual(rm, tc.Remove) {
+			t.Fatalf("%d: bad remove: %#v", i, rm)
+		}
+	}
+}
+
+func TestTagsFromMap(t *testing.T) {
+	tags := tagsFromMap(map[string]string{"b": "2", "a": "1"})
+	if len(tags) != 2 || *tags[0].Key != "a" || *tags[1].Value != "2" {
+		t.Fatalf("bad: %s", tagsDebugString(tags))
+	}
+	if m := tagsToMap(tags); !reflect.DeepEqual(m, map[string]string{"a": "1", "b": "2"}) {
+		t.Fatalf("bad: %#v", m)
+	}
+}
+
+// testAccCheckTags can be used to check the tags on a resource. An empty
+// value checks that the tag is not set.
+func testAccCheckTags(ts *[]ec2.Tag, key string, value string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		m := tagsToMap(*ts)
+		v, ok := m[key]
+		if value != "" && !ok {
+			return fmt.Errorf("Missing tag: %s", key)
+		} else if value == "" && ok {
+			return fmt.Errorf("Extra tag: %s", key)
+		}
+		if value == "" {
+			return nil
+		}
+
+		if v != value {
+			return fmt.Errorf("%s: bad value: %s", key, v)
+		}
+
+		return nil
+	}
+}
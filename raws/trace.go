@@ -0,0 +1,184 @@
+package raws
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// redacted replaces credentials and signatures in logged requests.
+const redacted = "REDACTED"
+
+// secretParams are request parameters that carry credentials or
+// signatures. They are never logged.
+var secretParams = map[string]bool{
+	"AWSAccessKeyId":       true,
+	"Signature":            true,
+	"SecurityToken":        true,
+	"X-Amz-Credential":     true,
+	"X-Amz-Signature":      true,
+	"X-Amz-Security-Token": true,
+}
+
+// secretHeaders are request headers that carry credentials or signatures.
+var secretHeaders = []string{
+	"Authorization",
+	"X-Amz-Security-Token",
+}
+
+var (
+	// secretElementPattern matches response elements holding credentials,
+	// e.g. in an STS AssumeRole response.
+	secretElementPattern = regexp.MustCompile(`<(SecretAccessKey|SessionToken|Token)>[^<]*</`)
+
+	requestIDPattern = regexp.MustCompile(`<(?:requestId|RequestId|RequestID)>([^<]*)</`)
+)
+
+// logTraceEnabled reports whether Terraform runs with TF_LOG=TRACE, the
+// only level at which API traffic is logged.
+func logTraceEnabled() bool {
+	return strings.EqualFold(os.Getenv("TF_LOG"), "TRACE")
+}
+
+// traceTransport logs every API request and response: the action, its
+// parameters, the request ID and the latency. Credentials and signatures
+// are redacted.
+type traceTransport struct {
+	Service string
+	Base    http.RoundTripper
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	action := requestAction(req, body)
+
+	log.Printf("[TRACE] %s request %s: %s %s\n%s%s", t.Service, action, req.Method,
+		redactURL(req.URL), redactHeaders(req.Header), redactParams(body))
+
+	r := cloneRequest(req)
+	if body != nil {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	start := t.clock()
+	resp, err := t.base().RoundTrip(r)
+	latency := t.clock().Sub(start)
+	if err != nil {
+		log.Printf("[TRACE] %s response %s: error after %s: %s", t.Service, action, latency, err)
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[TRACE] %s response %s: %s (request ID %s, %s)\n%s", t.Service, action, resp.Status,
+		responseRequestID(resp, respBody), latency, secretElementPattern.ReplaceAll(respBody, []byte("<$1>"+redacted+"</")))
+	return resp, nil
+}
+
+func (t *traceTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *traceTransport) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// responseRequestID returns the AWS request ID from the response headers
+// or, for EC2, the response body.
+func responseRequestID(resp *http.Response, body []byte) string {
+	for _, h := range []string{"X-Amzn-Requestid", "X-Amz-Request-Id"} {
+		if id := resp.Header.Get(h); id != "" {
+			return id
+		}
+	}
+	if m := requestIDPattern.FindSubmatch(body); m != nil {
+		return string(m[1])
+	}
+	return "unknown"
+}
+
+// redactParams formats form parameters one per line, in order, with the
+// secret ones redacted.
+func redactParams(body []byte) string {
+	params, err := url.ParseQuery(string(body))
+	if err != nil {
+		return string(body)
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		for _, v := range params[k] {
+			if secretParams[k] {
+				v = redacted
+			}
+			buf.WriteString(k + "=" + v + "\n")
+		}
+	}
+	return buf.String()
+}
+
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	if len(query) == 0 {
+		return u.String()
+	}
+
+	r := *u
+	for k := range query {
+		if secretParams[k] {
+			query.Set(k, redacted)
+		}
+	}
+	r.RawQuery = query.Encode()
+	return r.String()
+}
+
+func redactHeaders(header http.Header) string {
+	h := make(http.Header, len(header))
+	for k, v := range header {
+		h[k] = v
+	}
+	for _, k := range secretHeaders {
+		if h.Get(k) != "" {
+			h.Set(k, redacted)
+		}
+	}
+
+	var buf bytes.Buffer
+	h.Write(&buf)
+	return buf.String()
+}
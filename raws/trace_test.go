@@ -0,0 +1,82 @@
+package raws
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<CreateRouteResponse><requestId>req-1234</requestId><return>true</return>` +
+			`<SecretAccessKey>wJalrXUtnFEMI</SecretAccessKey></CreateRouteResponse>`))
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	clock := time.Unix(0, 0)
+	transport := &traceTransport{
+		Service: "ec2",
+		now: func() time.Time {
+			clock = clock.Add(150 * time.Millisecond)
+			return clock
+		},
+	}
+
+	form := "Action=CreateRoute&RouteTableId=rtb-1&DestinationCidrBlock=10.0.0.0%2F16" +
+		"&X-Amz-Signature=deadbeef&SecurityToken=sessiontoken"
+	req, _ := http.NewRequest("POST", ts.URL+"/?X-Amz-Credential=AKIDEXAMPLE", strings.NewReader(form))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150101, Signature=deadbeef")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "wJalrXUtnFEMI") {
+		t.Fatalf("the response body should be passed on unchanged: %s", body)
+	}
+
+	out := buf.String()
+	for _, s := range []string{
+		"[TRACE] ec2 request CreateRoute: POST",
+		"RouteTableId=rtb-1",
+		"DestinationCidrBlock=10.0.0.0/16",
+		"ec2 response CreateRoute: 200 OK (request ID req-1234, 150ms)",
+	} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("log is missing %q:\n%s", s, out)
+		}
+	}
+	for _, secret := range []string{"AKIDEXAMPLE", "deadbeef", "sessiontoken", "wJalrXUtnFEMI"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("log contains %q:\n%s", secret, out)
+		}
+	}
+}
+
+func TestLogTraceEnabled(t *testing.T) {
+	defer os.Setenv("TF_LOG", os.Getenv("TF_LOG"))
+
+	cases := map[string]bool{
+		"":      false,
+		"DEBUG": false,
+		"TRACE": true,
+		"trace": true,
+	}
+	for level, expected := range cases {
+		os.Setenv("TF_LOG", level)
+		if logTraceEnabled() != expected {
+			t.Fatalf("TF_LOG=%q: expected %t", level, expected)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package raws
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+)
+
+// endpointTransport sends every request to a fixed endpoint instead of
+// the regional endpoint the SDK looked up, e.g. a local stub or emulator.
+// The SDK signed the request for the regional host, so it is signed again
+// with Credentials for the endpoint it is actually sent to.
+type endpointTransport struct {
+	Endpoint    *url.URL
+	Credentials codaws.CredentialsProvider
+	Base        http.RoundTripper
+}
+
+func (t *endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := cloneRequest(req)
+	r.URL.Scheme = t.Endpoint.Scheme
+	r.URL.Host = t.Endpoint.Host
+	r.Host = t.Endpoint.Host
+	if p := strings.TrimRight(t.Endpoint.Path, "/"); p != "" {
+		r.URL.Path = p + r.URL.Path
+	}
+
+	if t.Credentials != nil && r.Header.Get("Authorization") != "" {
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		creds, err := t.Credentials.Credentials()
+		if err != nil {
+			return nil, err
+		}
+		if err := resignV4(r, body, creds, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.base().RoundTrip(r)
+}
+
+func (t *endpointTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// cloneRequest returns a shallow copy of the request with its own URL and
+// headers, so a RoundTripper can change them without touching the
+// caller's request.
+func cloneRequest(req *http.Request) *http.Request {
+	r := new(http.Request)
+	*r = *req
+
+	u := *req.URL
+	r.URL = &u
+
+	r.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		r.Header[k] = append([]string(nil), v...)
+	}
+
+	return r
+}
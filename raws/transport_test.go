@@ -0,0 +1,106 @@
+package raws
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	codaws "github.com/awslabs/aws-sdk-go/aws"
+)
+
+func TestEndpointTransport(t *testing.T) {
+	var got *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL + "/emulator/")
+	client := &http.Client{Transport: &endpointTransport{Endpoint: u}}
+
+	req, _ := http.NewRequest("POST", "https://ec2.us-east-1.amazonaws.com/", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	if got == nil {
+		t.Fatal("request did not reach the custom endpoint")
+	}
+	if got.URL.Path != "/emulator/" {
+		t.Fatalf("bad path: %s", got.URL.Path)
+	}
+	if req.URL.Host != "ec2.us-east-1.amazonaws.com" {
+		t.Fatalf("original request was modified: %s", req.URL)
+	}
+}
+
+func TestEndpointTransport_resign(t *testing.T) {
+	creds := &staticCredentials{AccessKeyID: "key", SecretAccessKey: "secret"}
+
+	var signedFor, sentTo, authErr string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sentTo = r.Host
+		body, _ := ioutil.ReadAll(r.Body)
+		date, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+		if err != nil {
+			authErr = err.Error()
+			return
+		}
+
+		// Check the signature the way EC2 would, for the host it arrived at
+		check, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+		check.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+		c, _ := creds.Credentials()
+		signV4(check, body, c, "us-east-1", "ec2", date)
+		if got, want := r.Header.Get("Authorization"), check.Header.Get("Authorization"); got != want {
+			authErr = "signature mismatch: " + got
+		}
+		signedFor = check.Host
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	client := &http.Client{Transport: &endpointTransport{Endpoint: u, Credentials: creds}}
+
+	body := "Action=DescribeVpcs&Version=2014-10-01"
+	req, _ := http.NewRequest("POST", "https://ec2.us-east-1.amazonaws.com/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c, _ := creds.Credentials()
+	signV4(req, []byte(body), c, "us-east-1", "ec2", time.Now())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	if authErr != "" {
+		t.Fatal(authErr)
+	}
+	if signedFor != u.Host || sentTo != u.Host {
+		t.Fatalf("signed for %s, sent to %s, expected %s", signedFor, sentTo, u.Host)
+	}
+}
+
+func TestSignV4(t *testing.T) {
+	// The get-vanilla case of the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	creds := &codaws.Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	signV4(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != expected {
+		t.Fatalf("bad Authorization:\n%s\nexpected:\n%s", got, expected)
+	}
+}